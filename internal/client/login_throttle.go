@@ -0,0 +1,169 @@
+package client
+
+import (
+	"errors"
+	"github.com/hashicorp/go-hclog"
+	"github.com/jmoiron/sqlx"
+	"github.com/knockbox/authentication/internal/platform"
+	"github.com/knockbox/authentication/pkg/accessors"
+	"github.com/knockbox/authentication/pkg/models"
+	"github.com/knockbox/authentication/pkg/utils"
+	"strconv"
+	"time"
+)
+
+// LoginThrottleClient provides database functionality for models.LoginFailure, throttling failed logins per
+// account and per IP address as set by the utils.LockoutConfig.
+type LoginThrottleClient struct {
+	failures accessors.LoginFailureAccessor
+	config   *utils.LockoutConfig
+	hclog.Logger
+}
+
+// NewLoginThrottleClient creates a new LoginThrottleClient using the SQLImpl accessors.
+func NewLoginThrottleClient(db *sqlx.DB, config *utils.LockoutConfig, l hclog.Logger) *LoginThrottleClient {
+	return NewLoginThrottleClientWithAccessors(platform.LoginFailureSQLImpl{DB: db, Logger: l}, config, l)
+}
+
+// NewLoginThrottleClientWithAccessors creates a new LoginThrottleClient using the given accessor.
+func NewLoginThrottleClientWithAccessors(failures accessors.LoginFailureAccessor, config *utils.LockoutConfig, l hclog.Logger) *LoginThrottleClient {
+	return &LoginThrottleClient{
+		failures: failures,
+		config:   config,
+		Logger:   l,
+	}
+}
+
+// LoginAttempt is a login let through by Attempt. Once the credentials were checked it must be reported to Fail
+// or Release.
+type LoginAttempt struct {
+	user *models.User
+	ip   string
+}
+
+// Attempt reserves a login by the user, nil if unknown, from the IP address before their credentials are
+// checked. Returns how long they must wait instead if either is throttled, in which case no attempt is made.
+// Checking and reserving happen under a lock on each subject that has failed before, so concurrent guesses cannot
+// all get past the gate. Subjects that never failed have nothing stored, a login that succeeds stores nothing.
+func (c *LoginThrottleClient) Attempt(user *models.User, ip string) (*LoginAttempt, time.Duration, error) {
+	wait, err := c.reserve(models.LoginFailureIp, ip)
+	if err != nil || wait > 0 {
+		return nil, wait, err
+	}
+
+	attempt := &LoginAttempt{user: user, ip: ip}
+	if user == nil {
+		return attempt, 0, nil
+	}
+
+	wait, err = c.reserve(models.LoginFailureAccount, accountSubject(user))
+	if err != nil || wait > 0 {
+		return nil, wait, errors.Join(err, c.release(models.LoginFailureIp, ip))
+	}
+
+	return attempt, 0, nil
+}
+
+// Fail counts the attempt as a failed login against the IP address and the user. Returns the
+// models.LoginFailure of the account if the failure locked it, nil otherwise.
+func (c *LoginThrottleClient) Fail(attempt *LoginAttempt) (*models.LoginFailure, error) {
+	now := time.Now()
+	if _, err := c.failures.DeleteStale(now, now.Add(-c.config.Window)); err != nil {
+		return nil, err
+	}
+
+	failure, locked, err := c.penalize(models.LoginFailureIp, attempt.ip, c.config.IpThreshold)
+	if err != nil {
+		return nil, err
+	}
+
+	if locked {
+		c.Warn("ip address locked after failed logins", "ip_address", attempt.ip, "lockouts", failure.Lockouts)
+	}
+
+	if attempt.user == nil {
+		return nil, nil
+	}
+
+	failure, locked, err = c.penalize(models.LoginFailureAccount, accountSubject(attempt.user), c.config.AccountThreshold)
+	if err != nil || !locked {
+		return nil, err
+	}
+
+	return failure, nil
+}
+
+// Release gives back the attempt once the credentials were correct, without forgetting earlier failures.
+func (c *LoginThrottleClient) Release(attempt *LoginAttempt) error {
+	err := c.release(models.LoginFailureIp, attempt.ip)
+	if attempt.user == nil {
+		return err
+	}
+
+	return errors.Join(err, c.release(models.LoginFailureAccount, accountSubject(attempt.user)))
+}
+
+// ExceedsLockouts determines if the account was locked too many times in a row and should stay locked until an
+// admin unlocks it.
+func (c *LoginThrottleClient) ExceedsLockouts(failure *models.LoginFailure) bool {
+	return c.config.MaxLockouts > 0 && failure.Lockouts >= c.config.MaxLockouts
+}
+
+// Reset forgets the failed logins of the user, lifting any lock of their account. Failures of IP addresses are
+// kept so signing in to one account does not reset guesses at others.
+func (c *LoginThrottleClient) Reset(user *models.User) error {
+	_, err := c.failures.DeleteBySubject(models.LoginFailureAccount, accountSubject(user))
+	return err
+}
+
+// reserve holds off other attempts by the subject for the back-off a failure would earn, returning how long to
+// wait instead if it is throttled. Only subjects that failed before are held off. IP addresses without failures
+// are not either, so users behind a shared address do not wait on each other.
+func (c *LoginThrottleClient) reserve(kind, subject string) (time.Duration, error) {
+	var wait time.Duration
+	now := time.Now()
+	_, err := c.failures.Apply(kind, subject, now.Add(-c.config.Window), func(failure *models.LoginFailure) bool {
+		if wait = failure.RetryAfter(now); wait > 0 {
+			return false
+		}
+
+		if kind == models.LoginFailureIp && failure.Failures == 0 {
+			return false
+		}
+
+		failure.Reserve(c.config, now)
+		return true
+	})
+
+	return wait, err
+}
+
+// release undoes reserve for the subject.
+func (c *LoginThrottleClient) release(kind, subject string) error {
+	_, err := c.failures.Apply(kind, subject, time.Now().Add(-c.config.Window), func(failure *models.LoginFailure) bool {
+		failure.Release(c.config)
+		return true
+	})
+
+	return err
+}
+
+// penalize counts a failure against the subject, storing it if it is their first, and applies the back-off or
+// lock it earns, returning true if it locked the subject.
+func (c *LoginThrottleClient) penalize(kind, subject string, threshold uint) (*models.LoginFailure, bool, error) {
+	var locked bool
+	now := time.Now()
+	failure, err := c.failures.ApplyOrCreate(kind, subject, now, now.Add(-c.config.Window), func(failure *models.LoginFailure) bool {
+		failure.Failures++
+		failure.LastFailedAt = now
+		locked = failure.Penalize(c.config, threshold, now)
+		return true
+	})
+
+	return failure, locked, err
+}
+
+// accountSubject returns the subject failed logins of the user are counted against.
+func accountSubject(user *models.User) string {
+	return strconv.FormatUint(uint64(user.Id), 10)
+}
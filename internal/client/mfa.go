@@ -0,0 +1,238 @@
+package client
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/hashicorp/go-hclog"
+	"github.com/jmoiron/sqlx"
+	"github.com/knockbox/authentication/internal/platform"
+	"github.com/knockbox/authentication/pkg/accessors"
+	"github.com/knockbox/authentication/pkg/keyring"
+	"github.com/knockbox/authentication/pkg/models"
+	"github.com/knockbox/authentication/pkg/totp"
+	"github.com/knockbox/authentication/pkg/utils"
+	"strings"
+	"time"
+)
+
+// recoveryCodeCount is how many recovery codes are issued at once.
+const recoveryCodeCount = 10
+
+// totpSkew is how many time steps either side of now a code is accepted for, allowing for clock drift.
+const totpSkew = 1
+
+// ErrNoSealer is returned when a TOTP secret must be sealed or opened but no key-encryption key was configured.
+var ErrNoSealer = errors.New("no key-encryption key configured, two-factor authentication is unavailable")
+
+// MFAClient provides database functionality for models.UserTOTP and models.RecoveryCode. TOTP secrets are sealed
+// by the keyring.Sealer before they are written.
+type MFAClient struct {
+	totp   accessors.UserTOTPAccessor
+	codes  accessors.RecoveryCodeAccessor
+	sealer keyring.Sealer
+	hclog.Logger
+}
+
+// NewMFAClient creates a new MFAClient using the SQLImpl accessors. A nil sealer leaves two-factor authentication
+// unavailable.
+func NewMFAClient(db *sqlx.DB, sealer keyring.Sealer, l hclog.Logger) *MFAClient {
+	return &MFAClient{
+		totp: platform.UserTOTPSQLImpl{
+			DB:     db,
+			Logger: l,
+		},
+		codes: platform.RecoveryCodeSQLImpl{
+			DB:     db,
+			Logger: l,
+		},
+		sealer: sealer,
+		Logger: l,
+	}
+}
+
+// EnrollTOTP generates a new secret for the user and returns it. The authenticator replaces any unconfirmed one
+// and is not required to sign in until it is confirmed.
+func (c *MFAClient) EnrollTOTP(userId uint) (string, error) {
+	if c.sealer == nil {
+		return "", ErrNoSealer
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return "", err
+	}
+
+	sealed, err := c.sealer.Seal([]byte(secret))
+	if err != nil {
+		return "", err
+	}
+
+	_, err = c.totp.Upsert(models.UserTOTP{
+		UserId: userId,
+		Secret: sealed,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return secret, nil
+}
+
+// GetTOTP returns the models.UserTOTP of the user, or nil if they have not enrolled one.
+func (c *MFAClient) GetTOTP(userId uint) (*models.UserTOTP, error) {
+	t, err := c.totp.GetByUserId(userId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+
+	return t, err
+}
+
+// HasTOTP determines if the user has a confirmed authenticator, which is then required to sign in.
+func (c *MFAClient) HasTOTP(userId uint) (bool, error) {
+	t, err := c.GetTOTP(userId)
+	if err != nil {
+		return false, err
+	}
+
+	return t != nil && t.IsConfirmed(), nil
+}
+
+// ConfirmTOTP confirms the authenticator with its first code. Returns false if the code is wrong or the
+// authenticator was already confirmed.
+func (c *MFAClient) ConfirmTOTP(t *models.UserTOTP, code string) (bool, error) {
+	step, ok, err := c.validate(t, code)
+	if err != nil || !ok {
+		return false, err
+	}
+
+	result, err := c.totp.Confirm(t.UserId, step)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := result.RowsAffected()
+	return n == 1, err
+}
+
+// VerifyTOTP determines if the code is valid for the confirmed authenticator. Each code is only accepted once,
+// a code from the same or an earlier time step as the last one accepted is refused.
+func (c *MFAClient) VerifyTOTP(t *models.UserTOTP, code string) (bool, error) {
+	if !t.IsConfirmed() {
+		return false, nil
+	}
+
+	step, ok, err := c.validate(t, code)
+	if err != nil || !ok || step <= t.LastStep {
+		return false, err
+	}
+
+	result, err := c.totp.UseStep(t.UserId, step)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := result.RowsAffected()
+	return n == 1, err
+}
+
+// DisableTOTP removes the authenticator and recovery codes of the user.
+func (c *MFAClient) DisableTOTP(userId uint) error {
+	if _, err := c.totp.DeleteByUserId(userId); err != nil {
+		return err
+	}
+
+	_, err := c.codes.DeleteByUserId(userId)
+	return err
+}
+
+// GenerateRecoveryCodes replaces the recovery codes of the user and returns the new codes, only their hashes are
+// stored.
+func (c *MFAClient) GenerateRecoveryCodes(userId uint) ([]string, error) {
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		code, err := utils.GenerateRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+
+		codes[i] = code
+		hashes[i] = utils.HashToken(normalizeRecoveryCode(code))
+	}
+
+	if _, err := c.codes.ReplaceForUser(userId, hashes); err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// UseRecoveryCode marks the recovery code of the user as used. Returns false if the code does not exist or was
+// already used.
+func (c *MFAClient) UseRecoveryCode(userId uint, code string) (bool, error) {
+	result, err := c.codes.MarkUsed(userId, utils.HashToken(normalizeRecoveryCode(code)))
+	if err != nil {
+		return false, err
+	}
+
+	n, err := result.RowsAffected()
+	return n == 1, err
+}
+
+// RewrapSecrets re-seals every TOTP secret with the current KEK, returning how many were re-sealed.
+func (c *MFAClient) RewrapSecrets() (int, error) {
+	if c.sealer == nil {
+		return 0, ErrNoSealer
+	}
+
+	all, err := c.totp.GetAll()
+	if err != nil {
+		return 0, err
+	}
+
+	for i, t := range all {
+		secret, err := c.sealer.Open(t.Secret)
+		if err != nil {
+			return i, fmt.Errorf("failed to open totp secret of user %d, %w", t.UserId, err)
+		}
+
+		sealed, err := c.sealer.Seal(secret)
+		if err != nil {
+			return i, err
+		}
+
+		if _, err := c.totp.UpdateSecret(t.Id, sealed); err != nil {
+			return i, err
+		}
+	}
+
+	return len(all), nil
+}
+
+// validate opens the secret of the authenticator and validates the code against it, returning the matched step.
+func (c *MFAClient) validate(t *models.UserTOTP, code string) (int64, bool, error) {
+	if c.sealer == nil {
+		return 0, false, ErrNoSealer
+	}
+
+	secret, err := c.sealer.Open(t.Secret)
+	if err != nil {
+		return 0, false, err
+	}
+
+	step, ok := totp.Validate(string(secret), strings.TrimSpace(code), time.Now(), totpSkew)
+	return step, ok, nil
+}
+
+// normalizeRecoveryCode ignores case, spaces and hyphens so codes may be typed loosely.
+func normalizeRecoveryCode(code string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '-' || r == ' ' {
+			return -1
+		}
+
+		return r
+	}, strings.ToLower(code))
+}
@@ -0,0 +1,149 @@
+package client
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/hashicorp/go-hclog"
+	"github.com/jmoiron/sqlx"
+	"github.com/knockbox/authentication/internal/platform"
+	"github.com/knockbox/authentication/pkg/accessors"
+	"github.com/knockbox/authentication/pkg/models"
+	"github.com/knockbox/authentication/pkg/payloads"
+	"github.com/knockbox/authentication/pkg/utils"
+	"time"
+)
+
+// OAuthClient provides database functionality for models.OAuthClient and models.AuthorizationCode
+type OAuthClient struct {
+	clients accessors.OAuthClientAccessor
+	codes   accessors.AuthorizationCodeAccessor
+	hclog.Logger
+}
+
+// NewOAuthClient creates a new OAuthClient using the SQLImpl accessors.
+func NewOAuthClient(db *sqlx.DB, l hclog.Logger) *OAuthClient {
+	return NewOAuthClientWithAccessors(
+		platform.OAuthClientSQLImpl{DB: db, Logger: l},
+		platform.AuthorizationCodeSQLImpl{DB: db, Logger: l},
+		l,
+	)
+}
+
+// NewOAuthClientWithAccessors creates a new OAuthClient using the given accessors.
+func NewOAuthClientWithAccessors(clients accessors.OAuthClientAccessor, codes accessors.AuthorizationCodeAccessor, l hclog.Logger) *OAuthClient {
+	return &OAuthClient{
+		clients: clients,
+		codes:   codes,
+		Logger:  l,
+	}
+}
+
+// RegisterClient creates a client with a generated client_id. Confidential clients are also given a generated
+// secret which is returned, it cannot be retrieved again.
+func (c *OAuthClient) RegisterClient(payload *payloads.OAuthClientRegister) (*models.OAuthClient, string, error) {
+	client := &models.OAuthClient{
+		ClientId:  uuid.NewString(),
+		CreatedAt: time.Now(),
+	}
+	client.ApplyRegister(payload)
+
+	var secret string
+	if payload.Confidential {
+		var err error
+		if secret, err = utils.GenerateOpaqueToken(32); err != nil {
+			return nil, "", err
+		}
+
+		client.SetSecret(secret)
+	}
+
+	if _, err := c.clients.Create(*client); err != nil {
+		return nil, "", err
+	}
+
+	return client, secret, nil
+}
+
+// GetClient returns the models.OAuthClient by client_id, or nil if it does not exist.
+func (c *OAuthClient) GetClient(clientId string) (*models.OAuthClient, error) {
+	client, err := c.clients.GetByClientId(clientId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+
+	return client, err
+}
+
+// AuthenticateClientCredentials returns the confidential client if the secret is its own, or nil otherwise.
+func (c *OAuthClient) AuthenticateClientCredentials(clientId, secret string) (*models.OAuthClient, error) {
+	client, err := c.GetClient(clientId)
+	if err != nil || client == nil {
+		return nil, err
+	}
+
+	if !client.VerifySecret(secret) {
+		return nil, nil
+	}
+
+	return client, nil
+}
+
+// AuthenticateClient satisfies middleware.ClientAuthenticator using the registered confidential clients.
+func (c *OAuthClient) AuthenticateClient(clientId, secret string) (bool, error) {
+	client, err := c.AuthenticateClientCredentials(clientId, secret)
+	return client != nil, err
+}
+
+// DeleteClient removes the client, returns false if it did not exist.
+func (c *OAuthClient) DeleteClient(clientId string) (bool, error) {
+	result, err := c.clients.DeleteByClientId(clientId)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := result.RowsAffected()
+	return n == 1, err
+}
+
+// CreateAuthorizationCode stores the code for the given lifespan and returns the raw code, only its hash is
+// stored. The CodeHash, IssuedAt and ExpiresAt of the code are set here.
+func (c *OAuthClient) CreateAuthorizationCode(code models.AuthorizationCode, lifespan time.Duration) (string, error) {
+	raw, err := utils.GenerateOpaqueToken(32)
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	code.CodeHash = utils.HashToken(raw)
+	code.IssuedAt = now
+	code.ExpiresAt = now.Add(lifespan)
+
+	if _, err := c.codes.Create(code); err != nil {
+		return "", err
+	}
+
+	return raw, nil
+}
+
+// GetAuthorizationCode returns the models.AuthorizationCode for the raw code, or nil if it does not exist.
+func (c *OAuthClient) GetAuthorizationCode(raw string) (*models.AuthorizationCode, error) {
+	code, err := c.codes.GetByHash(utils.HashToken(raw))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+
+	return code, err
+}
+
+// UseAuthorizationCode marks the code as used. Returns false if the code had already been used, which
+// includes losing a race with a concurrent request presenting the same code.
+func (c *OAuthClient) UseAuthorizationCode(code *models.AuthorizationCode) (bool, error) {
+	result, err := c.codes.MarkUsed(code.Id)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := result.RowsAffected()
+	return n == 1, err
+}
@@ -0,0 +1,79 @@
+package client
+
+import (
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/hashicorp/go-hclog"
+	"github.com/jmoiron/sqlx"
+	"github.com/knockbox/authentication/internal/platform"
+	"github.com/knockbox/authentication/pkg/accessors"
+	"github.com/knockbox/authentication/pkg/models"
+)
+
+// PasskeyClient provides database functionality for models.Passkey
+type PasskeyClient struct {
+	passkeys accessors.PasskeyAccessor
+	hclog.Logger
+}
+
+// NewPasskeyClient creates a new PasskeyClient using the SQLImpl accessors.
+func NewPasskeyClient(db *sqlx.DB, l hclog.Logger) *PasskeyClient {
+	return NewPasskeyClientWithAccessors(platform.PasskeySQLImpl{DB: db, Logger: l}, l)
+}
+
+// NewPasskeyClientWithAccessors creates a new PasskeyClient using the given accessor.
+func NewPasskeyClientWithAccessors(passkeys accessors.PasskeyAccessor, l hclog.Logger) *PasskeyClient {
+	return &PasskeyClient{
+		passkeys: passkeys,
+		Logger:   l,
+	}
+}
+
+// CreatePasskey stores the credential of a verified registration as a passkey of the user.
+func (c *PasskeyClient) CreatePasskey(userId uint, name string, credential *webauthn.Credential) error {
+	_, err := c.passkeys.Create(*models.NewPasskey(userId, name, credential))
+	return err
+}
+
+// GetPasskeys returns every passkey of the user, oldest first.
+func (c *PasskeyClient) GetPasskeys(userId uint) ([]models.Passkey, error) {
+	return c.passkeys.GetByUserId(userId)
+}
+
+// GetWebAuthnUser returns the user along with their passkeys for a WebAuthn ceremony.
+func (c *PasskeyClient) GetWebAuthnUser(user *models.User) (*models.WebAuthnUser, error) {
+	passkeys, err := c.GetPasskeys(user.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.WebAuthnUser{User: user, Passkeys: passkeys}, nil
+}
+
+// UsePasskey records the signature counter and flags of a verified login with the passkey.
+func (c *PasskeyClient) UsePasskey(passkey *models.Passkey, credential *webauthn.Credential) error {
+	passkey.ApplyAssertion(credential)
+
+	_, err := c.passkeys.UpdateUsage(passkey.Id, passkey.SignCount, passkey.Flags)
+	return err
+}
+
+// DeletePasskey removes the passkey of the user. Returns false if the user has no passkey with the credential id.
+func (c *PasskeyClient) DeletePasskey(userId uint, credentialId []byte) (bool, error) {
+	result, err := c.passkeys.DeleteByCredentialId(userId, credentialId)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := result.RowsAffected()
+	return n == 1, err
+}
+
+// DeletePasskeys removes every passkey of the user, returning how many were removed.
+func (c *PasskeyClient) DeletePasskeys(userId uint) (int64, error) {
+	result, err := c.passkeys.DeleteByUserId(userId)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
@@ -0,0 +1,79 @@
+package client
+
+import (
+	"fmt"
+	"github.com/hashicorp/go-hclog"
+	"github.com/jmoiron/sqlx"
+	"github.com/knockbox/authentication/internal/platform"
+	"github.com/knockbox/authentication/pkg/accessors"
+	"github.com/knockbox/authentication/pkg/keyring"
+	"github.com/knockbox/authentication/pkg/models"
+)
+
+// SigningKeyClient provides database functionality for models.SigningKey and satisfies keyring.SharedStore so
+// every instance connected to the same database shares one keyring.KeySet. Key material is sealed by the
+// keyring.Sealer before it is written.
+type SigningKeyClient struct {
+	keys   accessors.SigningKeyAccessor
+	sealer keyring.Sealer
+	hclog.Logger
+}
+
+// NewSigningKeyClient creates a new SigningKeyClient using the SQLImpl accessors.
+func NewSigningKeyClient(db *sqlx.DB, sealer keyring.Sealer, l hclog.Logger) *SigningKeyClient {
+	return &SigningKeyClient{
+		keys: platform.SigningKeySQLImpl{
+			DB:     db,
+			Logger: l,
+		},
+		sealer: sealer,
+		Logger: l,
+	}
+}
+
+func (c *SigningKeyClient) Load() ([]keyring.StoredKey, error) {
+	rows, err := c.keys.GetAll()
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []keyring.StoredKey
+	for _, row := range rows {
+		key, err := keyring.OpenKey(c.sealer, row.KeyData)
+		if err != nil {
+			return nil, fmt.Errorf("kid %s, %w", row.Kid, err)
+		}
+
+		keys = append(keys, keyring.StoredKey{
+			Key:         key,
+			ActiveUntil: row.ActiveUntil,
+			ExpiresAt:   row.ExpiresAt,
+		})
+	}
+
+	return keys, nil
+}
+
+func (c *SigningKeyClient) Save(key keyring.StoredKey) error {
+	data, err := keyring.SealKey(c.sealer, key.Key)
+	if err != nil {
+		return err
+	}
+
+	_, err = c.keys.Upsert(models.SigningKey{
+		Kid:         key.Key.KeyID(),
+		KeyData:     data,
+		ActiveUntil: key.ActiveUntil,
+		ExpiresAt:   key.ExpiresAt,
+	})
+	return err
+}
+
+func (c *SigningKeyClient) Delete(kid string) error {
+	_, err := c.keys.DeleteByKid(kid)
+	return err
+}
+
+func (c *SigningKeyClient) WithLock(fn func() error) error {
+	return c.keys.WithLock(fn)
+}
@@ -0,0 +1,144 @@
+package client
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/hashicorp/go-hclog"
+	"github.com/jmoiron/sqlx"
+	"github.com/knockbox/authentication/internal/platform"
+	"github.com/knockbox/authentication/pkg/accessors"
+	"github.com/knockbox/authentication/pkg/models"
+	"github.com/knockbox/authentication/pkg/utils"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+	"time"
+)
+
+// TokenClient provides database functionality for models.RefreshToken and models.RevokedToken
+type TokenClient struct {
+	refresh accessors.RefreshTokenAccessor
+	revoked accessors.RevokedTokenAccessor
+	hclog.Logger
+}
+
+// NewTokenClient creates a new TokenClient using the SQLImpl accessors.
+func NewTokenClient(db *sqlx.DB, l hclog.Logger) *TokenClient {
+	return NewTokenClientWithAccessors(
+		platform.RefreshTokenSQLImpl{DB: db, Logger: l},
+		platform.RevokedTokenSQLImpl{DB: db, Logger: l},
+		l,
+	)
+}
+
+// NewTokenClientWithAccessors creates a new TokenClient using the given accessors.
+func NewTokenClientWithAccessors(refresh accessors.RefreshTokenAccessor, revoked accessors.RevokedTokenAccessor, l hclog.Logger) *TokenClient {
+	return &TokenClient{
+		refresh: refresh,
+		revoked: revoked,
+		Logger:  l,
+	}
+}
+
+// CreateRefreshToken issues a refresh token for the user to the client, empty for logins to this service, and
+// returns the raw token, only its hash is stored. An empty familyId starts a new family. The scope and amr are
+// carried over to the tokens it is exchanged for.
+func (c *TokenClient) CreateRefreshToken(userId uint, clientId, familyId, scope, amr string, lifespan time.Duration) (string, error) {
+	raw, err := utils.GenerateOpaqueToken(32)
+	if err != nil {
+		return "", err
+	}
+
+	if familyId == "" {
+		familyId = uuid.NewString()
+	}
+
+	now := time.Now()
+	_, err = c.refresh.Create(models.RefreshToken{
+		UserId:    userId,
+		FamilyId:  familyId,
+		ClientId:  clientId,
+		TokenHash: utils.HashToken(raw),
+		Scope:     scope,
+		Amr:       amr,
+		IssuedAt:  now,
+		ExpiresAt: now.Add(lifespan),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return raw, nil
+}
+
+// GetRefreshToken returns the models.RefreshToken for the raw token, or nil if it does not exist.
+func (c *TokenClient) GetRefreshToken(raw string) (*models.RefreshToken, error) {
+	token, err := c.refresh.GetByHash(utils.HashToken(raw))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+
+	return token, err
+}
+
+// UseRefreshToken marks the token as used. Returns false if the token had already been used, which
+// includes losing a race with a concurrent request presenting the same token.
+func (c *TokenClient) UseRefreshToken(token *models.RefreshToken) (bool, error) {
+	result, err := c.refresh.MarkUsed(token.Id)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := result.RowsAffected()
+	return n == 1, err
+}
+
+// RevokeRefreshTokenFamily revokes every token rotated from the same login.
+func (c *TokenClient) RevokeRefreshTokenFamily(familyId string) error {
+	_, err := c.refresh.RevokeByFamilyId(familyId)
+	return err
+}
+
+// RevokeRefreshTokensForUser revokes every refresh token belonging to the user.
+func (c *TokenClient) RevokeRefreshTokensForUser(userId uint) error {
+	_, err := c.refresh.RevokeByUserId(userId)
+	return err
+}
+
+// RevokeToken adds the jwt to the denylist until it would have expired.
+func (c *TokenClient) RevokeToken(token jwt.Token) error {
+	jti := token.JwtID()
+	if jti == "" {
+		return errors.New("token is missing jti")
+	}
+
+	_, err := c.revoked.Create(models.RevokedToken{
+		Jti:       &jti,
+		RevokedAt: time.Now(),
+		ExpiresAt: token.Expiration(),
+	})
+	return err
+}
+
+// RevokeSessions ends every session of the user. Refresh tokens are revoked and every jwt issued to the user
+// up to now is added to the denylist for the given lifespan, the longest any of them could still be valid.
+func (c *TokenClient) RevokeSessions(user *models.User, lifespan time.Duration) error {
+	if err := c.RevokeRefreshTokensForUser(user.Id); err != nil {
+		return err
+	}
+
+	// Tokens carry their iat to the second, so a token issued within the second of the revocation is revoked too.
+	now := time.Now().Truncate(time.Second)
+	subject := user.AccountId.String()
+	_, err := c.revoked.Create(models.RevokedToken{
+		Subject:   &subject,
+		RevokedAt: now,
+		ExpiresAt: now.Add(lifespan),
+	})
+	return err
+}
+
+// IsRevoked determines if the jwt is on the denylist, either by its jti or by its subject.
+func (c *TokenClient) IsRevoked(token jwt.Token) (bool, error) {
+	count, err := c.revoked.CountActive(token.JwtID(), token.Subject(), token.IssuedAt())
+	return count > 0, err
+}
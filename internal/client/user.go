@@ -7,65 +7,235 @@ import (
 	"github.com/jmoiron/sqlx"
 	"github.com/knockbox/authentication/internal/platform"
 	"github.com/knockbox/authentication/pkg/accessors"
+	"github.com/knockbox/authentication/pkg/enums"
 	"github.com/knockbox/authentication/pkg/models"
 	"github.com/knockbox/authentication/pkg/payloads"
+	"github.com/knockbox/authentication/pkg/utils"
+	"time"
 )
 
-// UserClient provides database functionality for models.User, models.UserDetails and models.UserHistory
+// UserClient provides database functionality for models.User, models.UserDetails, models.UserHistory and
+// models.PasswordReset
 type UserClient struct {
 	user    accessors.UserAccessor
 	details accessors.UserDetailsAccessor
 	history accessors.UserHistoryAccessor
+	resets  accessors.PasswordResetAccessor
 	hclog.Logger
 }
 
 // NewUserClient creates a new UserClient using the SQLImpl accessors.
 func NewUserClient(db *sqlx.DB, l hclog.Logger) *UserClient {
+	return NewUserClientWithAccessors(
+		platform.UserSQLImpl{DB: db, Logger: l},
+		platform.UserDetailsSQLImpl{DB: db, Logger: l},
+		platform.UserHistorySQLImpl{DB: db, Logger: l},
+		platform.PasswordResetSQLImpl{DB: db, Logger: l},
+		l,
+	)
+}
+
+// NewUserClientWithAccessors creates a new UserClient using the given accessors.
+func NewUserClientWithAccessors(
+	user accessors.UserAccessor,
+	details accessors.UserDetailsAccessor,
+	history accessors.UserHistoryAccessor,
+	resets accessors.PasswordResetAccessor,
+	l hclog.Logger,
+) *UserClient {
 	return &UserClient{
-		user: platform.UserSQLImpl{
-			DB:     db,
-			Logger: l,
-		},
-		details: platform.UserDetailsSQLImpl{
-			DB:     db,
-			Logger: l,
-		},
-		history: platform.UserHistorySQLImpl{
-			DB:     db,
-			Logger: l,
-		},
-	}
-}
-
-func (c *UserClient) RegisterUser(payload *payloads.UserRegister) error {
+		user:    user,
+		details: details,
+		history: history,
+		resets:  resets,
+		Logger:  l,
+	}
+}
+
+// RegisterUser creates a pending user along with their details.
+func (c *UserClient) RegisterUser(payload *payloads.UserRegister) (*models.User, error) {
 	user := models.NewUser()
 	if err := user.ApplyRegister(payload); err != nil {
-		return err
+		return nil, err
 	}
 
 	result, err := c.user.Create(*user)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	id, err := result.LastInsertId()
 	if err != nil {
-		return err
+		return nil, err
 	}
+	user.Id = uint(id)
 
 	if _, err := c.details.CreateForUser(int(id)); err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// UpdateUser applies the payload to the user, recording a password change in their history.
+func (c *UserClient) UpdateUser(user *models.User, payload *payloads.UserUpdate, origin models.Origin) error {
+	if err := user.ApplyUpdate(payload); err != nil {
+		return err
+	}
+
+	if _, err := c.user.Update(*user); err != nil {
+		return err
+	}
+
+	if payload.Password != nil {
+		return c.RecordHistory(user.Id, origin, enums.UpdatePassword)
+	}
+
+	return nil
+}
+
+// UpdateUserEmail replaces the email of the user with one they confirmed they receive mail at, marking it
+// verified, and records the change in their history.
+func (c *UserClient) UpdateUserEmail(user *models.User, email string, origin models.Origin) error {
+	user.Email = email
+	if _, err := c.user.Update(*user); err != nil {
+		return err
+	}
+
+	if _, err := c.details.UpdateVerified(user.Id, true); err != nil {
+		return err
+	}
+
+	return c.RecordHistory(user.Id, origin, enums.UpdateEmail)
+}
+
+// UpdateUserRole changes the role of the user and records the previous role, the new role and the account_id of
+// the user who changed it in their history. changedBy is empty when the service changed the role itself.
+func (c *UserClient) UpdateUserRole(user *models.User, role enums.UserRole, changedBy string, origin models.Origin) error {
+	previous := user.Role
+	user.Role = role
+	if _, err := c.user.Update(*user); err != nil {
 		return err
 	}
 
+	history := models.UserHistory{
+		UserId:       user.Id,
+		IpAddress:    origin.IpAddress,
+		UserAgent:    origin.UserAgent,
+		Action:       enums.UpdateRole,
+		PreviousRole: &previous,
+		NewRole:      &role,
+	}
+	if changedBy != "" {
+		history.ChangedBy = &changedBy
+	}
+
+	_, err := c.history.Create(history)
 	return err
 }
 
-func (c *UserClient) UpdateUser(user *models.User, payload *payloads.UserUpdate) error {
-	if err := user.ApplyUpdate(payload); err != nil {
+// ResetPassword sets the new password of the user, voids their outstanding password resets and records the
+// change in their history.
+func (c *UserClient) ResetPassword(user *models.User, password string, origin models.Origin) error {
+	pwd, err := utils.GeneratePassword(password)
+	if err != nil {
 		return err
 	}
 
-	_, err := c.user.Update(*user)
+	user.Password = pwd
+	if _, err := c.user.Update(*user); err != nil {
+		return err
+	}
+
+	if _, err := c.resets.MarkUsedByUserId(user.Id); err != nil {
+		return err
+	}
+
+	return c.RecordHistory(user.Id, origin, enums.UpdatePassword)
+}
+
+// CreatePasswordReset issues a password reset for the user and returns the raw token, only its hash is stored.
+func (c *UserClient) CreatePasswordReset(userId uint, lifespan time.Duration) (string, error) {
+	raw, err := utils.GenerateOpaqueToken(32)
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	_, err = c.resets.Create(models.PasswordReset{
+		UserId:    userId,
+		TokenHash: utils.HashToken(raw),
+		IssuedAt:  now,
+		ExpiresAt: now.Add(lifespan),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return raw, nil
+}
+
+// GetPasswordReset returns the models.PasswordReset for the raw token, or nil if it does not exist.
+func (c *UserClient) GetPasswordReset(raw string) (*models.PasswordReset, error) {
+	reset, err := c.resets.GetByHash(utils.HashToken(raw))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+
+	return reset, err
+}
+
+// UsePasswordReset marks the reset as used. Returns false if the reset had already been used, which includes
+// losing a race with a concurrent request presenting the same token.
+func (c *UserClient) UsePasswordReset(reset *models.PasswordReset) (bool, error) {
+	result, err := c.resets.MarkUsed(reset.Id)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := result.RowsAffected()
+	return n == 1, err
+}
+
+// VerifyUserEmail marks the email of the user as verified, promoting a pending user to enums.User, and records
+// the verification in their history. Other roles are left unchanged.
+func (c *UserClient) VerifyUserEmail(user *models.User, origin models.Origin) error {
+	if _, err := c.details.UpdateVerified(user.Id, true); err != nil {
+		return err
+	}
+
+	if user.Role == enums.Pending {
+		user.Role = enums.User
+		if _, err := c.user.Update(*user); err != nil {
+			return err
+		}
+	}
+
+	return c.RecordHistory(user.Id, origin, enums.VerifyEmail)
+}
+
+// GetUserHistory returns a page of the history of the user, most recent first.
+func (c *UserClient) GetUserHistory(userId uint, page *models.Page) ([]models.UserHistory, error) {
+	if page == nil || page.Limit == 0 {
+		page = models.DefaultPage()
+	}
+
+	return c.history.GetByUserId(int(userId), *page)
+}
+
+// CountRecentHistory counts how many times the user performed the action within the window.
+func (c *UserClient) CountRecentHistory(userId uint, action enums.UserAction, window time.Duration) (int, error) {
+	return c.history.CountRecent(userId, action, window)
+}
+
+// RecordHistory adds the action to the history of the user.
+func (c *UserClient) RecordHistory(userId uint, origin models.Origin, action enums.UserAction) error {
+	_, err := c.history.Create(models.UserHistory{
+		UserId:    userId,
+		IpAddress: origin.IpAddress,
+		UserAgent: origin.UserAgent,
+		Action:    action,
+	})
 	return err
 }
 
@@ -96,6 +266,15 @@ func (c *UserClient) GetUserByUsername(username string) (*models.User, error) {
 	return user, err
 }
 
+func (c *UserClient) GetUserByEmail(email string) (*models.User, error) {
+	user, err := c.user.GetByEmail(email)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+
+	return user, err
+}
+
 func (c *UserClient) GetUsersLikeUsername(username string, page *models.Page) ([]models.User, error) {
 	if page == nil || page.Limit == 0 {
 		page = models.DefaultPage()
@@ -109,8 +288,21 @@ func (c *UserClient) DeleteById(id int) error {
 	return err
 }
 
-func (c *UserClient) UpdateUserDetails(userDetails *models.UserDetails, payload *payloads.UserDetailsUpdate) error {
+func (c *UserClient) GetUserDetailsByUserId(userId uint) (*models.UserDetails, error) {
+	details, err := c.details.GetByUserId(userId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+
+	return details, err
+}
+
+// UpdateUserDetails applies the payload to the details of the user, and records the change in their history.
+func (c *UserClient) UpdateUserDetails(userDetails *models.UserDetails, payload *payloads.UserDetailsUpdate, origin models.Origin) error {
 	userDetails.ApplyUpdate(payload)
-	_, err := c.details.Update(*userDetails)
-	return err
+	if _, err := c.details.Update(*userDetails); err != nil {
+		return err
+	}
+
+	return c.RecordHistory(userDetails.UserId, origin, enums.UpdateProfile)
 }
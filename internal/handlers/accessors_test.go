@@ -0,0 +1,457 @@
+package handlers
+
+import (
+	"database/sql"
+	"github.com/hashicorp/go-hclog"
+	"github.com/knockbox/authentication/internal/client"
+	"github.com/knockbox/authentication/pkg/enums"
+	"github.com/knockbox/authentication/pkg/keyring"
+	"github.com/knockbox/authentication/pkg/mail"
+	"github.com/knockbox/authentication/pkg/models"
+	"github.com/knockbox/authentication/pkg/utils"
+	"testing"
+	"time"
+)
+
+// memoryResult is the sql.Result of an in-memory query.
+type memoryResult struct {
+	id       int64
+	affected int64
+}
+
+func (r memoryResult) LastInsertId() (int64, error) { return r.id, nil }
+func (r memoryResult) RowsAffected() (int64, error) { return r.affected, nil }
+
+// memoryUsers is an in-memory accessors.UserAccessor.
+type memoryUsers map[uint]*models.User
+
+func (m memoryUsers) Create(user models.User) (sql.Result, error) {
+	user.Id = uint(len(m) + 1)
+	m[user.Id] = &user
+	return memoryResult{id: int64(user.Id), affected: 1}, nil
+}
+
+func (m memoryUsers) Update(user models.User) (sql.Result, error) {
+	m[user.Id] = &user
+	return memoryResult{affected: 1}, nil
+}
+
+func (m memoryUsers) GetById(id int) (*models.User, error) {
+	return m.find(func(user *models.User) bool { return user.Id == uint(id) })
+}
+
+func (m memoryUsers) GetByAccountId(accountId string) (*models.User, error) {
+	return m.find(func(user *models.User) bool { return user.AccountId.String() == accountId })
+}
+
+func (m memoryUsers) GetByUsername(username string) (*models.User, error) {
+	return m.find(func(user *models.User) bool { return user.Username == username })
+}
+
+func (m memoryUsers) GetByEmail(email string) (*models.User, error) {
+	return m.find(func(user *models.User) bool { return user.Email == email })
+}
+
+func (m memoryUsers) GetLikeUsername(string, models.Page) ([]models.User, error) {
+	return nil, nil
+}
+
+func (m memoryUsers) DeleteById(id int) (sql.Result, error) {
+	delete(m, uint(id))
+	return memoryResult{affected: 1}, nil
+}
+
+func (m memoryUsers) find(match func(user *models.User) bool) (*models.User, error) {
+	for _, user := range m {
+		if match(user) {
+			found := *user
+			return &found, nil
+		}
+	}
+
+	return nil, sql.ErrNoRows
+}
+
+// memoryDetails is an in-memory accessors.UserDetailsAccessor keyed by user id.
+type memoryDetails map[uint]*models.UserDetails
+
+func (m memoryDetails) CreateForUser(userId int) (sql.Result, error) {
+	m[uint(userId)] = &models.UserDetails{Id: uint(userId), UserId: uint(userId)}
+	return memoryResult{id: int64(userId), affected: 1}, nil
+}
+
+func (m memoryDetails) Update(details models.UserDetails) (sql.Result, error) {
+	m[details.UserId] = &details
+	return memoryResult{affected: 1}, nil
+}
+
+func (m memoryDetails) UpdateVerified(userId uint, verified bool) (sql.Result, error) {
+	m[userId].Verified = verified
+	return memoryResult{affected: 1}, nil
+}
+
+func (m memoryDetails) GetByUserId(userId uint) (*models.UserDetails, error) {
+	details, ok := m[userId]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+
+	found := *details
+	return &found, nil
+}
+
+// memoryHistory is an in-memory accessors.UserHistoryAccessor, every entry counts as recent.
+type memoryHistory struct {
+	entries []models.UserHistory
+}
+
+func (m *memoryHistory) Create(history models.UserHistory) (sql.Result, error) {
+	m.entries = append(m.entries, history)
+	return memoryResult{id: int64(len(m.entries)), affected: 1}, nil
+}
+
+func (m *memoryHistory) GetByUserId(int, models.Page) ([]models.UserHistory, error) {
+	return m.entries, nil
+}
+
+func (m *memoryHistory) CountRecent(userId uint, action enums.UserAction, _ time.Duration) (int, error) {
+	count := 0
+	for _, entry := range m.entries {
+		if entry.UserId == userId && entry.Action == action {
+			count++
+		}
+	}
+
+	return count, nil
+}
+
+// actions returns the recorded actions in order.
+func (m *memoryHistory) actions() []enums.UserAction {
+	actions := make([]enums.UserAction, len(m.entries))
+	for i, entry := range m.entries {
+		actions[i] = entry.Action
+	}
+
+	return actions
+}
+
+// memoryResets is an in-memory accessors.PasswordResetAccessor.
+type memoryResets map[uint]*models.PasswordReset
+
+func (m memoryResets) Create(reset models.PasswordReset) (sql.Result, error) {
+	reset.Id = uint(len(m) + 1)
+	m[reset.Id] = &reset
+	return memoryResult{id: int64(reset.Id), affected: 1}, nil
+}
+
+func (m memoryResets) GetByHash(hash string) (*models.PasswordReset, error) {
+	for _, reset := range m {
+		if reset.TokenHash == hash {
+			found := *reset
+			return &found, nil
+		}
+	}
+
+	return nil, sql.ErrNoRows
+}
+
+func (m memoryResets) MarkUsed(id uint) (sql.Result, error) {
+	reset, ok := m[id]
+	if !ok || reset.UsedAt != nil {
+		return memoryResult{}, nil
+	}
+
+	now := time.Now()
+	reset.UsedAt = &now
+	return memoryResult{affected: 1}, nil
+}
+
+func (m memoryResets) MarkUsedByUserId(userId uint) (sql.Result, error) {
+	var affected int64
+	for _, reset := range m {
+		if reset.UserId == userId && reset.UsedAt == nil {
+			now := time.Now()
+			reset.UsedAt = &now
+			affected++
+		}
+	}
+
+	return memoryResult{affected: affected}, nil
+}
+
+// memoryRefreshTokens is an in-memory accessors.RefreshTokenAccessor.
+type memoryRefreshTokens map[uint]*models.RefreshToken
+
+func (m memoryRefreshTokens) Create(token models.RefreshToken) (sql.Result, error) {
+	token.Id = uint(len(m) + 1)
+	m[token.Id] = &token
+	return memoryResult{id: int64(token.Id), affected: 1}, nil
+}
+
+func (m memoryRefreshTokens) GetByHash(hash string) (*models.RefreshToken, error) {
+	for _, token := range m {
+		if token.TokenHash == hash {
+			found := *token
+			return &found, nil
+		}
+	}
+
+	return nil, sql.ErrNoRows
+}
+
+func (m memoryRefreshTokens) MarkUsed(id uint) (sql.Result, error) {
+	now := time.Now()
+	m[id].UsedAt = &now
+	return memoryResult{affected: 1}, nil
+}
+
+func (m memoryRefreshTokens) RevokeByFamilyId(familyId string) (sql.Result, error) {
+	return m.revoke(func(token *models.RefreshToken) bool { return token.FamilyId == familyId })
+}
+
+func (m memoryRefreshTokens) RevokeByUserId(userId uint) (sql.Result, error) {
+	return m.revoke(func(token *models.RefreshToken) bool { return token.UserId == userId })
+}
+
+func (m memoryRefreshTokens) revoke(match func(token *models.RefreshToken) bool) (sql.Result, error) {
+	var affected int64
+	for _, token := range m {
+		if match(token) && token.RevokedAt == nil {
+			now := time.Now()
+			token.RevokedAt = &now
+			affected++
+		}
+	}
+
+	return memoryResult{affected: affected}, nil
+}
+
+// memoryRevokedTokens is an in-memory accessors.RevokedTokenAccessor.
+type memoryRevokedTokens struct {
+	entries []models.RevokedToken
+}
+
+func (m *memoryRevokedTokens) Create(token models.RevokedToken) (sql.Result, error) {
+	m.entries = append(m.entries, token)
+	return memoryResult{id: int64(len(m.entries)), affected: 1}, nil
+}
+
+func (m *memoryRevokedTokens) CountActive(jti, subject string, issuedAt time.Time) (int, error) {
+	count := 0
+	for _, entry := range m.entries {
+		if (entry.Jti != nil && *entry.Jti == jti) || (entry.Subject != nil && *entry.Subject == subject && !entry.RevokedAt.Before(issuedAt)) {
+			count++
+		}
+	}
+
+	return count, nil
+}
+
+// memoryPasskeys is an in-memory accessors.PasskeyAccessor.
+type memoryPasskeys map[uint]*models.Passkey
+
+func (m memoryPasskeys) Create(passkey models.Passkey) (sql.Result, error) {
+	passkey.Id = uint(len(m) + 1)
+	m[passkey.Id] = &passkey
+	return memoryResult{id: int64(passkey.Id), affected: 1}, nil
+}
+
+func (m memoryPasskeys) GetByUserId(userId uint) ([]models.Passkey, error) {
+	var passkeys []models.Passkey
+	for _, passkey := range m {
+		if passkey.UserId == userId {
+			passkeys = append(passkeys, *passkey)
+		}
+	}
+
+	return passkeys, nil
+}
+
+func (m memoryPasskeys) UpdateUsage(uint, uint32, uint8) (sql.Result, error) {
+	return memoryResult{affected: 1}, nil
+}
+
+func (m memoryPasskeys) DeleteByCredentialId(uint, []byte) (sql.Result, error) {
+	return memoryResult{}, nil
+}
+
+func (m memoryPasskeys) DeleteByUserId(userId uint) (sql.Result, error) {
+	var affected int64
+	for id, passkey := range m {
+		if passkey.UserId == userId {
+			delete(m, id)
+			affected++
+		}
+	}
+
+	return memoryResult{affected: affected}, nil
+}
+
+// memoryLoginFailures is an in-memory accessors.LoginFailureAccessor keyed by kind and subject.
+type memoryLoginFailures map[string]*models.LoginFailure
+
+func (m memoryLoginFailures) Apply(kind, subject string, since time.Time, apply func(failure *models.LoginFailure) bool) (*models.LoginFailure, error) {
+	stored, ok := m[kind+":"+subject]
+	if !ok {
+		return nil, nil
+	}
+
+	failure := *stored
+	if failure.LastFailedAt.Before(since) {
+		failure.Failures = 0
+		failure.Lockouts = 0
+	}
+
+	if apply(&failure) {
+		*stored = failure
+	}
+
+	return &failure, nil
+}
+
+func (m memoryLoginFailures) ApplyOrCreate(kind, subject string, now, since time.Time, apply func(failure *models.LoginFailure) bool) (*models.LoginFailure, error) {
+	if _, ok := m[kind+":"+subject]; !ok {
+		m[kind+":"+subject] = &models.LoginFailure{Id: uint(len(m) + 1), Kind: kind, Subject: subject, LastFailedAt: now}
+	}
+
+	return m.Apply(kind, subject, since, apply)
+}
+
+func (m memoryLoginFailures) DeleteBySubject(kind, subject string) (sql.Result, error) {
+	delete(m, kind+":"+subject)
+	return memoryResult{affected: 1}, nil
+}
+
+func (m memoryLoginFailures) DeleteStale(now, since time.Time) (sql.Result, error) {
+	var affected int64
+	for key, failure := range m {
+		if failure.LastFailedAt.Before(since) && (failure.RetryAt == nil || failure.RetryAt.Before(now)) {
+			delete(m, key)
+			affected++
+		}
+	}
+
+	return memoryResult{affected: affected}, nil
+}
+
+// memoryOAuthClients is an in-memory accessors.OAuthClientAccessor keyed by client_id.
+type memoryOAuthClients map[string]*models.OAuthClient
+
+func (m memoryOAuthClients) Create(client models.OAuthClient) (sql.Result, error) {
+	client.Id = uint(len(m) + 1)
+	m[client.ClientId] = &client
+	return memoryResult{id: int64(client.Id), affected: 1}, nil
+}
+
+func (m memoryOAuthClients) GetByClientId(clientId string) (*models.OAuthClient, error) {
+	client, ok := m[clientId]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+
+	found := *client
+	return &found, nil
+}
+
+func (m memoryOAuthClients) DeleteByClientId(clientId string) (sql.Result, error) {
+	delete(m, clientId)
+	return memoryResult{affected: 1}, nil
+}
+
+// memorySender is a mail.Sender keeping the messages it was asked to send.
+type memorySender struct {
+	messages []mail.Message
+}
+
+func (s *memorySender) Send(message mail.Message) error {
+	s.messages = append(s.messages, message)
+	return nil
+}
+
+// memoryStore holds the in-memory accessors behind a User handler built by newMemoryUser.
+type memoryStore struct {
+	users         memoryUsers
+	details       memoryDetails
+	history       *memoryHistory
+	resets        memoryResets
+	refreshTokens memoryRefreshTokens
+	revokedTokens *memoryRevokedTokens
+	passkeys      memoryPasskeys
+	failures      memoryLoginFailures
+	mail          *memorySender
+}
+
+// newMemoryUser returns a User handler backed by in-memory accessors, along with the accessors.
+func newMemoryUser(t *testing.T) (*User, *memoryStore) {
+	keyset, _ := keyring.NewSet(1000, 500, hclog.Default())
+	keyset.SetCurveTypes(keyring.P256)
+	if err := keyset.Load(1); err != nil {
+		t.Fatal(err)
+	}
+
+	store := &memoryStore{
+		users:         memoryUsers{},
+		details:       memoryDetails{},
+		history:       &memoryHistory{},
+		resets:        memoryResets{},
+		refreshTokens: memoryRefreshTokens{},
+		revokedTokens: &memoryRevokedTokens{},
+		passkeys:      memoryPasskeys{},
+		failures:      memoryLoginFailures{},
+		mail:          &memorySender{},
+	}
+
+	l := hclog.NewNullLogger()
+	users := client.NewUserClientWithAccessors(store.users, store.details, store.history, store.resets, l)
+	tokens := client.NewTokenClientWithAccessors(store.refreshTokens, store.revokedTokens, l)
+	config := &utils.TokenConfig{Issuer: "https://auth.knockbox.io", Audience: []string{"knockbox"}, RefreshLifespan: time.Hour}
+
+	// Without a back-off, wrong passwords only hold off further attempts once they lock the account.
+	lockout := &utils.LockoutConfig{
+		AccountThreshold: 3,
+		IpThreshold:      20,
+		Duration:         15 * time.Minute,
+		MaxLockouts:      3,
+		Window:           24 * time.Hour,
+	}
+
+	return &User{
+		Logger:   l,
+		KeySet:   keyset,
+		c:        users,
+		passkeys: client.NewPasskeyClientWithAccessors(store.passkeys, l),
+		mail:     store.mail,
+		throttle: client.NewLoginThrottleClientWithAccessors(store.failures, lockout, l),
+		account: &utils.AccountConfig{
+			VerificationURL:       "https://knockbox.io/verify",
+			VerificationLifespan:  time.Hour,
+			ResendInterval:        time.Minute,
+			PasswordResetURL:      "https://knockbox.io/reset",
+			PasswordResetLifespan: time.Hour,
+		},
+		issuer: newIssuer(keyset, config, users, tokens, l),
+	}, store
+}
+
+// addUser stores a verified user with the password along with their details.
+func (s *memoryStore) addUser(t *testing.T, password string) *models.User {
+	pwd, err := utils.GeneratePassword(password)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	user := models.NewUser()
+	user.Username = "user"
+	user.Email = "user@knockbox.io"
+	user.Password = pwd
+	user.Role = enums.User
+
+	result, _ := s.users.Create(*user)
+	id, _ := result.LastInsertId()
+	user.Id = uint(id)
+
+	_, _ = s.details.CreateForUser(int(id))
+	s.details[user.Id].Verified = true
+
+	return user
+}
@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"encoding/json"
+	"github.com/gorilla/mux"
+	"github.com/hashicorp/go-hclog"
+	"github.com/knockbox/authentication/internal/client"
+	"github.com/knockbox/authentication/pkg/enums"
+	"github.com/knockbox/authentication/pkg/keyring"
+	"github.com/knockbox/authentication/pkg/middleware"
+	"github.com/knockbox/authentication/pkg/payloads"
+	"github.com/knockbox/authentication/pkg/responses"
+	"github.com/knockbox/authentication/pkg/utils"
+	"net/http"
+)
+
+// Clients provides administration of the registered OAuth clients.
+type Clients struct {
+	hclog.Logger
+	*keyring.KeySet
+	oauth  *client.OAuthClient
+	tokens *client.TokenClient
+}
+
+// Register creates a client with the given redirect URIs, the secret of a confidential client is only returned
+// here.
+func (c *Clients) Register(w http.ResponseWriter, r *http.Request) {
+	payload := &payloads.OAuthClientRegister{}
+	if utils.DecodeAndValidateStruct(w, r, payload) {
+		return
+	}
+
+	registered, secret, err := c.oauth.RegisterClient(payload)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		responses.NewGenericError("failed to register client").Encode(w)
+
+		c.Error("failed to register client", "err", err)
+		return
+	}
+
+	c.Info("client registered by admin", "client_id", registered.ClientId, "confidential", registered.IsConfidential())
+
+	dto := registered.DTO()
+	dto.ClientSecret = secret
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(dto)
+}
+
+// GetByClientId returns a client by its client_id.
+func (c *Clients) GetByClientId(w http.ResponseWriter, r *http.Request) {
+	clientId, ok := mux.Vars(r)["client_id"]
+	if !ok {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		responses.NewGenericError("client_id was not provided").Encode(w)
+		return
+	}
+
+	registered, err := c.oauth.GetClient(clientId)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		c.Error("failed to get client by client_id", "err", err)
+		return
+	}
+
+	if registered == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(registered.DTO())
+}
+
+// Delete removes a client, outstanding authorization codes for it can no longer be exchanged.
+func (c *Clients) Delete(w http.ResponseWriter, r *http.Request) {
+	clientId, ok := mux.Vars(r)["client_id"]
+	if !ok {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		responses.NewGenericError("client_id was not provided").Encode(w)
+		return
+	}
+
+	deleted, err := c.oauth.DeleteClient(clientId)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		c.Error("failed to delete client", "err", err)
+		return
+	}
+
+	if !deleted {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	c.Info("client deleted by admin", "client_id", clientId)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (c *Clients) Route(r *mux.Router) {
+	bearer := middleware.UseLocalBearerToken(c.Logger, c.KeySet, c.tokens)
+
+	adminRouter := r.PathPrefix("/admin/clients").Subrouter()
+	adminRouter.Use(
+		bearer.Middleware,
+		middleware.RequireRole(c.Logger, enums.Admin).Middleware,
+		middleware.RequireScope(c.Logger, enums.ScopeAdminClients).Middleware,
+	)
+	adminRouter.HandleFunc("", c.Register).Methods(http.MethodPost)
+	adminRouter.HandleFunc("/{client_id}", c.GetByClientId).Methods(http.MethodGet)
+	adminRouter.HandleFunc("/{client_id}", c.Delete).Methods(http.MethodDelete)
+}
+
+func NewClients(l hclog.Logger, ks *keyring.KeySet) *Clients {
+	db, err := utils.MySQLConnection()
+	if err != nil {
+		panic(err)
+	}
+
+	return &Clients{
+		Logger: l,
+		KeySet: ks,
+		oauth:  client.NewOAuthClient(db, l),
+		tokens: client.NewTokenClient(db, l),
+	}
+}
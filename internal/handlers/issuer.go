@@ -0,0 +1,173 @@
+package handlers
+
+import (
+	"errors"
+	"github.com/google/uuid"
+	"github.com/hashicorp/go-hclog"
+	"github.com/knockbox/authentication/internal/client"
+	"github.com/knockbox/authentication/pkg/keyring"
+	"github.com/knockbox/authentication/pkg/models"
+	"github.com/knockbox/authentication/pkg/responses"
+	"github.com/knockbox/authentication/pkg/utils"
+)
+
+var (
+	// errInvalidGrant is returned when a presented grant is unknown, expired, reused or belongs to a forbidden user.
+	errInvalidGrant = errors.New("invalid grant")
+
+	// errInvalidClient is returned when a client fails to authenticate.
+	errInvalidClient = errors.New("invalid client")
+
+	// errInvalidScope is returned when a client requests a scope it is not allowed.
+	errInvalidScope = errors.New("invalid scope")
+)
+
+// Authentication method references of RFC 8176, space separated as stored with codes and refresh tokens.
+const (
+	amrPassword     = "pwd"
+	amrTOTP         = "pwd otp mfa"
+	amrRecoveryCode = "pwd mfa"
+	amrPasskey      = "hwk user mfa"
+)
+
+// issuer creates the access and refresh tokens handed to a client.
+type issuer struct {
+	ks     *keyring.KeySet
+	config *utils.TokenConfig
+	users  *client.UserClient
+	tokens *client.TokenClient
+	l      hclog.Logger
+}
+
+// issue signs an access token for the user along with a refresh token in the given family, an empty familyId
+// begins a new family. The family is the session and is carried by the access token as the sid claim. The
+// refresh token is bound to the OAuth client, empty for logins to this service. The requested scope is narrowed
+// to what the role of the user allows, errInvalidScope is returned if it cannot be. The amr names how the user
+// authenticated and is kept by the family.
+func (i *issuer) issue(user *models.User, clientId, familyId, requested, amr string) (*responses.Token, error) {
+	if familyId == "" {
+		familyId = uuid.NewString()
+	}
+
+	scope, ok := user.GrantScopes(requested)
+	if !ok {
+		return nil, errInvalidScope
+	}
+
+	token, err := user.CreateToken(i.config, i.ks.GetTokenDuration(), scope, amr)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := token.Set("sid", familyId); err != nil {
+		return nil, err
+	}
+
+	bs, err := i.ks.Sign(token, keyring.TypeAccessToken)
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken, err := i.tokens.CreateRefreshToken(user.Id, clientId, familyId, scope, amr, i.config.RefreshLifespan)
+	if err != nil {
+		return nil, err
+	}
+
+	return responses.NewBearerToken(bs, int(i.ks.GetTokenDuration().Seconds())).
+		WithRefreshToken(refreshToken).
+		WithScope(scope), nil
+}
+
+// issueForClient signs an access token for the client acting on its own behalf. No refresh token is issued as
+// the client can always authenticate again.
+func (i *issuer) issueForClient(c *models.OAuthClient, scope string) (*responses.Token, error) {
+	token, err := c.CreateToken(i.config, i.ks.GetTokenDuration(), scope)
+	if err != nil {
+		return nil, err
+	}
+
+	bs, err := i.ks.Sign(token, keyring.TypeAccessToken)
+	if err != nil {
+		return nil, err
+	}
+
+	return responses.NewBearerToken(bs, int(i.ks.GetTokenDuration().Seconds())).WithScope(scope), nil
+}
+
+// issueIdToken signs an ID token for the user who authenticated to the client of the code.
+func (i *issuer) issueIdToken(user *models.User, code *models.AuthorizationCode) ([]byte, error) {
+	details, err := i.users.GetUserDetailsByUserId(user.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := user.CreateIDToken(i.config, i.ks.GetTokenDuration(), code, details)
+	if err != nil {
+		return nil, err
+	}
+
+	return i.ks.Sign(token, keyring.TypeIDToken)
+}
+
+// refresh exchanges a refresh token for a new access and refresh token in the same family and scope. The token
+// must have been issued to the client, empty for logins to this service, which must already have authenticated.
+// Presenting a refresh token that has already been exchanged revokes every token in its family. If the role of
+// the user no longer allows the scope the grant is invalid.
+func (i *issuer) refresh(raw, clientId string) (*responses.Token, error) {
+	refreshToken, err := i.tokens.GetRefreshToken(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	if refreshToken == nil || refreshToken.RevokedAt != nil || refreshToken.IsExpired() {
+		return nil, errInvalidGrant
+	}
+
+	if refreshToken.ClientId != clientId {
+		i.l.Warn("refresh token presented by another client", "family_id", refreshToken.FamilyId, "client_id", clientId)
+		return nil, errInvalidGrant
+	}
+
+	// A token that was already used, or loses the race to be used, is being replayed.
+	fresh := refreshToken.UsedAt == nil
+	if fresh {
+		if fresh, err = i.tokens.UseRefreshToken(refreshToken); err != nil {
+			return nil, err
+		}
+	}
+
+	if !fresh {
+		i.l.Warn("refresh token reused, revoking family", "family_id", refreshToken.FamilyId, "user_id", refreshToken.UserId)
+		if err := i.tokens.RevokeRefreshTokenFamily(refreshToken.FamilyId); err != nil {
+			i.l.Error("failed to revoke refresh token family", "family_id", refreshToken.FamilyId, "err", err)
+		}
+
+		return nil, errInvalidGrant
+	}
+
+	user, err := i.users.GetUserById(int(refreshToken.UserId))
+	if err != nil {
+		return nil, err
+	}
+
+	if user == nil || user.Role.IsForbidden() {
+		return nil, errInvalidGrant
+	}
+
+	token, err := i.issue(user, refreshToken.ClientId, refreshToken.FamilyId, refreshToken.Scope, refreshToken.Amr)
+	if errors.Is(err, errInvalidScope) {
+		return nil, errInvalidGrant
+	}
+
+	return token, err
+}
+
+func newIssuer(ks *keyring.KeySet, config *utils.TokenConfig, users *client.UserClient, tokens *client.TokenClient, l hclog.Logger) *issuer {
+	return &issuer{
+		ks:     ks,
+		config: config,
+		users:  users,
+		tokens: tokens,
+		l:      l,
+	}
+}
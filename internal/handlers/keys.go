@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"encoding/json"
+	"github.com/gorilla/mux"
+	"github.com/hashicorp/go-hclog"
+	"github.com/knockbox/authentication/internal/client"
+	"github.com/knockbox/authentication/pkg/enums"
+	"github.com/knockbox/authentication/pkg/keyring"
+	"github.com/knockbox/authentication/pkg/middleware"
+	"github.com/knockbox/authentication/pkg/payloads"
+	"github.com/knockbox/authentication/pkg/responses"
+	"github.com/knockbox/authentication/pkg/utils"
+	"net/http"
+)
+
+// Keys provides administration of the keyring.KeySet.
+type Keys struct {
+	hclog.Logger
+	*keyring.KeySet
+	tokens *client.TokenClient
+}
+
+// GetKeys returns the state of every key in the set. The pool size is not kept in the keyring.Store, it is that
+// of the instance serving the request and is reported as such by pool_size_scope.
+func (k *Keys) GetKeys(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"pool_size":       k.GetPoolSize(),
+		"pool_size_scope": "instance",
+		"keys":            k.GetKeyStates(),
+	})
+}
+
+// Rotate moves the active keys out of the signing pool and replaces them immediately.
+func (k *Keys) Rotate(w http.ResponseWriter, r *http.Request) {
+	if err := k.KeySet.Rotate(); err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		responses.NewGenericError("failed to rotate keys").Encode(w)
+
+		k.Error("failed to rotate keys", "err", err)
+		return
+	}
+
+	k.Info("keys rotated by admin")
+	k.GetKeys(w, r)
+}
+
+// Revoke removes a key from the set, jwt(s) signed by the key will no longer verify.
+func (k *Keys) Revoke(w http.ResponseWriter, r *http.Request) {
+	kid, ok := mux.Vars(r)["kid"]
+	if !ok {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		responses.NewGenericError("kid was not provided").Encode(w)
+		return
+	}
+
+	if !k.RevokeKeyById(kid) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	k.Info("key revoked by admin", "kid", kid)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// UpdatePool changes the number of keys kept active by the instance serving the request. Other instances keep
+// their own pool size, and it is reset when the instance restarts.
+func (k *Keys) UpdatePool(w http.ResponseWriter, r *http.Request) {
+	payload := &payloads.KeyPoolUpdate{}
+	if utils.DecodeAndValidateStruct(w, r, payload) {
+		return
+	}
+
+	if err := k.SetPoolSize(payload.Size); err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		responses.NewGenericError("failed to resize key pool").Encode(w)
+
+		k.Error("failed to resize key pool", "err", err)
+		return
+	}
+
+	k.Info("key pool resized by admin", "size", payload.Size)
+	k.GetKeys(w, r)
+}
+
+func (k *Keys) Route(r *mux.Router) {
+	bearer := middleware.UseLocalBearerToken(k.Logger, k.KeySet, k.tokens)
+
+	adminRouter := r.PathPrefix("/admin/keys").Subrouter()
+	adminRouter.Use(
+		bearer.Middleware,
+		middleware.RequireRole(k.Logger, enums.Admin).Middleware,
+		middleware.RequireScope(k.Logger, enums.ScopeAdminKeys).Middleware,
+	)
+	adminRouter.HandleFunc("", k.GetKeys).Methods(http.MethodGet)
+	adminRouter.HandleFunc("/rotate", k.Rotate).Methods(http.MethodPost)
+	adminRouter.HandleFunc("/pool", k.UpdatePool).Methods(http.MethodPut)
+	adminRouter.HandleFunc("/{kid}", k.Revoke).Methods(http.MethodDelete)
+}
+
+func NewKeys(l hclog.Logger, ks *keyring.KeySet) *Keys {
+	db, err := utils.MySQLConnection()
+	if err != nil {
+		panic(err)
+	}
+
+	return &Keys{
+		Logger: l,
+		KeySet: ks,
+		tokens: client.NewTokenClient(db, l),
+	}
+}
@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"github.com/gorilla/mux"
+	"github.com/hashicorp/go-hclog"
+	"github.com/knockbox/authentication/pkg/keyring"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestKeys_GetKeys(t *testing.T) {
+	keyset, _ := keyring.NewSet(1000, 500, hclog.Default())
+	keyset.SetCurveTypes(keyring.P256)
+	if err := keyset.Load(2); err != nil {
+		t.Fatal(err)
+	}
+
+	req, err := http.NewRequest(http.MethodGet, "/admin/keys", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc((&Keys{Logger: hclog.Default(), KeySet: keyset}).GetKeys).ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), `"pool_size":2`)
+	assert.Contains(t, rr.Body.String(), `"pool_size_scope":"instance"`)
+}
+
+func TestKeys_Revoke(t *testing.T) {
+	keyset, _ := keyring.NewSet(1000, 500, hclog.Default())
+	keyset.SetCurveTypes(keyring.P256)
+	if err := keyset.Load(1); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		kid  string
+		want int
+	}{
+		{
+			name: "DELETE /admin/keys/{kid} known",
+			kid:  keyset.GetRandomKey().KeyID(),
+			want: http.StatusNoContent,
+		},
+		{
+			name: "DELETE /admin/keys/{kid} unknown",
+			kid:  "unknown",
+			want: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodDelete, "/admin/keys/"+tt.kid, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req = mux.SetURLVars(req, map[string]string{"kid": tt.kid})
+
+			rr := httptest.NewRecorder()
+			handler := http.HandlerFunc((&Keys{Logger: hclog.Default(), KeySet: keyset}).Revoke)
+
+			handler.ServeHTTP(rr, req)
+
+			got := rr.Code
+			assert.Truef(t, got == tt.want, "got status %v, wanted %v", got, tt.want)
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"github.com/hashicorp/go-hclog"
+	"github.com/knockbox/authentication/internal/client"
+	"github.com/knockbox/authentication/pkg/enums"
+	"github.com/knockbox/authentication/pkg/models"
+	"github.com/knockbox/authentication/pkg/responses"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// tooManyAttempts is the error shown while a login is throttled.
+const tooManyAttempts = "too many failed attempts, try again later"
+
+// recordLoginFailure records the failed login in the history of the user, nil if unknown, and counts the attempt
+// against them and the IP address of the request. A lock is recorded in their history too, and locking a user too many
+// times in a row assigns them enums.Locked until an admin unlocks them. Staff are only ever locked temporarily,
+// so failed guesses cannot lock them out for good. Failing to record it does not fail the request.
+func recordLoginFailure(c *client.UserClient, t *client.LoginThrottleClient, l hclog.Logger, r *http.Request, user *models.User, attempt *client.LoginAttempt) {
+	if user != nil {
+		recordHistory(c, l, user.Id, r, enums.LoginFailed)
+	}
+
+	failure, err := t.Fail(attempt)
+	if err != nil {
+		l.Error("failed to record login failure", "err", err)
+		return
+	}
+
+	if failure == nil {
+		return
+	}
+
+	l.Warn("account locked after failed logins", "account_id", user.AccountId, "lockouts", failure.Lockouts)
+	recordHistory(c, l, user.Id, r, enums.LockAccount)
+
+	if user.Role == enums.User && t.ExceedsLockouts(failure) {
+		if err := c.UpdateUserRole(user, enums.Locked, "", models.OriginFromRequest(r)); err != nil {
+			l.Error("failed to lock user", "account_id", user.AccountId, "err", err)
+		}
+	}
+}
+
+// releaseLoginAttempt gives back the attempt once the request is done with it, keeping the penalty of a failure
+// recorded for it. Failing to release it does not fail the request, the reservation runs out by itself.
+func releaseLoginAttempt(t *client.LoginThrottleClient, l hclog.Logger, attempt *client.LoginAttempt) {
+	if err := t.Release(attempt); err != nil {
+		l.Error("failed to release login attempt", "err", err)
+	}
+}
+
+// resetLoginFailures forgets the failed logins of the user once they sign in. Failing to reset them does not
+// fail the request.
+func resetLoginFailures(t *client.LoginThrottleClient, l hclog.Logger, user *models.User) {
+	if err := t.Reset(user); err != nil {
+		l.Error("failed to reset login failures", "account_id", user.AccountId, "err", err)
+	}
+}
+
+// setRetryAfter sets the Retry-After header to the wait in whole seconds, rounded up.
+func setRetryAfter(w http.ResponseWriter, wait time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+}
+
+// writeTooManyAttempts responds with 429, asking the client to retry after the wait.
+func writeTooManyAttempts(w http.ResponseWriter, wait time.Duration) {
+	setRetryAfter(w, wait)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusTooManyRequests)
+	responses.NewGenericError(tooManyAttempts).Encode(w)
+}
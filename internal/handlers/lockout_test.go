@@ -0,0 +1,30 @@
+package handlers
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestWriteTooManyAttempts(t *testing.T) {
+	tests := []struct {
+		name string
+		wait time.Duration
+		want string
+	}{
+		{name: "should round up part of a second", wait: 1500 * time.Millisecond, want: "2"},
+		{name: "should keep whole seconds", wait: 15 * time.Minute, want: "900"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			writeTooManyAttempts(rr, tt.wait)
+
+			assert.Equal(t, http.StatusTooManyRequests, rr.Code)
+			assert.Equal(t, tt.want, rr.Header().Get("Retry-After"))
+			assert.Contains(t, rr.Body.String(), tooManyAttempts)
+		})
+	}
+}
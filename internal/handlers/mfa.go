@@ -0,0 +1,458 @@
+package handlers
+
+import (
+	"errors"
+	"github.com/hashicorp/go-hclog"
+	"github.com/jmoiron/sqlx"
+	"github.com/knockbox/authentication/internal/client"
+	"github.com/knockbox/authentication/pkg/enums"
+	"github.com/knockbox/authentication/pkg/keyring"
+	"github.com/knockbox/authentication/pkg/middleware"
+	"github.com/knockbox/authentication/pkg/models"
+	"github.com/knockbox/authentication/pkg/payloads"
+	"github.com/knockbox/authentication/pkg/responses"
+	"github.com/knockbox/authentication/pkg/totp"
+	"github.com/knockbox/authentication/pkg/utils"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+	"net/http"
+)
+
+// newMFAClient creates the client.MFAClient sealing secrets with the key-encryption key of the keyring. Without
+// one, two-factor authentication cannot be enrolled.
+func newMFAClient(db *sqlx.DB, l hclog.Logger) *client.MFAClient {
+	var sealer keyring.Sealer
+	if envelope, err := keyring.NewEnvelopeFromEnv(); err == nil {
+		sealer = envelope
+	} else {
+		l.Warn("two-factor authentication is unavailable", "err", err)
+	}
+
+	return client.NewMFAClient(db, sealer, l)
+}
+
+// verifySecondFactor checks the TOTP code, or failing that the recovery code, of the user and returns the amr of
+// the password and the factor given. An empty amr means neither was accepted.
+func verifySecondFactor(mfa *client.MFAClient, userId uint, code, recoveryCode string) (string, error) {
+	t, err := mfa.GetTOTP(userId)
+	if err != nil || t == nil || !t.IsConfirmed() {
+		return "", err
+	}
+
+	if code != "" {
+		ok, err := mfa.VerifyTOTP(t, code)
+		if err != nil || !ok {
+			return "", err
+		}
+
+		return amrTOTP, nil
+	}
+
+	ok, err := mfa.UseRecoveryCode(userId, recoveryCode)
+	if err != nil || !ok {
+		return "", err
+	}
+
+	return amrRecoveryCode, nil
+}
+
+// challengeMFA responds to a login whose password was accepted with a token to give the second factor with. The
+// scope is checked now so a login that could never succeed fails before the second factor is asked for.
+func (u *User) challengeMFA(w http.ResponseWriter, user *models.User, scope string) {
+	if _, ok := user.GrantScopes(scope); !ok {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		responses.NewGenericError("the requested scope is not allowed").Encode(w)
+		return
+	}
+
+	token, err := user.CreateMFAToken(u.config, u.account.MFATokenLifespan, scope)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		u.Error("failed to create mfa token", "err", err)
+		return
+	}
+
+	signed, err := u.Sign(token, keyring.TypeMFAToken)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		u.Error("failed to sign mfa token", "err", err)
+		return
+	}
+
+	responses.NewMFAChallenge(signed, int(u.account.MFATokenLifespan.Seconds())).Encode(w)
+}
+
+// LoginMFA completes a login that required a second factor, exchanging the MFA token and a TOTP code or recovery
+// code for tokens in the scope requested with the password. Each MFA token allows a single attempt.
+func (u *User) LoginMFA(w http.ResponseWriter, r *http.Request) {
+	payload := &payloads.UserLoginMFA{}
+	if utils.DecodeAndValidateStruct(w, r, payload) {
+		return
+	}
+
+	token, err := u.Verify(
+		[]byte(payload.MFAToken),
+		keyring.TypeMFAToken,
+		jwt.WithIssuer(u.config.Issuer),
+		jwt.WithAudience(u.config.Issuer),
+		jwt.WithAcceptableSkew(u.config.ClockSkew),
+	)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnauthorized)
+		responses.NewGenericError("the mfa token is invalid or has expired").Encode(w)
+		return
+	}
+
+	// Revoking first makes the token single use, so each password gives one guess at the second factor.
+	if err := u.tokens.RevokeToken(token); err != nil {
+		if utils.IsDuplicateEntry(err) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusUnauthorized)
+			responses.NewGenericError("the mfa token has already been used").Encode(w)
+			return
+		}
+
+		w.WriteHeader(http.StatusInternalServerError)
+		u.Error("failed to revoke mfa token", "err", err)
+		return
+	}
+
+	user, err := u.c.GetUserByAccountId(token.Subject())
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		u.Error("failed to get user by account_id", "err", err)
+		return
+	}
+
+	if user == nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	if user.Role.IsForbidden() {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusForbidden)
+		responses.NewGenericError("account locked").Encode(w)
+		return
+	}
+
+	attempt, wait, err := u.throttle.Attempt(user, utils.GetIpAddress(r))
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		u.Error("failed to get login failures", "err", err)
+		return
+	}
+
+	if wait > 0 {
+		writeTooManyAttempts(w, wait)
+		return
+	}
+	defer releaseLoginAttempt(u.throttle, u.Logger, attempt)
+
+	amr, err := verifySecondFactor(u.mfa, user.Id, payload.Code, payload.RecoveryCode)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		u.Error("failed to verify second factor", "err", err)
+		return
+	}
+
+	if amr == "" {
+		recordLoginFailure(u.c, u.throttle, u.Logger, r, user, attempt)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnauthorized)
+		responses.NewGenericError("the code is incorrect, sign in again to retry").Encode(w)
+		return
+	}
+
+	if amr == amrRecoveryCode {
+		recordHistory(u.c, u.Logger, user.Id, r, enums.UseRecoveryCode)
+	}
+
+	issued, err := u.issue(user, "", "", models.MFATokenScope(token), amr)
+	if errors.Is(err, errInvalidScope) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		responses.NewGenericError("the requested scope is not allowed").Encode(w)
+		return
+	}
+
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		responses.NewGenericError("failed to issue token").Encode(w)
+
+		u.Error("failed to issue token", "err", err)
+		return
+	}
+
+	resetLoginFailures(u.throttle, u.Logger, user)
+	recordHistory(u.c, u.Logger, user.Id, r, enums.Login)
+	issued.Encode(w)
+}
+
+// EnrollTOTP generates a new authenticator secret for the bearer, given their current password. It is not required
+// to sign in until confirmed with ConfirmTOTP, enrolling again before then replaces it. An authenticator locks
+// every password login to whoever holds it, so the bearer token alone must not be enough to add one.
+func (u *User) EnrollTOTP(w http.ResponseWriter, r *http.Request) {
+	payload := &payloads.CurrentPassword{}
+	if utils.DecodeAndValidateStruct(w, r, payload) {
+		return
+	}
+
+	user, ok := u.principalUser(w, r)
+	if !ok || !u.checkCurrentPassword(w, r, user, payload.CurrentPassword) {
+		return
+	}
+
+	enabled, err := u.mfa.HasTOTP(user.Id)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		u.Error("failed to get totp", "err", err)
+		return
+	}
+
+	if enabled {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusConflict)
+		responses.NewGenericError("two-factor authentication is already enabled").Encode(w)
+		return
+	}
+
+	secret, err := u.mfa.EnrollTOTP(user.Id)
+	if errors.Is(err, client.ErrNoSealer) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		responses.NewGenericError("two-factor authentication is unavailable").Encode(w)
+		return
+	}
+
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		u.Error("failed to enroll totp", "err", err)
+		return
+	}
+
+	enrollment := &responses.TOTPEnrollment{
+		Secret:     secret,
+		OTPAuthURI: totp.URI(u.account.TOTPIssuer, user.Username, secret),
+	}
+	enrollment.Encode(w)
+}
+
+// ConfirmTOTP enables two-factor authentication once the bearer gives their current password and a code from their
+// new authenticator, and returns their recovery codes.
+func (u *User) ConfirmTOTP(w http.ResponseWriter, r *http.Request) {
+	payload := &payloads.TOTPConfirm{}
+	if utils.DecodeAndValidateStruct(w, r, payload) {
+		return
+	}
+
+	user, ok := u.principalUser(w, r)
+	if !ok || !u.checkCurrentPassword(w, r, user, payload.CurrentPassword) {
+		return
+	}
+
+	t, err := u.mfa.GetTOTP(user.Id)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		u.Error("failed to get totp", "err", err)
+		return
+	}
+
+	if t == nil || t.IsConfirmed() {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusConflict)
+		responses.NewGenericError("there is no authenticator waiting to be confirmed").Encode(w)
+		return
+	}
+
+	confirmed, err := u.mfa.ConfirmTOTP(t, payload.Code)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		u.Error("failed to confirm totp", "err", err)
+		return
+	}
+
+	if !confirmed {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		responses.NewGenericError("the code is incorrect").Encode(w)
+		return
+	}
+
+	codes, err := u.mfa.GenerateRecoveryCodes(user.Id)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		u.Error("failed to generate recovery codes", "err", err)
+		return
+	}
+
+	recordHistory(u.c, u.Logger, user.Id, r, enums.EnableMFA)
+
+	recovery := &responses.RecoveryCodes{RecoveryCodes: codes}
+	recovery.Encode(w)
+}
+
+// DisableTOTP removes the authenticator and recovery codes of the bearer, given their current password.
+func (u *User) DisableTOTP(w http.ResponseWriter, r *http.Request) {
+	payload := &payloads.CurrentPassword{}
+	if utils.DecodeAndValidateStruct(w, r, payload) {
+		return
+	}
+
+	user, ok := u.principalUser(w, r)
+	if !ok || !u.checkCurrentPassword(w, r, user, payload.CurrentPassword) {
+		return
+	}
+
+	t, err := u.mfa.GetTOTP(user.Id)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		u.Error("failed to get totp", "err", err)
+		return
+	}
+
+	if t == nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		responses.NewGenericError("two-factor authentication is not enabled").Encode(w)
+		return
+	}
+
+	if err := u.mfa.DisableTOTP(user.Id); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		u.Error("failed to disable totp", "err", err)
+		return
+	}
+
+	if t.IsConfirmed() {
+		recordHistory(u.c, u.Logger, user.Id, r, enums.DisableMFA)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RegenerateRecoveryCodes replaces the recovery codes of the bearer, given their current password.
+func (u *User) RegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	payload := &payloads.CurrentPassword{}
+	if utils.DecodeAndValidateStruct(w, r, payload) {
+		return
+	}
+
+	user, ok := u.principalUser(w, r)
+	if !ok || !u.checkCurrentPassword(w, r, user, payload.CurrentPassword) {
+		return
+	}
+
+	enabled, err := u.mfa.HasTOTP(user.Id)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		u.Error("failed to get totp", "err", err)
+		return
+	}
+
+	if !enabled {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		responses.NewGenericError("two-factor authentication is not enabled").Encode(w)
+		return
+	}
+
+	codes, err := u.mfa.GenerateRecoveryCodes(user.Id)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		u.Error("failed to generate recovery codes", "err", err)
+		return
+	}
+
+	recordHistory(u.c, u.Logger, user.Id, r, enums.RegenerateRecoveryCodes)
+
+	recovery := &responses.RecoveryCodes{RecoveryCodes: codes}
+	recovery.Encode(w)
+}
+
+// principalUser returns the user of the bearer token, responding and returning false if there is none.
+func (u *User) principalUser(w http.ResponseWriter, r *http.Request) (*models.User, bool) {
+	p, ok := middleware.GetPrincipal(r)
+	if !ok {
+		w.WriteHeader(http.StatusUnauthorized)
+		u.Warn("principal was expected and should have existed but was not found", "path", r.URL.Path)
+		return nil, false
+	}
+
+	user, err := u.c.GetUserByAccountId(p.Subject)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		u.Error("failed to get user by account_id", "err", err)
+		return nil, false
+	}
+
+	if user == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return nil, false
+	}
+
+	return user, true
+}
+
+// checkTOTP responds with 403 and returns false if the user has enabled two-factor authentication and the code
+// is not valid for their authenticator. Each code is accepted once.
+func (u *User) checkTOTP(w http.ResponseWriter, user *models.User, code string) bool {
+	t, err := u.mfa.GetTOTP(user.Id)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		u.Error("failed to get totp", "err", err)
+		return false
+	}
+
+	if t == nil || !t.IsConfirmed() {
+		return true
+	}
+
+	ok, err := u.mfa.VerifyTOTP(t, code)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		u.Error("failed to verify totp", "err", err)
+		return false
+	}
+
+	if !ok {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusForbidden)
+		responses.NewGenericError("the code from your authenticator app is missing or incorrect").Encode(w)
+		return false
+	}
+
+	return true
+}
+
+// checkCurrentPassword responds with 403 and returns false if the password is not the current password of the user.
+// Wrong passwords count as failed logins, so a bearer token gives no more guesses than the login does, and a
+// throttled check is refused with 429.
+func (u *User) checkCurrentPassword(w http.ResponseWriter, r *http.Request, user *models.User, password string) bool {
+	attempt, wait, err := u.throttle.Attempt(user, utils.GetIpAddress(r))
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		u.Error("failed to get login failures", "err", err)
+		return false
+	}
+
+	if wait > 0 {
+		writeTooManyAttempts(w, wait)
+		return false
+	}
+	defer releaseLoginAttempt(u.throttle, u.Logger, attempt)
+
+	if utils.ComparePasswords(user.Password, password) {
+		return true
+	}
+
+	recordLoginFailure(u.c, u.throttle, u.Logger, r, user, attempt)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusForbidden)
+	responses.NewGenericError("the current password is incorrect").Encode(w)
+	return false
+}
@@ -0,0 +1,212 @@
+package handlers
+
+import (
+	"github.com/google/uuid"
+	"github.com/hashicorp/go-hclog"
+	"github.com/knockbox/authentication/pkg/enums"
+	"github.com/knockbox/authentication/pkg/keyring"
+	"github.com/knockbox/authentication/pkg/models"
+	"github.com/knockbox/authentication/pkg/utils"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestUser_LoginMFA(t *testing.T) {
+	keyset, _ := keyring.NewSet(1000, 500, hclog.Default())
+	keyset.SetCurveTypes(keyring.P256)
+	if err := keyset.Load(1); err != nil {
+		t.Fatal(err)
+	}
+
+	config := &utils.TokenConfig{Issuer: "https://auth.knockbox.io", Audience: []string{"knockbox"}}
+	user := &models.User{AccountId: uuid.New(), Email: "user@knockbox.io"}
+
+	sign := func(typ string, duration time.Duration) string {
+		token, err := user.CreateMFAToken(config, duration, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		signed, err := keyset.Sign(token, typ)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return string(signed)
+	}
+
+	tests := []struct {
+		name string
+		body string
+		want int
+	}{
+		{
+			name: "missing both codes",
+			body: `{"mfa_token": "` + sign(keyring.TypeMFAToken, time.Minute) + `"}`,
+			want: http.StatusBadRequest,
+		},
+		{
+			name: "code that is not six digits",
+			body: `{"mfa_token": "` + sign(keyring.TypeMFAToken, time.Minute) + `", "code": "12345a"}`,
+			want: http.StatusBadRequest,
+		},
+		{
+			name: "missing mfa_token",
+			body: `{"code": "123456"}`,
+			want: http.StatusBadRequest,
+		},
+		{
+			name: "malformed mfa_token",
+			body: `{"mfa_token": "not-a-jwt", "code": "123456"}`,
+			want: http.StatusUnauthorized,
+		},
+		{
+			name: "verification token typ",
+			body: `{"mfa_token": "` + sign(keyring.TypeVerificationToken, time.Minute) + `", "code": "123456"}`,
+			want: http.StatusUnauthorized,
+		},
+		{
+			name: "expired mfa_token",
+			body: `{"mfa_token": "` + sign(keyring.TypeMFAToken, -time.Minute) + `", "recovery_code": "abcd-efgh"}`,
+			want: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodPost, "/login/mfa", strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Content-Type", "application/json")
+
+			rr := httptest.NewRecorder()
+			u := &User{Logger: hclog.Default(), KeySet: keyset, issuer: &issuer{config: config}}
+			http.HandlerFunc(u.LoginMFA).ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.want, rr.Code)
+		})
+	}
+}
+
+func TestUser_EnrollTOTP(t *testing.T) {
+	u, store := newMemoryUser(t)
+	user := store.addUser(t, "the-current-password")
+
+	tests := []struct {
+		name    string
+		handler func(u *User) http.HandlerFunc
+		body    string
+		want    int
+	}{
+		{
+			name:    "enroll without the current password",
+			handler: func(u *User) http.HandlerFunc { return u.EnrollTOTP },
+			body:    `{}`,
+			want:    http.StatusBadRequest,
+		},
+		{
+			name:    "enroll with the wrong current password",
+			handler: func(u *User) http.HandlerFunc { return u.EnrollTOTP },
+			body:    `{"current_password": "not-the-current-password"}`,
+			want:    http.StatusForbidden,
+		},
+		{
+			name:    "confirm without the current password",
+			handler: func(u *User) http.HandlerFunc { return u.ConfirmTOTP },
+			body:    `{"code": "123456"}`,
+			want:    http.StatusBadRequest,
+		},
+		{
+			name:    "confirm with the wrong current password",
+			handler: func(u *User) http.HandlerFunc { return u.ConfirmTOTP },
+			body:    `{"current_password": "not-the-current-password", "code": "123456"}`,
+			want:    http.StatusForbidden,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodPost, "/mfa/totp", strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Content-Type", "application/json")
+
+			// The handler has no MFAClient, so reaching the authenticator at all would panic.
+			rr := httptest.NewRecorder()
+			tt.handler(u).ServeHTTP(rr, withTestPrincipal(req, user))
+
+			assert.Equal(t, tt.want, rr.Code)
+			assert.NotContains(t, store.history.actions(), enums.UserAction(enums.EnableMFA))
+		})
+	}
+}
+
+func TestUser_CheckCurrentPassword(t *testing.T) {
+	u, store := newMemoryUser(t)
+	user := store.addUser(t, "the-current-password")
+
+	// Each step runs against the failures left by the steps before it.
+	tests := []struct {
+		name     string
+		password string
+		want     int
+		failures int
+	}{
+		{
+			name:     "current password stores no failure",
+			password: "the-current-password",
+			want:     http.StatusNoContent,
+			failures: 0,
+		},
+		{
+			name:     "wrong password counts against the account and ip address",
+			password: "not-the-current-password",
+			want:     http.StatusForbidden,
+			failures: 2,
+		},
+		{
+			name:     "second wrong password",
+			password: "not-the-current-password",
+			want:     http.StatusForbidden,
+			failures: 2,
+		},
+		{
+			name:     "third wrong password locks the account",
+			password: "not-the-current-password",
+			want:     http.StatusForbidden,
+			failures: 2,
+		},
+		{
+			name:     "current password once locked",
+			password: "the-current-password",
+			want:     http.StatusTooManyRequests,
+			failures: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodDelete, "/mfa/totp", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.RemoteAddr = "192.0.2.1:52000"
+
+			rr := httptest.NewRecorder()
+			if u.checkCurrentPassword(rr, req, user, tt.password) {
+				rr.WriteHeader(http.StatusNoContent)
+			}
+
+			assert.Equal(t, tt.want, rr.Code)
+			assert.Len(t, store.failures, tt.failures)
+		})
+	}
+
+	assert.Contains(t, store.history.actions(), enums.UserAction(enums.LockAccount))
+}
@@ -0,0 +1,547 @@
+package handlers
+
+import (
+	"errors"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/hashicorp/go-hclog"
+	"github.com/knockbox/authentication/internal/client"
+	"github.com/knockbox/authentication/internal/templates"
+	"github.com/knockbox/authentication/pkg/enums"
+	"github.com/knockbox/authentication/pkg/keyring"
+	"github.com/knockbox/authentication/pkg/models"
+	"github.com/knockbox/authentication/pkg/responses"
+	"github.com/knockbox/authentication/pkg/utils"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// authorizationCodeLifespan is how long an authorization code may wait to be exchanged.
+const authorizationCodeLifespan = time.Minute
+
+// authorizeRequest is the authorization request of RFC 6749 section 4.1.1 with the PKCE parameters of RFC 7636
+// and the nonce of OpenID Connect.
+type authorizeRequest struct {
+	ResponseType        string
+	ClientId            string
+	RedirectURI         string
+	Scope               string
+	State               string
+	CodeChallenge       string
+	CodeChallengeMethod string
+	Nonce               string
+}
+
+func parseAuthorizeRequest(form url.Values) *authorizeRequest {
+	return &authorizeRequest{
+		ResponseType:        form.Get("response_type"),
+		ClientId:            form.Get("client_id"),
+		RedirectURI:         form.Get("redirect_uri"),
+		Scope:               form.Get("scope"),
+		State:               form.Get("state"),
+		CodeChallenge:       form.Get("code_challenge"),
+		CodeChallengeMethod: form.Get("code_challenge_method"),
+		Nonce:               form.Get("nonce"),
+	}
+}
+
+// authorizeView is rendered by templates.Authorize.
+type authorizeView struct {
+	Client      *models.OAuthClient
+	Request     *authorizeRequest
+	Scopes      []string
+	Username    string
+	MFARequired bool
+	Error       string
+}
+
+// OAuth is the OAuth 2.0 authorization server, codes are only issued to registered clients and must be
+// exchanged with the PKCE (S256) code_verifier they were requested with.
+type OAuth struct {
+	hclog.Logger
+	*keyring.KeySet
+	c        *client.UserClient
+	mfa      *client.MFAClient
+	throttle *client.LoginThrottleClient
+	oauth    *client.OAuthClient
+	*issuer
+}
+
+// Authorize shows the login form, and the consent form for third-party clients.
+func (o *OAuth) Authorize(w http.ResponseWriter, r *http.Request) {
+	req := parseAuthorizeRequest(r.URL.Query())
+
+	registered, ok := o.validateAuthorizeRequest(w, r, req)
+	if !ok {
+		return
+	}
+
+	o.render(w, http.StatusOK, &authorizeView{Client: registered, Request: req})
+}
+
+// Approve authenticates the user and, once consent has been given, redirects to the client with a code. Users with
+// two-factor authentication are asked for their code, or a recovery code, along with their password.
+func (o *OAuth) Approve(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		responses.NewGenericError("malformed body, expected form").Encode(w)
+		return
+	}
+
+	req := parseAuthorizeRequest(r.PostForm)
+
+	registered, ok := o.validateAuthorizeRequest(w, r, req)
+	if !ok {
+		return
+	}
+
+	if r.PostForm.Get("consent") != "allow" {
+		o.redirectError(w, r, req, "access_denied", "the user denied the request")
+		return
+	}
+
+	username := r.PostForm.Get("username")
+	user, err := o.c.GetUserByUsername(username)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		o.Error("failed to get user by username", "err", err)
+		return
+	}
+
+	attempt, wait, err := o.throttle.Attempt(user, utils.GetIpAddress(r))
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		o.Error("failed to get login failures", "err", err)
+		return
+	}
+
+	if wait > 0 {
+		setRetryAfter(w, wait)
+		o.render(w, http.StatusTooManyRequests, &authorizeView{
+			Client:   registered,
+			Request:  req,
+			Username: username,
+			Error:    tooManyAttempts,
+		})
+		return
+	}
+	defer releaseLoginAttempt(o.throttle, o.Logger, attempt)
+
+	if user == nil || !utils.ComparePasswords(user.Password, r.PostForm.Get("password")) {
+		recordLoginFailure(o.c, o.throttle, o.Logger, r, user, attempt)
+
+		o.render(w, http.StatusUnauthorized, &authorizeView{
+			Client:   registered,
+			Request:  req,
+			Username: username,
+			Error:    "invalid username or password",
+		})
+		return
+	}
+
+	if user.Role.IsForbidden() {
+		o.redirectError(w, r, req, "access_denied", "the user may not sign in")
+		return
+	}
+
+	scope, ok := user.GrantScopes(req.Scope)
+	if !ok {
+		o.redirectError(w, r, req, "invalid_scope", "the user may not grant the requested scope")
+		return
+	}
+
+	amr, ok := o.approveSecondFactor(w, r, registered, req, user, attempt)
+	if !ok {
+		return
+	}
+
+	code, err := o.oauth.CreateAuthorizationCode(models.AuthorizationCode{
+		ClientId:      registered.ClientId,
+		UserId:        user.Id,
+		FamilyId:      uuid.NewString(),
+		RedirectURI:   req.RedirectURI,
+		Scope:         scope,
+		CodeChallenge: req.CodeChallenge,
+		Nonce:         req.Nonce,
+		AuthTime:      time.Now(),
+		Amr:           amr,
+	}, authorizationCodeLifespan)
+	if err != nil {
+		o.Error("failed to create authorization code", "err", err)
+		o.redirectError(w, r, req, "server_error", "failed to create authorization code")
+		return
+	}
+
+	resetLoginFailures(o.throttle, o.Logger, user)
+	recordHistory(o.c, o.Logger, user.Id, r, enums.Login)
+	o.redirect(w, r, req, url.Values{"code": {code}})
+}
+
+// approveSecondFactor returns the amr of the user signing in to the authorization form. Users with two-factor
+// authentication must also give a valid otp or recovery_code, otherwise the form is shown again asking for it and
+// false is returned. A wrong one fails the attempt.
+func (o *OAuth) approveSecondFactor(w http.ResponseWriter, r *http.Request, registered *models.OAuthClient, req *authorizeRequest, user *models.User, attempt *client.LoginAttempt) (string, bool) {
+	hasTOTP, err := o.mfa.HasTOTP(user.Id)
+	if err != nil {
+		o.Error("failed to get totp", "err", err)
+		o.redirectError(w, r, req, "server_error", "failed to verify the second factor")
+		return "", false
+	}
+
+	if !hasTOTP {
+		return amrPassword, true
+	}
+
+	view := &authorizeView{
+		Client:      registered,
+		Request:     req,
+		Username:    user.Username,
+		MFARequired: true,
+		Error:       "enter the code from your authenticator app",
+	}
+
+	code, recoveryCode := strings.TrimSpace(r.PostForm.Get("otp")), strings.TrimSpace(r.PostForm.Get("recovery_code"))
+	if code == "" && recoveryCode == "" {
+		o.render(w, http.StatusUnauthorized, view)
+		return "", false
+	}
+
+	amr, err := verifySecondFactor(o.mfa, user.Id, code, recoveryCode)
+	if err != nil {
+		o.Error("failed to verify second factor", "err", err)
+		o.redirectError(w, r, req, "server_error", "failed to verify the second factor")
+		return "", false
+	}
+
+	if amr == "" {
+		recordLoginFailure(o.c, o.throttle, o.Logger, r, user, attempt)
+
+		view.Error = "invalid code"
+		o.render(w, http.StatusUnauthorized, view)
+		return "", false
+	}
+
+	if amr == amrRecoveryCode {
+		recordHistory(o.c, o.Logger, user.Id, r, enums.UseRecoveryCode)
+	}
+
+	return amr, true
+}
+
+// Exchange is the token endpoint, supporting the authorization_code, refresh_token and client_credentials grants.
+func (o *OAuth) Exchange(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Pragma", "no-cache")
+
+	if err := r.ParseForm(); err != nil {
+		o.tokenError(w, http.StatusBadRequest, "invalid_request", "malformed body, expected form")
+		return
+	}
+
+	var token *responses.Token
+	var err error
+
+	switch grantType := r.PostForm.Get("grant_type"); grantType {
+	case "authorization_code":
+		token, err = o.exchangeAuthorizationCode(r)
+	case "refresh_token":
+		token, err = o.exchangeRefreshToken(r)
+	case "client_credentials":
+		token, err = o.exchangeClientCredentials(r)
+	case "":
+		o.tokenError(w, http.StatusBadRequest, "invalid_request", "grant_type was not provided")
+		return
+	default:
+		o.tokenError(w, http.StatusBadRequest, "unsupported_grant_type", "")
+		return
+	}
+
+	switch {
+	case errors.Is(err, errInvalidGrant):
+		o.tokenError(w, http.StatusBadRequest, "invalid_grant", "")
+		return
+	case errors.Is(err, errInvalidScope):
+		o.tokenError(w, http.StatusBadRequest, "invalid_scope", "")
+		return
+	case errors.Is(err, errInvalidClient):
+		w.Header().Set("WWW-Authenticate", `Basic realm="oauth"`)
+		o.tokenError(w, http.StatusUnauthorized, "invalid_client", "")
+		return
+	}
+
+	if err != nil {
+		o.tokenError(w, http.StatusInternalServerError, "server_error", "failed to issue token")
+		o.Error("failed to issue token", "err", err)
+		return
+	}
+
+	token.Encode(w)
+}
+
+// exchangeAuthorizationCode redeems a code once. The client_id and redirect_uri must match those it was
+// requested with and the code_verifier must satisfy its challenge, confidential clients must also authenticate.
+// Presenting a code that was already redeemed revokes the refresh tokens issued for it, the access token issued
+// with them stays valid until it expires. An ID token is included when the openid scope was requested.
+func (o *OAuth) exchangeAuthorizationCode(r *http.Request) (*responses.Token, error) {
+	clientId, secret, _ := clientCredentials(r)
+
+	code, err := o.oauth.GetAuthorizationCode(r.PostForm.Get("code"))
+	if err != nil {
+		return nil, err
+	}
+
+	if code == nil || code.IsExpired() {
+		return nil, errInvalidGrant
+	}
+
+	if code.ClientId != clientId || code.RedirectURI != r.PostForm.Get("redirect_uri") {
+		return nil, errInvalidGrant
+	}
+
+	if !code.VerifyChallenge(r.PostForm.Get("code_verifier")) {
+		return nil, errInvalidGrant
+	}
+
+	registered, err := o.oauth.GetClient(code.ClientId)
+	if err != nil {
+		return nil, err
+	}
+
+	if registered == nil {
+		return nil, errInvalidGrant
+	}
+
+	if registered.IsConfidential() && !registered.VerifySecret(secret) {
+		return nil, errInvalidClient
+	}
+
+	// A code that was already used, or loses the race to be used, is being replayed.
+	fresh := code.UsedAt == nil
+	if fresh {
+		if fresh, err = o.oauth.UseAuthorizationCode(code); err != nil {
+			return nil, err
+		}
+	}
+
+	if !fresh {
+		o.Warn("authorization code reused, revoking family", "family_id", code.FamilyId, "client_id", code.ClientId)
+		if err := o.tokens.RevokeRefreshTokenFamily(code.FamilyId); err != nil {
+			o.Error("failed to revoke refresh token family", "family_id", code.FamilyId, "err", err)
+		}
+
+		return nil, errInvalidGrant
+	}
+
+	user, err := o.c.GetUserById(int(code.UserId))
+	if err != nil {
+		return nil, err
+	}
+
+	if user == nil || user.Role.IsForbidden() {
+		return nil, errInvalidGrant
+	}
+
+	token, err := o.issue(user, code.ClientId, code.FamilyId, code.Scope, code.Amr)
+	if errors.Is(err, errInvalidScope) {
+		return nil, errInvalidGrant
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !code.HasScope("openid") {
+		return token, nil
+	}
+
+	idToken, err := o.issueIdToken(user, code)
+	if err != nil {
+		return nil, err
+	}
+
+	return token.WithIdToken(idToken), nil
+}
+
+// exchangeRefreshToken exchanges a refresh token issued to the client (RFC 6749 section 6). Confidential clients
+// must authenticate, public clients give their client_id.
+func (o *OAuth) exchangeRefreshToken(r *http.Request) (*responses.Token, error) {
+	clientId, secret, _ := clientCredentials(r)
+
+	registered, err := o.oauth.GetClient(clientId)
+	if err != nil {
+		return nil, err
+	}
+
+	if registered == nil || (registered.IsConfidential() && !registered.VerifySecret(secret)) {
+		o.Info("client authentication failed", "client_id", clientId)
+		return nil, errInvalidClient
+	}
+
+	return o.refresh(r.PostForm.Get("refresh_token"), registered.ClientId)
+}
+
+// exchangeClientCredentials issues a token to a confidential client acting on its own behalf, limited to the
+// scopes it is allowed.
+func (o *OAuth) exchangeClientCredentials(r *http.Request) (*responses.Token, error) {
+	clientId, secret, ok := clientCredentials(r)
+	if !ok {
+		return nil, errInvalidClient
+	}
+
+	registered, err := o.oauth.AuthenticateClientCredentials(clientId, secret)
+	if err != nil {
+		return nil, err
+	}
+
+	if registered == nil {
+		o.Info("client authentication failed", "client_id", clientId)
+		return nil, errInvalidClient
+	}
+
+	scope, ok := registered.GrantScopes(r.PostForm.Get("scope"))
+	if !ok {
+		return nil, errInvalidScope
+	}
+
+	return o.issueForClient(registered, scope)
+}
+
+// clientCredentials returns the client_id and client_secret from HTTP Basic, or otherwise from the form. ok is
+// false if no secret was presented, the client_id of a public client is still returned.
+func clientCredentials(r *http.Request) (clientId, secret string, ok bool) {
+	if clientId, secret, ok := r.BasicAuth(); ok {
+		return clientId, secret, secret != ""
+	}
+
+	clientId, secret = r.PostForm.Get("client_id"), r.PostForm.Get("client_secret")
+	return clientId, secret, secret != ""
+}
+
+// validateAuthorizeRequest writes the error and returns false if the request is invalid. Errors with the client
+// or redirect_uri are shown to the user, as redirecting them would make this an open redirector, every other
+// error is sent to the client.
+func (o *OAuth) validateAuthorizeRequest(w http.ResponseWriter, r *http.Request, req *authorizeRequest) (*models.OAuthClient, bool) {
+	registered, err := o.oauth.GetClient(req.ClientId)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		o.Error("failed to get client by client_id", "err", err)
+		return nil, false
+	}
+
+	if registered == nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		responses.NewGenericError("unknown client_id").Encode(w)
+		return nil, false
+	}
+
+	if !registered.HasRedirectURI(req.RedirectURI) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		responses.NewGenericError("redirect_uri is not registered for the client").Encode(w)
+		return nil, false
+	}
+
+	if req.ResponseType != "code" {
+		o.redirectError(w, r, req, "unsupported_response_type", "only the code response_type is supported")
+		return nil, false
+	}
+
+	if req.CodeChallengeMethod != "S256" || len(req.CodeChallenge) < 43 || len(req.CodeChallenge) > 128 {
+		o.redirectError(w, r, req, "invalid_request", "a code_challenge using the S256 code_challenge_method is required")
+		return nil, false
+	}
+
+	// Clients must ask for what they need, an empty scope would otherwise grant everything the user may grant.
+	if strings.TrimSpace(req.Scope) == "" {
+		o.redirectError(w, r, req, "invalid_scope", "a scope is required")
+		return nil, false
+	}
+
+	// The scope is later narrowed to what the user may grant, so the code only carries scopes both allow.
+	if _, ok := registered.GrantScopes(req.Scope); !ok {
+		o.redirectError(w, r, req, "invalid_scope", "the client may not request the requested scope")
+		return nil, false
+	}
+
+	return registered, true
+}
+
+// render writes the templates.Authorize form, framing is denied so consent cannot be clickjacked.
+func (o *OAuth) render(w http.ResponseWriter, status int, view *authorizeView) {
+	view.Scopes = strings.Fields(view.Request.Scope)
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("X-Frame-Options", "DENY")
+	w.Header().Set("Content-Security-Policy", "frame-ancestors 'none'")
+	w.WriteHeader(status)
+
+	if err := templates.Authorize.Execute(w, view); err != nil {
+		o.Error("failed to render authorize form", "err", err)
+	}
+}
+
+// redirect sends the user back to the validated redirect_uri with the params and the state.
+func (o *OAuth) redirect(w http.ResponseWriter, r *http.Request, req *authorizeRequest, params url.Values) {
+	target, err := url.Parse(req.RedirectURI)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		responses.NewGenericError("redirect_uri failed to parse").Encode(w)
+		return
+	}
+
+	query := target.Query()
+	for key, values := range params {
+		query[key] = values
+	}
+
+	if req.State != "" {
+		query.Set("state", req.State)
+	}
+
+	target.RawQuery = query.Encode()
+	http.Redirect(w, r, target.String(), http.StatusFound)
+}
+
+// redirectError sends the error to the client as defined by RFC 6749 section 4.1.2.1.
+func (o *OAuth) redirectError(w http.ResponseWriter, r *http.Request, req *authorizeRequest, code, description string) {
+	o.redirect(w, r, req, url.Values{"error": {code}, "error_description": {description}})
+}
+
+// tokenError writes the error of the token endpoint as defined by RFC 6749 section 5.2.
+func (o *OAuth) tokenError(w http.ResponseWriter, status int, code, description string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	responses.NewOAuthError(code, description).Encode(w)
+}
+
+func (o *OAuth) Route(r *mux.Router) {
+	oauthRouter := r.PathPrefix("/oauth").Subrouter()
+	oauthRouter.HandleFunc("/authorize", o.Authorize).Methods(http.MethodGet)
+	oauthRouter.HandleFunc("/authorize", o.Approve).Methods(http.MethodPost)
+	oauthRouter.HandleFunc("/token", o.Exchange).Methods(http.MethodPost)
+}
+
+func NewOAuth(l hclog.Logger, ks *keyring.KeySet) *OAuth {
+	db, err := utils.MySQLConnection()
+	if err != nil {
+		panic(err)
+	}
+
+	users := client.NewUserClient(db, l)
+
+	return &OAuth{
+		Logger:   l,
+		KeySet:   ks,
+		c:        users,
+		mfa:      newMFAClient(db, l),
+		throttle: client.NewLoginThrottleClient(db, utils.LockoutConfigFromEnv(), l),
+		oauth:    client.NewOAuthClient(db, l),
+		issuer:   newIssuer(ks, utils.TokenConfigFromEnv(), users, client.NewTokenClient(db, l), l),
+	}
+}
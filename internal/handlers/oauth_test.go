@@ -0,0 +1,201 @@
+package handlers
+
+import (
+	"encoding/json"
+	"github.com/hashicorp/go-hclog"
+	"github.com/knockbox/authentication/internal/client"
+	"github.com/knockbox/authentication/pkg/models"
+	"github.com/knockbox/authentication/pkg/responses"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOAuth_Exchange(t *testing.T) {
+	tests := []struct {
+		name  string
+		form  url.Values
+		want  int
+		error string
+	}{
+		{
+			name:  "missing grant_type",
+			form:  url.Values{},
+			want:  http.StatusBadRequest,
+			error: "invalid_request",
+		},
+		{
+			name:  "unsupported grant_type",
+			form:  url.Values{"grant_type": {"password"}},
+			want:  http.StatusBadRequest,
+			error: "unsupported_grant_type",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodPost, "/oauth/token", strings.NewReader(tt.form.Encode()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+			rr := httptest.NewRecorder()
+			http.HandlerFunc((&OAuth{Logger: hclog.Default()}).Exchange).ServeHTTP(rr, req)
+
+			var got responses.OAuthError
+			if err := json.NewDecoder(rr.Body).Decode(&got); err != nil {
+				t.Fatal(err)
+			}
+
+			assert.Equal(t, tt.want, rr.Code)
+			assert.Equal(t, tt.error, got.Error)
+			assert.Equal(t, "no-store", rr.Header().Get("Cache-Control"))
+		})
+	}
+}
+
+func TestOAuth_ExchangeRefreshToken(t *testing.T) {
+	u, store := newMemoryUser(t)
+	user := store.addUser(t, "the-current-password")
+
+	confidential := models.OAuthClient{ClientId: "confidential"}
+	confidential.SetSecret("the-client-secret")
+
+	clients := memoryOAuthClients{}
+	_, _ = clients.Create(confidential)
+	_, _ = clients.Create(models.OAuthClient{ClientId: "public"})
+
+	l := hclog.NewNullLogger()
+	o := &OAuth{Logger: l, KeySet: u.KeySet, c: u.c, oauth: client.NewOAuthClientWithAccessors(clients, nil, l), issuer: u.issuer}
+
+	tests := []struct {
+		name     string
+		issuedTo string
+		form     url.Values
+		basic    []string
+		want     int
+		error    string
+	}{
+		{
+			name:     "confidential client without its secret",
+			issuedTo: "confidential",
+			form:     url.Values{"client_id": {"confidential"}},
+			want:     http.StatusUnauthorized,
+			error:    "invalid_client",
+		},
+		{
+			name:     "confidential client with the wrong secret",
+			issuedTo: "confidential",
+			basic:    []string{"confidential", "not-the-client-secret"},
+			want:     http.StatusUnauthorized,
+			error:    "invalid_client",
+		},
+		{
+			name:     "without a client",
+			issuedTo: "public",
+			form:     url.Values{},
+			want:     http.StatusUnauthorized,
+			error:    "invalid_client",
+		},
+		{
+			name:     "token of another client",
+			issuedTo: "confidential",
+			form:     url.Values{"client_id": {"public"}},
+			want:     http.StatusBadRequest,
+			error:    "invalid_grant",
+		},
+		{
+			name:     "token of a login to this service",
+			issuedTo: "",
+			form:     url.Values{"client_id": {"public"}},
+			want:     http.StatusBadRequest,
+			error:    "invalid_grant",
+		},
+		{
+			name:     "confidential client with its secret",
+			issuedTo: "confidential",
+			basic:    []string{"confidential", "the-client-secret"},
+			want:     http.StatusOK,
+		},
+		{
+			name:     "public client",
+			issuedTo: "public",
+			form:     url.Values{"client_id": {"public"}},
+			want:     http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			refresh, err := u.tokens.CreateRefreshToken(user.Id, tt.issuedTo, "", "", amrPassword, time.Hour)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			form := url.Values{"grant_type": {"refresh_token"}, "refresh_token": {refresh}}
+			for key, values := range tt.form {
+				form[key] = values
+			}
+
+			req, err := http.NewRequest(http.MethodPost, "/oauth/token", strings.NewReader(form.Encode()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			if tt.basic != nil {
+				req.SetBasicAuth(tt.basic[0], tt.basic[1])
+			}
+
+			rr := httptest.NewRecorder()
+			http.HandlerFunc(o.Exchange).ServeHTTP(rr, req)
+
+			var got responses.OAuthError
+			_ = json.NewDecoder(rr.Body).Decode(&got)
+
+			assert.Equal(t, tt.want, rr.Code)
+			assert.Equal(t, tt.error, got.Error)
+		})
+	}
+}
+
+func TestOAuth_redirectError(t *testing.T) {
+	tests := []struct {
+		name string
+		req  *authorizeRequest
+		want url.Values
+	}{
+		{
+			name: "with state",
+			req:  &authorizeRequest{RedirectURI: "https://app.example.com/callback", State: "xyz"},
+			want: url.Values{"error": {"access_denied"}, "error_description": {"denied"}, "state": {"xyz"}},
+		},
+		{
+			name: "without state keeps existing query",
+			req:  &authorizeRequest{RedirectURI: "https://app.example.com/callback?tenant=a"},
+			want: url.Values{"error": {"access_denied"}, "error_description": {"denied"}, "tenant": {"a"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/oauth/authorize", nil)
+			rr := httptest.NewRecorder()
+
+			(&OAuth{Logger: hclog.Default()}).redirectError(rr, r, tt.req, "access_denied", "denied")
+
+			location, err := url.Parse(rr.Header().Get("Location"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assert.Equal(t, http.StatusFound, rr.Code)
+			assert.Equal(t, "app.example.com", location.Host)
+			assert.Equal(t, tt.want, location.Query())
+		})
+	}
+}
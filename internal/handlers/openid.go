@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"encoding/json"
+	"github.com/gorilla/mux"
+	"github.com/hashicorp/go-hclog"
+	"github.com/knockbox/authentication/internal/client"
+	"github.com/knockbox/authentication/pkg/enums"
+	"github.com/knockbox/authentication/pkg/keyring"
+	"github.com/knockbox/authentication/pkg/middleware"
+	"github.com/knockbox/authentication/pkg/responses"
+	"github.com/knockbox/authentication/pkg/utils"
+	"net/http"
+	"strings"
+)
+
+// OpenID provides the OpenID Connect discovery and userinfo endpoints.
+type OpenID struct {
+	hclog.Logger
+	*keyring.KeySet
+	c      *client.UserClient
+	tokens *client.TokenClient
+	config *utils.TokenConfig
+}
+
+// GetConfiguration returns the provider metadata describing this service.
+func (o *OpenID) GetConfiguration(w http.ResponseWriter, r *http.Request) {
+	base := strings.TrimSuffix(o.config.Issuer, "/")
+
+	var algs []string
+	for _, alg := range o.GetAlgorithms() {
+		algs = append(algs, alg.String())
+	}
+
+	configuration := &responses.OpenIDConfiguration{
+		Issuer:                           o.config.Issuer,
+		AuthorizationEndpoint:            base + "/oauth/authorize",
+		TokenEndpoint:                    base + "/oauth/token",
+		UserinfoEndpoint:                 base + "/userinfo",
+		JwksURI:                          base + "/api/jwks",
+		IntrospectionEndpoint:            base + "/api/token/introspect",
+		ScopesSupported:                  strings.Fields(enums.ScopesToString(enums.Scopes)),
+		ResponseTypesSupported:           []string{"code"},
+		GrantTypesSupported:              []string{"authorization_code", "refresh_token", "client_credentials"},
+		SubjectTypesSupported:            []string{"public"},
+		IdTokenSigningAlgValuesSupported: algs,
+		TokenEndpointAuthMethods:         []string{"none", "client_secret_basic", "client_secret_post"},
+		CodeChallengeMethodsSupported:    []string{"S256"},
+		ClaimsSupported: []string{
+			"sub", "iss", "aud", "exp", "iat", "auth_time", "nonce", "amr", "azp", "scope", "preferred_username", "email",
+			"email_verified", "name", "picture", "website",
+		},
+	}
+
+	configuration.Encode(w)
+}
+
+// GetUserInfo returns the standard claims of the user the bearer token was issued to, limited by its scopes as in
+// the ID token.
+func (o *OpenID) GetUserInfo(w http.ResponseWriter, r *http.Request) {
+	p, ok := middleware.GetPrincipal(r)
+	if !ok {
+		w.WriteHeader(http.StatusUnauthorized)
+		o.Warn("OpenID.GetUserInfo principal was expected and should have existed but was not found")
+		return
+	}
+
+	user, err := o.c.GetUserByAccountId(p.Subject)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		o.Error("failed to get user by account_id", "err", err)
+		return
+	}
+
+	if user == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	details, err := o.c.GetUserDetailsByUserId(user.Id)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		o.Error("failed to get user details by user_id", "err", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	info := user.UserInfo(details, p.HasScope(enums.ScopeProfile), p.HasScope(enums.ScopeEmail))
+	_ = json.NewEncoder(w).Encode(info)
+}
+
+// Route registers the endpoints on the root router, discovery documents are not under /api.
+func (o *OpenID) Route(r *mux.Router) {
+	bearer := middleware.UseLocalBearerToken(o.Logger, o.KeySet, o.tokens)
+
+	r.HandleFunc("/.well-known/openid-configuration", o.GetConfiguration).Methods(http.MethodGet)
+
+	userinfoRouter := r.PathPrefix("/userinfo").Subrouter()
+	userinfoRouter.Use(
+		bearer.Middleware,
+		middleware.RequireRole(o.Logger, enums.User).Middleware,
+		middleware.RequireScope(o.Logger, enums.ScopeOpenID).Middleware,
+	)
+	userinfoRouter.HandleFunc("", o.GetUserInfo).Methods(http.MethodGet, http.MethodPost)
+}
+
+func NewOpenID(l hclog.Logger, ks *keyring.KeySet) *OpenID {
+	db, err := utils.MySQLConnection()
+	if err != nil {
+		panic(err)
+	}
+
+	return &OpenID{
+		Logger: l,
+		KeySet: ks,
+		c:      client.NewUserClient(db, l),
+		tokens: client.NewTokenClient(db, l),
+		config: utils.TokenConfigFromEnv(),
+	}
+}
@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"encoding/json"
+	"github.com/hashicorp/go-hclog"
+	"github.com/knockbox/authentication/pkg/keyring"
+	"github.com/knockbox/authentication/pkg/responses"
+	"github.com/knockbox/authentication/pkg/utils"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestOpenID_GetConfiguration(t *testing.T) {
+	keyset, _ := keyring.NewSet(1000, 500, hclog.Default())
+	keyset.SetKeyTypes(keyring.P256)
+	if err := keyset.Load(1); err != nil {
+		t.Fatal(err)
+	}
+
+	// Rotate onto the other type so the set holds a key of each.
+	keyset.SetKeyTypes(keyring.RS256)
+	if err := keyset.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		issuer string
+		want   responses.OpenIDConfiguration
+	}{
+		{
+			name:   "issuer without trailing slash",
+			issuer: "https://auth.example.com",
+			want: responses.OpenIDConfiguration{
+				Issuer:           "https://auth.example.com",
+				UserinfoEndpoint: "https://auth.example.com/userinfo",
+				JwksURI:          "https://auth.example.com/api/jwks",
+			},
+		},
+		{
+			name:   "issuer with trailing slash",
+			issuer: "https://auth.example.com/",
+			want: responses.OpenIDConfiguration{
+				Issuer:           "https://auth.example.com/",
+				UserinfoEndpoint: "https://auth.example.com/userinfo",
+				JwksURI:          "https://auth.example.com/api/jwks",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, "/.well-known/openid-configuration", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			rr := httptest.NewRecorder()
+			o := &OpenID{Logger: hclog.Default(), KeySet: keyset, config: &utils.TokenConfig{Issuer: tt.issuer}}
+			http.HandlerFunc(o.GetConfiguration).ServeHTTP(rr, req)
+
+			var got responses.OpenIDConfiguration
+			if err := json.NewDecoder(rr.Body).Decode(&got); err != nil {
+				t.Fatal(err)
+			}
+
+			assert.Equal(t, tt.want.Issuer, got.Issuer)
+			assert.Equal(t, tt.want.UserinfoEndpoint, got.UserinfoEndpoint)
+			assert.Equal(t, tt.want.JwksURI, got.JwksURI)
+			assert.ElementsMatch(t, []string{"ES256", "RS256"}, got.IdTokenSigningAlgValuesSupported)
+			assert.Contains(t, got.SubjectTypesSupported, "public")
+		})
+	}
+}
@@ -0,0 +1,346 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/knockbox/authentication/pkg/enums"
+	"github.com/knockbox/authentication/pkg/keyring"
+	"github.com/knockbox/authentication/pkg/models"
+	"github.com/knockbox/authentication/pkg/payloads"
+	"github.com/knockbox/authentication/pkg/responses"
+	"github.com/knockbox/authentication/pkg/utils"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+	"net/http"
+)
+
+// defaultPasskeyName names passkeys registered without one.
+const defaultPasskeyName = "Passkey"
+
+// PasskeyLoginOptions begins a passkey login. Any passkey of the relying party may answer, the user is found by
+// the passkey the browser picks.
+func (u *User) PasskeyLoginOptions(w http.ResponseWriter, r *http.Request) {
+	assertion, session, err := u.relyingParty.BeginDiscoverableLogin()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		u.Error("failed to begin passkey login", "err", err)
+		return
+	}
+
+	u.writeCeremony(w, models.CeremonyLogin, "", assertion, session)
+}
+
+// PasskeyLogin completes a passkey login, verifying the assertion and signature counter of the passkey. The
+// token is granted every scope the role of the user allows unless narrowed by the requested scope, as by Login.
+func (u *User) PasskeyLogin(w http.ResponseWriter, r *http.Request) {
+	payload := &payloads.PasskeyLogin{}
+	if utils.DecodeAndValidateStruct(w, r, payload) {
+		return
+	}
+
+	session, ok := u.consumeCeremony(w, payload.SessionToken, models.CeremonyLogin)
+	if !ok {
+		return
+	}
+
+	parsed, err := protocol.ParseCredentialRequestResponseBytes(payload.Credential)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		responses.NewGenericError("the credential is malformed").Encode(w)
+		return
+	}
+
+	var owner *models.WebAuthnUser
+	findOwner := func(_, userHandle []byte) (webauthn.User, error) {
+		accountId, err := uuid.FromBytes(userHandle)
+		if err != nil {
+			return nil, err
+		}
+
+		user, err := u.c.GetUserByAccountId(accountId.String())
+		if err != nil {
+			return nil, err
+		}
+
+		if user == nil {
+			return nil, errors.New("no user with the user handle")
+		}
+
+		owner, err = u.passkeys.GetWebAuthnUser(user)
+		return owner, err
+	}
+
+	_, credential, err := u.relyingParty.ValidatePasskeyLogin(findOwner, *session, parsed)
+	if err != nil {
+		if owner != nil {
+			recordHistory(u.c, u.Logger, owner.Id, r, enums.LoginFailed)
+		}
+
+		u.Debug("passkey login failed", "err", err)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnauthorized)
+		responses.NewGenericError("the passkey could not be verified").Encode(w)
+		return
+	}
+
+	user := owner.User
+	if user.Role == enums.Pending || user.Role.IsForbidden() {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusForbidden)
+		responses.NewGenericError("the user may not sign in").Encode(w)
+		return
+	}
+
+	// Synced passkeys report no counter, only one going backwards means a copy of the private key is in use.
+	if credential.Authenticator.CloneWarning {
+		u.Warn("passkey signature counter went backwards, it may have been cloned", "account_id", user.AccountId)
+		recordHistory(u.c, u.Logger, user.Id, r, enums.LoginFailed)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnauthorized)
+		responses.NewGenericError("the passkey could not be verified").Encode(w)
+		return
+	}
+
+	if err := u.passkeys.UsePasskey(owner.Passkey(credential.ID), credential); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		u.Error("failed to update passkey", "err", err)
+		return
+	}
+
+	token, err := u.issue(user, "", "", payload.Scope, amrPasskey)
+	if errors.Is(err, errInvalidScope) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		responses.NewGenericError("the requested scope is not allowed").Encode(w)
+		return
+	}
+
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		responses.NewGenericError("failed to issue token").Encode(w)
+
+		u.Error("failed to issue token", "err", err)
+		return
+	}
+
+	recordHistory(u.c, u.Logger, user.Id, r, enums.Login)
+	token.Encode(w)
+}
+
+// PasskeyRegistrationOptions begins registering a passkey for the bearer, given their current password and a
+// code from their authenticator app if they enabled one. A passkey signs in without either, so the bearer token
+// alone must not be enough to add one. Authenticators already holding one of their passkeys are excluded.
+func (u *User) PasskeyRegistrationOptions(w http.ResponseWriter, r *http.Request) {
+	payload := &payloads.PasskeyRegistrationOptions{}
+	if utils.DecodeAndValidateStruct(w, r, payload) {
+		return
+	}
+
+	user, ok := u.principalUser(w, r)
+	if !ok || !u.checkCurrentPassword(w, r, user, payload.CurrentPassword) || !u.checkTOTP(w, user, payload.Code) {
+		return
+	}
+
+	owner, err := u.passkeys.GetWebAuthnUser(user)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		u.Error("failed to get passkeys", "err", err)
+		return
+	}
+
+	exclusions := webauthn.Credentials(owner.WebAuthnCredentials()).CredentialDescriptors()
+	creation, session, err := u.relyingParty.BeginRegistration(owner, webauthn.WithExclusions(exclusions))
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		u.Error("failed to begin passkey registration", "err", err)
+		return
+	}
+
+	u.writeCeremony(w, models.CeremonyRegistration, user.AccountId.String(), creation, session)
+}
+
+// RegisterPasskey completes registering a passkey for the bearer, verifying the attestation of the credential.
+// The session token proves the bearer re-authenticated at PasskeyRegistrationOptions.
+func (u *User) RegisterPasskey(w http.ResponseWriter, r *http.Request) {
+	payload := &payloads.PasskeyRegister{}
+	if utils.DecodeAndValidateStruct(w, r, payload) {
+		return
+	}
+
+	user, ok := u.principalUser(w, r)
+	if !ok {
+		return
+	}
+
+	session, ok := u.consumeCeremony(w, payload.SessionToken, models.CeremonyRegistration)
+	if !ok {
+		return
+	}
+
+	parsed, err := protocol.ParseCredentialCreationResponseBytes(payload.Credential)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		responses.NewGenericError("the credential is malformed").Encode(w)
+		return
+	}
+
+	owner, err := u.passkeys.GetWebAuthnUser(user)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		u.Error("failed to get passkeys", "err", err)
+		return
+	}
+
+	// The session is bound to the user it was begun for, so it cannot be completed by another bearer.
+	credential, err := u.relyingParty.CreateCredential(owner, *session, parsed)
+	if err != nil {
+		u.Debug("passkey registration failed", "err", err)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		responses.NewGenericError("the passkey could not be verified").Encode(w)
+		return
+	}
+
+	name := payload.Name
+	if name == "" {
+		name = defaultPasskeyName
+	}
+
+	if err := u.passkeys.CreatePasskey(user.Id, name, credential); err != nil {
+		if utils.IsDuplicateEntry(err) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusConflict)
+			responses.NewGenericError("the passkey is already registered").Encode(w)
+			return
+		}
+
+		w.WriteHeader(http.StatusInternalServerError)
+		u.Error("failed to create passkey", "err", err)
+		return
+	}
+
+	recordHistory(u.c, u.Logger, user.Id, r, enums.AddPasskey)
+	w.WriteHeader(http.StatusCreated)
+}
+
+// GetPasskeys returns the passkeys of the bearer, oldest first.
+func (u *User) GetPasskeys(w http.ResponseWriter, r *http.Request) {
+	user, ok := u.principalUser(w, r)
+	if !ok {
+		return
+	}
+
+	passkeys, err := u.passkeys.GetPasskeys(user.Id)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		u.Error("failed to get passkeys", "err", err)
+		return
+	}
+
+	dtos := make([]*models.PasskeyDTO, len(passkeys))
+	for i := range passkeys {
+		dtos[i] = passkeys[i].DTO()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(dtos)
+}
+
+// DeletePasskey removes a passkey of the bearer by its base64url credential id.
+func (u *User) DeletePasskey(w http.ResponseWriter, r *http.Request) {
+	credentialId, err := base64.RawURLEncoding.DecodeString(mux.Vars(r)["credential_id"])
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		responses.NewGenericError("the provided credential_id failed to parse").Encode(w)
+		return
+	}
+
+	user, ok := u.principalUser(w, r)
+	if !ok {
+		return
+	}
+
+	deleted, err := u.passkeys.DeletePasskey(user.Id, credentialId)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		u.Error("failed to delete passkey", "err", err)
+		return
+	}
+
+	if !deleted {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	recordHistory(u.c, u.Logger, user.Id, r, enums.RemovePasskey)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// writeCeremony responds with the options of a ceremony and a signed token holding its session.
+func (u *User) writeCeremony(w http.ResponseWriter, ceremony, subject string, options interface{}, session *webauthn.SessionData) {
+	token, err := models.CreateWebAuthnSessionToken(u.config, ceremony, subject, session)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		u.Error("failed to create webauthn session token", "err", err)
+		return
+	}
+
+	signed, err := u.Sign(token, keyring.TypeWebAuthnToken)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		u.Error("failed to sign webauthn session token", "err", err)
+		return
+	}
+
+	responses.NewWebAuthnCeremony(options, signed).Encode(w)
+}
+
+// consumeCeremony verifies the session token of the ceremony and revokes it so each ceremony completes once,
+// responding and returning false if it cannot be used.
+func (u *User) consumeCeremony(w http.ResponseWriter, raw, ceremony string) (*webauthn.SessionData, bool) {
+	token, err := u.Verify(
+		[]byte(raw),
+		keyring.TypeWebAuthnToken,
+		jwt.WithIssuer(u.config.Issuer),
+		jwt.WithAudience(u.config.Issuer),
+		jwt.WithAcceptableSkew(u.config.ClockSkew),
+	)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnauthorized)
+		responses.NewGenericError("the session token is invalid or has expired").Encode(w)
+		return nil, false
+	}
+
+	session, err := models.WebAuthnSessionFrom(token, ceremony)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnauthorized)
+		responses.NewGenericError("the session token is invalid or has expired").Encode(w)
+		return nil, false
+	}
+
+	if err := u.tokens.RevokeToken(token); err != nil {
+		if utils.IsDuplicateEntry(err) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusUnauthorized)
+			responses.NewGenericError("the session token has already been used").Encode(w)
+			return nil, false
+		}
+
+		w.WriteHeader(http.StatusInternalServerError)
+		u.Error("failed to revoke webauthn session token", "err", err)
+		return nil, false
+	}
+
+	return session, true
+}
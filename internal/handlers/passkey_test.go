@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/hashicorp/go-hclog"
+	"github.com/knockbox/authentication/pkg/keyring"
+	"github.com/knockbox/authentication/pkg/models"
+	"github.com/knockbox/authentication/pkg/utils"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestUser_PasskeyLogin(t *testing.T) {
+	keyset, _ := keyring.NewSet(1000, 500, hclog.Default())
+	keyset.SetCurveTypes(keyring.P256)
+	if err := keyset.Load(1); err != nil {
+		t.Fatal(err)
+	}
+
+	config := &utils.TokenConfig{Issuer: "https://auth.knockbox.io", Audience: []string{"knockbox"}}
+
+	sign := func(typ, ceremony string, duration time.Duration) string {
+		session := &webauthn.SessionData{Challenge: "challenge", Expires: time.Now().Add(duration)}
+		token, err := models.CreateWebAuthnSessionToken(config, ceremony, "", session)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		signed, err := keyset.Sign(token, typ)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return string(signed)
+	}
+
+	credential := `{"id": "AAAA", "type": "public-key"}`
+
+	tests := []struct {
+		name string
+		body string
+		want int
+	}{
+		{
+			name: "missing credential",
+			body: `{"session_token": "` + sign(keyring.TypeWebAuthnToken, models.CeremonyLogin, time.Minute) + `"}`,
+			want: http.StatusBadRequest,
+		},
+		{
+			name: "missing session_token",
+			body: `{"credential": ` + credential + `}`,
+			want: http.StatusBadRequest,
+		},
+		{
+			name: "malformed session_token",
+			body: `{"session_token": "not-a-jwt", "credential": ` + credential + `}`,
+			want: http.StatusUnauthorized,
+		},
+		{
+			name: "mfa token typ",
+			body: `{"session_token": "` + sign(keyring.TypeMFAToken, models.CeremonyLogin, time.Minute) + `", "credential": ` + credential + `}`,
+			want: http.StatusUnauthorized,
+		},
+		{
+			name: "registration ceremony",
+			body: `{"session_token": "` + sign(keyring.TypeWebAuthnToken, models.CeremonyRegistration, time.Minute) + `", "credential": ` + credential + `}`,
+			want: http.StatusUnauthorized,
+		},
+		{
+			name: "expired session_token",
+			body: `{"session_token": "` + sign(keyring.TypeWebAuthnToken, models.CeremonyLogin, -time.Minute) + `", "credential": ` + credential + `}`,
+			want: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodPost, "/login/passkey", strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Content-Type", "application/json")
+
+			rr := httptest.NewRecorder()
+			u := &User{Logger: hclog.Default(), KeySet: keyset, issuer: &issuer{config: config}}
+			http.HandlerFunc(u.PasskeyLogin).ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.want, rr.Code)
+		})
+	}
+}
+
+func TestUser_PasskeyLoginOptions(t *testing.T) {
+	keyset, _ := keyring.NewSet(1000, 500, hclog.Default())
+	keyset.SetCurveTypes(keyring.P256)
+	if err := keyset.Load(1); err != nil {
+		t.Fatal(err)
+	}
+
+	config := &utils.TokenConfig{Issuer: "https://auth.knockbox.io", Audience: []string{"knockbox"}}
+	relyingParty, err := webauthn.New(utils.WebAuthnConfigFromEnv())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, "/login/passkey/options", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+	u := &User{Logger: hclog.Default(), KeySet: keyset, relyingParty: relyingParty, issuer: &issuer{config: config}}
+	http.HandlerFunc(u.PasskeyLoginOptions).ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), `"session_token"`)
+	assert.Contains(t, rr.Body.String(), `"challenge"`)
+}
+
+func TestUser_PasskeyRegistrationOptions(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want int
+	}{
+		{
+			name: "missing current_password",
+			body: `{}`,
+			want: http.StatusBadRequest,
+		},
+		{
+			name: "code that is not six digits",
+			body: `{"current_password": "password", "code": "12345a"}`,
+			want: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodPost, "/user/passkeys/options", strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Content-Type", "application/json")
+
+			rr := httptest.NewRecorder()
+			u := &User{Logger: hclog.Default()}
+			http.HandlerFunc(u.PasskeyRegistrationOptions).ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.want, rr.Code)
+		})
+	}
+}
@@ -2,15 +2,26 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"github.com/gorilla/mux"
 	"github.com/hashicorp/go-hclog"
+	"github.com/knockbox/authentication/internal/client"
 	"github.com/knockbox/authentication/pkg/keyring"
+	"github.com/knockbox/authentication/pkg/middleware"
+	"github.com/knockbox/authentication/pkg/payloads"
+	"github.com/knockbox/authentication/pkg/responses"
+	"github.com/knockbox/authentication/pkg/utils"
+	"github.com/lestrrat-go/jwx/v2/jwt"
 	"net/http"
 )
 
 type Token struct {
 	hclog.Logger
 	*keyring.KeySet
+	c       *client.UserClient
+	tokens  *client.TokenClient
+	clients middleware.ClientAuthenticator
+	*issuer
 }
 
 // GetJWKs returns the known public keys that are currently being used for signing.
@@ -19,13 +30,191 @@ func (t *Token) GetJWKs(w http.ResponseWriter, r *http.Request) {
 	_ = json.NewEncoder(w).Encode(t.GetPublicKeySet())
 }
 
+// Refresh exchanges a refresh token for a new access and refresh token. Presenting a refresh token that has
+// already been exchanged revokes every token in its family.
+func (t *Token) Refresh(w http.ResponseWriter, r *http.Request) {
+	payload := &payloads.TokenRefresh{}
+	if utils.DecodeAndValidateStruct(w, r, payload) {
+		return
+	}
+
+	token, err := t.refresh(payload.RefreshToken, "")
+	if errors.Is(err, errInvalidGrant) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnauthorized)
+		responses.NewGenericError("invalid refresh token").Encode(w)
+		return
+	}
+
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		responses.NewGenericError("failed to issue token").Encode(w)
+
+		t.Error("failed to refresh token", "err", err)
+		return
+	}
+
+	token.Encode(w)
+}
+
+// Introspect describes the state of an access or refresh token as defined by RFC 7662.
+func (t *Token) Introspect(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		responses.NewGenericError("malformed body, expected form").Encode(w)
+		return
+	}
+
+	raw := r.PostForm.Get("token")
+	if raw == "" {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		responses.NewGenericError("token was not provided").Encode(w)
+		return
+	}
+
+	// The hint only decides which type is tried first.
+	introspectors := []func(string) (*responses.Introspection, error){t.introspectAccessToken, t.introspectRefreshToken}
+	if r.PostForm.Get("token_type_hint") == "refresh_token" {
+		introspectors[0], introspectors[1] = introspectors[1], introspectors[0]
+	}
+
+	for _, introspect := range introspectors {
+		result, err := introspect(raw)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			t.Error("failed to introspect token", "err", err)
+			return
+		}
+
+		if result.Active {
+			result.Encode(w)
+			return
+		}
+	}
+
+	responses.NewInactiveIntrospection().Encode(w)
+}
+
+// introspectAccessToken verifies the jwt against the keyring.KeySet and the denylist. Tokens of users who have
+// since been banned or locked are inactive.
+func (t *Token) introspectAccessToken(raw string) (*responses.Introspection, error) {
+	token, err := t.Verify(
+		[]byte(raw),
+		keyring.TypeAccessToken,
+		jwt.WithIssuer(t.config.Issuer),
+		jwt.WithAcceptableSkew(t.config.ClockSkew),
+	)
+	if err != nil {
+		return responses.NewInactiveIntrospection(), nil
+	}
+
+	revoked, err := t.tokens.IsRevoked(token)
+	if err != nil {
+		return nil, err
+	}
+
+	if revoked {
+		return responses.NewInactiveIntrospection(), nil
+	}
+
+	claims := token.PrivateClaims()
+	scope, _ := claims["scope"].(string)
+	clientId, _ := claims["client_id"].(string)
+	username, _ := claims["username"].(string)
+	role, _ := claims["role"].(string)
+
+	// Only tokens of users carry a role, clients act on their own behalf.
+	if role != "" {
+		user, err := t.c.GetUserByAccountId(token.Subject())
+		if err != nil {
+			return nil, err
+		}
+
+		if user == nil || user.Role.IsForbidden() {
+			return responses.NewInactiveIntrospection(), nil
+		}
+	}
+
+	return &responses.Introspection{
+		Active:    true,
+		Scope:     scope,
+		ClientId:  clientId,
+		Username:  username,
+		TokenType: "access_token",
+		Exp:       token.Expiration().Unix(),
+		Iat:       token.IssuedAt().Unix(),
+		Nbf:       token.NotBefore().Unix(),
+		Sub:       token.Subject(),
+		Aud:       token.Audience(),
+		Iss:       token.Issuer(),
+		Jti:       token.JwtID(),
+		Role:      role,
+	}, nil
+}
+
+// introspectRefreshToken looks up the refresh token, only unused tokens of permitted users are active.
+func (t *Token) introspectRefreshToken(raw string) (*responses.Introspection, error) {
+	refreshToken, err := t.tokens.GetRefreshToken(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	if refreshToken == nil || refreshToken.RevokedAt != nil || refreshToken.UsedAt != nil || refreshToken.IsExpired() {
+		return responses.NewInactiveIntrospection(), nil
+	}
+
+	user, err := t.c.GetUserById(int(refreshToken.UserId))
+	if err != nil {
+		return nil, err
+	}
+
+	if user == nil || user.Role.IsForbidden() {
+		return responses.NewInactiveIntrospection(), nil
+	}
+
+	return &responses.Introspection{
+		Active:    true,
+		Scope:     refreshToken.Scope,
+		ClientId:  refreshToken.ClientId,
+		Username:  user.Username,
+		TokenType: "refresh_token",
+		Exp:       refreshToken.ExpiresAt.Unix(),
+		Iat:       refreshToken.IssuedAt.Unix(),
+		Sub:       user.AccountId.String(),
+		Iss:       t.config.Issuer,
+		Role:      string(user.Role),
+	}, nil
+}
+
 func (t *Token) Route(r *mux.Router) {
+	basic := middleware.UseBasicAuth(t.Logger, t.clients, "introspection")
+
 	r.HandleFunc("/jwks", t.GetJWKs).Methods(http.MethodGet)
+
+	tokenRouter := r.PathPrefix("/token").Subrouter()
+	tokenRouter.HandleFunc("/refresh", t.Refresh).Methods(http.MethodPost)
+	tokenRouter.Handle("/introspect", basic.Middleware(http.HandlerFunc(t.Introspect))).Methods(http.MethodPost)
 }
 
 func NewToken(l hclog.Logger, ks *keyring.KeySet) *Token {
+	db, err := utils.MySQLConnection()
+	if err != nil {
+		panic(err)
+	}
+
+	users := client.NewUserClient(db, l)
+	tokens := client.NewTokenClient(db, l)
+
+	// Introspection is available to any registered confidential client.
 	return &Token{
-		Logger: l,
-		KeySet: ks,
+		Logger:  l,
+		KeySet:  ks,
+		c:       users,
+		tokens:  tokens,
+		clients: client.NewOAuthClient(db, l),
+		issuer:  newIssuer(ks, utils.TokenConfigFromEnv(), users, tokens, l),
 	}
 }
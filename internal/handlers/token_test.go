@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"encoding/json"
+	"github.com/hashicorp/go-hclog"
+	"github.com/knockbox/authentication/pkg/enums"
+	"github.com/knockbox/authentication/pkg/responses"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestToken_Introspect(t *testing.T) {
+	u, store := newMemoryUser(t)
+	user := store.addUser(t, "the-current-password")
+
+	issued, err := u.issue(user, "", "", "user:read", amrPassword)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tk := &Token{Logger: hclog.NewNullLogger(), KeySet: u.KeySet, c: u.c, tokens: u.tokens, issuer: u.issuer}
+
+	tests := []struct {
+		name   string
+		token  string
+		role   enums.UserRole
+		active bool
+		scope  string
+	}{
+		{
+			name:   "access token",
+			token:  issued.AccessToken,
+			role:   enums.User,
+			active: true,
+			scope:  "user:read",
+		},
+		{
+			name:   "refresh token",
+			token:  issued.RefreshToken,
+			role:   enums.User,
+			active: true,
+			scope:  "user:read",
+		},
+		{
+			name:  "access token of a banned user",
+			token: issued.AccessToken,
+			role:  enums.Banned,
+		},
+		{
+			name:  "access token of a locked user",
+			token: issued.AccessToken,
+			role:  enums.Locked,
+		},
+		{
+			name:  "refresh token of a banned user",
+			token: issued.RefreshToken,
+			role:  enums.Banned,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store.users[user.Id].Role = tt.role
+
+			form := url.Values{"token": {tt.token}}
+			req, err := http.NewRequest(http.MethodPost, "/token/introspect", strings.NewReader(form.Encode()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+			rr := httptest.NewRecorder()
+			http.HandlerFunc(tk.Introspect).ServeHTTP(rr, req)
+
+			var got responses.Introspection
+			if err := json.NewDecoder(rr.Body).Decode(&got); err != nil {
+				t.Fatal(err)
+			}
+
+			assert.Equal(t, http.StatusOK, rr.Code)
+			assert.Equal(t, tt.active, got.Active)
+			assert.Equal(t, tt.scope, got.Scope)
+		})
+	}
+}
@@ -2,11 +2,16 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/go-webauthn/webauthn/webauthn"
 	"github.com/google/uuid"
 	"github.com/gorilla/mux"
 	"github.com/hashicorp/go-hclog"
 	"github.com/knockbox/authentication/internal/client"
+	"github.com/knockbox/authentication/pkg/enums"
 	"github.com/knockbox/authentication/pkg/keyring"
+	"github.com/knockbox/authentication/pkg/mail"
 	"github.com/knockbox/authentication/pkg/middleware"
 	"github.com/knockbox/authentication/pkg/models"
 	"github.com/knockbox/authentication/pkg/payloads"
@@ -20,17 +25,26 @@ import (
 type User struct {
 	hclog.Logger
 	*keyring.KeySet
-	c *client.UserClient
+	c            *client.UserClient
+	mfa          *client.MFAClient
+	throttle     *client.LoginThrottleClient
+	passkeys     *client.PasskeyClient
+	relyingParty *webauthn.WebAuthn
+	mail         mail.Sender
+	account      *utils.AccountConfig
+	*issuer
 }
 
-// Register handles user registration.
+// Register handles user registration. The user is pending until they follow the verification link emailed to
+// them.
 func (u *User) Register(w http.ResponseWriter, r *http.Request) {
 	payload := &payloads.UserRegister{}
 	if utils.DecodeAndValidateStruct(w, r, payload) {
 		return
 	}
 
-	if err := u.c.RegisterUser(payload); err != nil {
+	user, err := u.c.RegisterUser(payload)
+	if err != nil {
 		if utils.IsDuplicateEntry(err) {
 			w.Header().Set("Content-Type", "application/json")
 			w.WriteHeader(http.StatusBadRequest)
@@ -45,10 +59,306 @@ func (u *User) Register(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	recordHistory(u.c, u.Logger, user.Id, r, enums.Register)
+
+	// The user can ask for another link, so failing to send this one does not fail the registration.
+	if err := u.sendVerification(user, models.OriginFromRequest(r)); err != nil {
+		u.Error("failed to send verification email", "account_id", user.AccountId, "err", err)
+	}
+
 	w.WriteHeader(http.StatusCreated)
 }
 
-// Login handles user login.
+// VerifyEmail consumes the token of a verification link, from the query of a GET or the body of a POST. Each link
+// may only be used once and only verifies the email it was sent to. Links sent to confirm a new email replace the
+// email of the user instead.
+func (u *User) VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	payload := &payloads.EmailVerification{Token: r.URL.Query().Get("token")}
+	if r.Method == http.MethodPost && utils.DecodeAndValidateStruct(w, r, payload) {
+		return
+	}
+
+	if payload.Token == "" {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		responses.NewGenericError("token was not provided").Encode(w)
+		return
+	}
+
+	token, err := u.Verify(
+		[]byte(payload.Token),
+		keyring.TypeVerificationToken,
+		jwt.WithIssuer(u.config.Issuer),
+		jwt.WithAudience(u.config.Issuer),
+		jwt.WithAcceptableSkew(u.config.ClockSkew),
+	)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		responses.NewGenericError("the verification link is invalid or has expired").Encode(w)
+		return
+	}
+
+	user, err := u.c.GetUserByAccountId(token.Subject())
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		u.Error("failed to get user by account_id", "err", err)
+		return
+	}
+
+	if user == nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		responses.NewGenericError("the verification link is invalid or has expired").Encode(w)
+		return
+	}
+
+	email, isChange := user.EmailChangedBy(token)
+	if !isChange && !user.IsVerifiedBy(token) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		responses.NewGenericError("the verification link is invalid or has expired").Encode(w)
+		return
+	}
+
+	// Revoking first makes the link single use, a concurrent request with the same link hits the unique jti.
+	if err := u.tokens.RevokeToken(token); err != nil {
+		if utils.IsDuplicateEntry(err) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			responses.NewGenericError("the verification link has already been used").Encode(w)
+			return
+		}
+
+		w.WriteHeader(http.StatusInternalServerError)
+		u.Error("failed to revoke verification token", "err", err)
+		return
+	}
+
+	if isChange {
+		if err := u.c.UpdateUserEmail(user, email, models.OriginFromRequest(r)); err != nil {
+			if utils.IsDuplicateEntry(err) {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusBadRequest)
+				responses.NewGenericError("a user with the provided email already exists").Encode(w)
+				return
+			}
+
+			w.WriteHeader(http.StatusInternalServerError)
+			u.Error("failed to update user email", "err", err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	if err := u.c.VerifyUserEmail(user, models.OriginFromRequest(r)); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		u.Error("failed to verify user email", "err", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ResendVerification emails a new verification link to an unverified user. It always responds with 202 before
+// looking the user up, and the email is sent in the background, so neither the response nor its timing reveals
+// which emails are registered.
+func (u *User) ResendVerification(w http.ResponseWriter, r *http.Request) {
+	payload := &payloads.EmailVerificationResend{}
+	if utils.DecodeAndValidateStruct(w, r, payload) {
+		return
+	}
+
+	origin := models.OriginFromRequest(r)
+	go u.resendVerification(payload.Email, origin)
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// resendVerification sends the verification email for ResendVerification, logging any failure.
+func (u *User) resendVerification(email string, origin models.Origin) {
+	user, err := u.c.GetUserByEmail(email)
+	if err != nil {
+		u.Error("failed to get user by email", "err", err)
+		return
+	}
+
+	if user == nil {
+		return
+	}
+
+	details, err := u.c.GetUserDetailsByUserId(user.Id)
+	if err != nil {
+		u.Error("failed to get user details by user_id", "err", err)
+		return
+	}
+
+	if details != nil && details.Verified {
+		return
+	}
+
+	if err := u.sendVerification(user, origin); err != nil && !errors.Is(err, errThrottled) {
+		u.Error("failed to send verification email", "account_id", user.AccountId, "err", err)
+	}
+}
+
+// ForgotPassword emails a password reset link to the user with the given email. It always responds with 202
+// before looking the user up, and the email is sent in the background, so neither the response nor its timing
+// reveals which emails are registered.
+func (u *User) ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	payload := &payloads.PasswordForgot{}
+	if utils.DecodeAndValidateStruct(w, r, payload) {
+		return
+	}
+
+	origin := models.OriginFromRequest(r)
+	go u.forgotPassword(payload.Email, origin)
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// forgotPassword sends the password reset email for ForgotPassword, logging any failure.
+func (u *User) forgotPassword(email string, origin models.Origin) {
+	user, err := u.c.GetUserByEmail(email)
+	if err != nil {
+		u.Error("failed to get user by email", "err", err)
+		return
+	}
+
+	if user == nil {
+		return
+	}
+
+	if err := u.sendPasswordReset(user, origin); err != nil && !errors.Is(err, errThrottled) {
+		u.Error("failed to send password reset email", "account_id", user.AccountId, "err", err)
+	}
+}
+
+// ResetPassword consumes a password reset token and sets the new password. Every session and passkey of the user
+// is ended, as is every other outstanding reset, so whoever had access to the account loses it.
+func (u *User) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	payload := &payloads.PasswordReset{}
+	if utils.DecodeAndValidateStruct(w, r, payload) {
+		return
+	}
+
+	reset, err := u.c.GetPasswordReset(payload.Token)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		u.Error("failed to get password reset", "err", err)
+		return
+	}
+
+	if reset == nil || reset.UsedAt != nil || reset.IsExpired() {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		responses.NewGenericError("the reset link is invalid or has expired").Encode(w)
+		return
+	}
+
+	ok, err := u.c.UsePasswordReset(reset)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		u.Error("failed to use password reset", "err", err)
+		return
+	}
+
+	if !ok {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		responses.NewGenericError("the reset link is invalid or has expired").Encode(w)
+		return
+	}
+
+	user, err := u.c.GetUserById(int(reset.UserId))
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		u.Error("failed to get user by id", "err", err)
+		return
+	}
+
+	if user == nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		responses.NewGenericError("the reset link is invalid or has expired").Encode(w)
+		return
+	}
+
+	if err := u.c.ResetPassword(user, payload.Password, models.OriginFromRequest(r)); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		u.Error("failed to reset password", "err", err)
+		return
+	}
+
+	if err := u.tokens.RevokeSessions(user, u.GetTokenDuration()); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		u.Error("failed to revoke sessions", "err", err)
+		return
+	}
+
+	removed, err := u.passkeys.DeletePasskeys(user.Id)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		u.Error("failed to delete passkeys", "err", err)
+		return
+	}
+
+	if removed > 0 {
+		recordHistory(u.c, u.Logger, user.Id, r, enums.RemovePasskey)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// errThrottled is returned when an email was already sent to the user within the resend interval.
+var errThrottled = errors.New("an email was sent too recently")
+
+// sendVerification emails the user a link to verify their current email, at most once per resend interval.
+func (u *User) sendVerification(user *models.User, origin models.Origin) error {
+	count, err := u.c.CountRecentHistory(user.Id, enums.SendVerification, u.account.ResendInterval)
+	if err != nil {
+		return err
+	}
+
+	if count > 0 {
+		return errThrottled
+	}
+
+	token, err := user.CreateVerificationToken(u.config, u.account.VerificationLifespan)
+	if err != nil {
+		return err
+	}
+
+	signed, err := u.Sign(token, keyring.TypeVerificationToken)
+	if err != nil {
+		return err
+	}
+
+	link, err := u.account.VerificationLink(string(signed))
+	if err != nil {
+		return err
+	}
+
+	err = u.mail.Send(mail.Message{
+		To:      user.Email,
+		Subject: "Verify your email address",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nFollow the link below to verify your email address. It expires in %s.\n\n%s\n",
+			user.Username, u.account.VerificationLifespan, link,
+		),
+	})
+	if err != nil {
+		return err
+	}
+
+	return u.c.RecordHistory(user.Id, origin, enums.SendVerification)
+}
+
+// Login handles user login. The token is granted every scope the role of the user allows unless narrowed by the
+// requested scope. Users with two-factor authentication are instead given an MFA token to complete the login at
+// LoginMFA. Failed logins are throttled per account and per IP address, a throttled login is refused with 429.
 func (u *User) Login(w http.ResponseWriter, r *http.Request) {
 	payload := &payloads.UserLogin{}
 	if utils.DecodeAndValidateStruct(w, r, payload) {
@@ -63,42 +373,81 @@ func (u *User) Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	attempt, wait, err := u.throttle.Attempt(user, utils.GetIpAddress(r))
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		u.Error("failed to get login failures", "err", err)
+		return
+	}
+
+	if wait > 0 {
+		writeTooManyAttempts(w, wait)
+		return
+	}
+	defer releaseLoginAttempt(u.throttle, u.Logger, attempt)
+
 	if user == nil {
+		recordLoginFailure(u.c, u.throttle, u.Logger, r, nil, attempt)
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusUnauthorized)
 		return
 	}
 
 	if !utils.ComparePasswords(user.Password, payload.Password) {
+		recordLoginFailure(u.c, u.throttle, u.Logger, r, user, attempt)
 		w.WriteHeader(http.StatusUnauthorized)
 		return
 	}
 
-	token, err := user.CreateToken(u.GetTokenDuration())
-	if err != nil {
+	if user.Role == enums.Pending {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusForbidden)
+		responses.NewGenericError("the email address of the user has not been verified").Encode(w)
+		return
+	}
+
+	if user.Role.IsForbidden() {
 		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusForbidden)
+		responses.NewGenericError("account locked").Encode(w)
+		return
+	}
+
+	hasTOTP, err := u.mfa.HasTOTP(user.Id)
+	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
-		responses.NewGenericError("failed to create token").Encode(w)
+		u.Error("failed to get totp", "err", err)
+		return
+	}
 
-		u.Error("failed to create token", "err", err)
+	if hasTOTP {
+		u.challengeMFA(w, user, payload.Scope)
+		return
+	}
+
+	token, err := u.issue(user, "", "", payload.Scope, amrPassword)
+	if errors.Is(err, errInvalidScope) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		responses.NewGenericError("the requested scope is not allowed").Encode(w)
 		return
 	}
 
-	key := u.GetRandomKey()
-	bs, err := jwt.Sign(token, jwt.WithKey(key.Algorithm(), key))
 	if err != nil {
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusInternalServerError)
-		responses.NewGenericError("failed to sign token").Encode(w)
+		responses.NewGenericError("failed to issue token").Encode(w)
 
-		u.Error("failed to sign token", "err", err)
+		u.Error("failed to issue token", "err", err)
 		return
 	}
 
-	responses.NewBearerToken(bs, int(u.GetTokenDuration().Seconds())).Encode(w)
+	resetLoginFailures(u.throttle, u.Logger, user)
+	recordHistory(u.c, u.Logger, user.Id, r, enums.Login)
+	token.Encode(w)
 }
 
-// GetByAccountId returns a user by their account_id.
+// GetByAccountId returns a user by their account_id, with the fields the bearer token, if any, may see.
 func (u *User) GetByAccountId(w http.ResponseWriter, r *http.Request) {
 	accountId, ok := mux.Vars(r)["account_id"]
 	if !ok {
@@ -127,11 +476,19 @@ func (u *User) GetByAccountId(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	p, _ := middleware.GetPrincipal(r)
+	dto := user.DTO(p)
+	if err := u.includeProfile(r, user, dto); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		u.Error("failed to get user details by user_id", "err", err)
+		return
+	}
+
 	w.Header().Set("Content-Type", "application/json")
-	_ = json.NewEncoder(w).Encode(user.DTO())
+	_ = json.NewEncoder(w).Encode(dto)
 }
 
-// GetByUsername returns a user by their username.
+// GetByUsername returns a user by their username, with the fields the bearer token, if any, may see.
 func (u *User) GetByUsername(w http.ResponseWriter, r *http.Request) {
 	username, ok := mux.Vars(r)["username"]
 	if !ok {
@@ -161,11 +518,20 @@ func (u *User) GetByUsername(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	p, _ := middleware.GetPrincipal(r)
+	dto := user.DTO(p)
+	if err := u.includeProfile(r, user, dto); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		u.Error("failed to get user details by user_id", "err", err)
+		return
+	}
+
 	w.Header().Set("Content-Type", "application/json")
-	_ = json.NewEncoder(w).Encode(user.DTO())
+	_ = json.NewEncoder(w).Encode(dto)
 }
 
-// GetLikeUsername returns all the users like the given username.
+// GetLikeUsername returns all the users like the given username, with the fields the bearer token, if any, may
+// see.
 func (u *User) GetLikeUsername(w http.ResponseWriter, r *http.Request) {
 	username, ok := mux.Vars(r)["username"]
 	if !ok {
@@ -198,49 +564,88 @@ func (u *User) GetLikeUsername(w http.ResponseWriter, r *http.Request) {
 
 	// TODO: Return the users and the paging result.
 	// TODO: Get the total.
+	p, _ := middleware.GetPrincipal(r)
 	var dtos []*models.UserDTO
 	for _, user := range users {
-		dtos = append(dtos, user.DTO())
+		dto := user.DTO(p)
+		if err := u.includeProfile(r, &user, dto); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			u.Error("failed to get user details by user_id", "err", err)
+			return
+		}
+
+		dtos = append(dtos, dto)
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	_ = json.NewEncoder(w).Encode(dtos)
 }
 
-// Update applies changes to the User based on the bearer token.
-func (u *User) Update(w http.ResponseWriter, r *http.Request) {
-	payload := &payloads.UserUpdate{}
-	if utils.DecodeAndValidateStruct(w, r, payload) {
+// GetProfile returns the public profile of a user by their account_id.
+func (u *User) GetProfile(w http.ResponseWriter, r *http.Request) {
+	accountId, ok := mux.Vars(r)["account_id"]
+	if !ok {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		responses.NewGenericError("account_id was not provided").Encode(w)
 		return
 	}
 
-	if !utils.PayloadHasChanges(*payload) {
+	if _, err := uuid.Parse(accountId); err != nil {
+		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusBadRequest)
+		responses.NewGenericError("the provided account_id failed to parse").Encode(w)
 		return
 	}
 
-	token, ok := r.Context().Value(middleware.BearerTokenContextKey).(*jwt.Token)
-	if !ok {
-		w.WriteHeader(http.StatusUnauthorized)
-		u.Warn("User.Update token was expected and should have existed but was not found")
+	user, err := u.c.GetUserByAccountId(accountId)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		u.Error("failed to get user by account_id", "err", err)
 		return
 	}
 
-	accountId, ok := (*token).Get("account_id")
-	if !ok {
-		w.WriteHeader(http.StatusUnauthorized)
-		u.Warn("User.Update token was missing claim 'account_id'")
+	if user == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	details, err := u.c.GetUserDetailsByUserId(user.Id)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		u.Error("failed to get user details by user_id", "err", err)
+		return
+	}
+
+	if details == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(details.DTO())
+}
+
+// UpdateProfile applies changes to the profile of the user of the bearer token.
+func (u *User) UpdateProfile(w http.ResponseWriter, r *http.Request) {
+	payload := &payloads.UserDetailsUpdate{}
+	if utils.DecodeAndValidateStruct(w, r, payload) {
+		return
+	}
+
+	if !utils.PayloadHasChanges(*payload) {
+		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
 
-	id, ok := accountId.(string)
+	p, ok := middleware.GetPrincipal(r)
 	if !ok {
 		w.WriteHeader(http.StatusUnauthorized)
-		u.Warn("User.Update token claim 'account_id' was not a string")
+		u.Warn("User.UpdateProfile principal was expected and should have existed but was not found")
 		return
 	}
 
-	user, err := u.c.GetUserByAccountId(id)
+	user, err := u.c.GetUserByAccountId(p.Subject)
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		u.Error("failed to get user by account_id", "err", err)
@@ -252,30 +657,600 @@ func (u *User) Update(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := u.c.UpdateUser(user, payload); err != nil {
+	details, err := u.c.GetUserDetailsByUserId(user.Id)
+	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
+		u.Error("failed to get user details by user_id", "err", err)
+		return
+	}
+
+	if details == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	if err := u.c.UpdateUserDetails(details, payload, models.OriginFromRequest(r)); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		u.Error("failed to update user details", "err", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(details.DTO())
+}
+
+// includeProfile adds the profile of the user to the dto when the request asks for it with include=profile.
+func (u *User) includeProfile(r *http.Request, user *models.User, dto *models.UserDTO) error {
+	if r.URL.Query().Get("include") != "profile" {
+		return nil
+	}
+
+	details, err := u.c.GetUserDetailsByUserId(user.Id)
+	if err != nil {
+		return err
+	}
+
+	if details != nil {
+		dto.Profile = details.DTO()
+	}
+
+	return nil
+}
+
+// Update applies changes to the User based on the bearer token. Changing the email or password requires the
+// current password, and a new password ends every session of the user. A new email is only applied once
+// confirmed through the link sent to it, in which case the response is 202. Confirmations are sent at most once
+// per resend interval.
+func (u *User) Update(w http.ResponseWriter, r *http.Request) {
+	payload := &payloads.UserUpdate{}
+	if utils.DecodeAndValidateStruct(w, r, payload) {
+		return
+	}
+
+	changes := *payload
+	changes.CurrentPassword = nil
+	if !utils.PayloadHasChanges(changes) {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	p, ok := middleware.GetPrincipal(r)
+	if !ok {
+		w.WriteHeader(http.StatusUnauthorized)
+		u.Warn("User.Update principal was expected and should have existed but was not found")
+		return
+	}
+
+	user, err := u.c.GetUserByAccountId(p.Subject)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		u.Error("failed to get user by account_id", "err", err)
+		return
+	}
+
+	if user == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	if !u.checkCurrentPassword(w, r, user, *payload.CurrentPassword) {
+		return
+	}
+
+	emailChanged := payload.Email != nil && *payload.Email != user.Email
+	if emailChanged {
+		existing, err := u.c.GetUserByEmail(*payload.Email)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			u.Error("failed to get user by email", "err", err)
+			return
+		}
+
+		if existing != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			responses.NewGenericError("a user with the provided email already exists").Encode(w)
+			return
+		}
+
+		// Checked before changing anything, so a throttled request applies no change at all.
+		count, err := u.c.CountRecentHistory(user.Id, enums.SendEmailChange, u.account.ResendInterval)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			u.Error("failed to count recent history", "err", err)
+			return
+		}
+
+		if count > 0 {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusTooManyRequests)
+			responses.NewGenericError("an email change was requested recently, try again later").Encode(w)
+			return
+		}
+	}
+
+	if payload.Password != nil {
+		if err := u.c.UpdateUser(user, payload, models.OriginFromRequest(r)); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			u.Error("failed to update user", "err", err)
+			return
+		}
+
+		if err := u.tokens.RevokeSessions(user, u.GetTokenDuration()); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			u.Error("failed to revoke sessions", "err", err)
+			return
+		}
+	}
+
+	if emailChanged {
+		if err := u.sendEmailChange(user, *payload.Email, models.OriginFromRequest(r)); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			u.Error("failed to send email change confirmation", "account_id", user.AccountId, "err", err)
+			return
+		}
+
+		w.WriteHeader(http.StatusAccepted)
 		return
 	}
 
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// UpdateRole changes the role of another user. The bearer may only change the role of a user they outrank, and
+// only to a role they outrank. Every token of the user is revoked so the new role takes effect immediately.
+func (u *User) UpdateRole(w http.ResponseWriter, r *http.Request) {
+	payload := &payloads.UserRoleUpdate{}
+	if utils.DecodeAndValidateStruct(w, r, payload) {
+		return
+	}
+
+	accountId, ok := mux.Vars(r)["account_id"]
+	if !ok {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		responses.NewGenericError("account_id was not provided").Encode(w)
+		return
+	}
+
+	if _, err := uuid.Parse(accountId); err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		responses.NewGenericError("the provided account_id failed to parse").Encode(w)
+		return
+	}
+
+	p, ok := middleware.GetPrincipal(r)
+	if !ok {
+		w.WriteHeader(http.StatusUnauthorized)
+		u.Warn("User.UpdateRole principal was expected and should have existed but was not found")
+		return
+	}
+
+	user, err := u.c.GetUserByAccountId(accountId)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		u.Error("failed to get user by account_id", "err", err)
+		return
+	}
+
+	if user == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	if !p.Role.Outranks(user.Role) || !p.Role.Outranks(payload.Role) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusForbidden)
+		responses.NewGenericError("roles may only be changed for users below your own, to a role below your own").Encode(w)
+		return
+	}
+
+	if user.Role == payload.Role {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	previous := user.Role
+	if err := u.c.UpdateUserRole(user, payload.Role, p.Subject, models.OriginFromRequest(r)); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		u.Error("failed to update user role", "err", err)
+		return
+	}
+
+	if err := u.tokens.RevokeSessions(user, u.GetTokenDuration()); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		u.Error("failed to revoke sessions", "err", err)
+		return
+	}
+
+	u.Info("user role changed", "account_id", accountId, "from", previous, "to", payload.Role, "by", p.Subject)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Unlock lifts the lock on a user after failed logins. A user locked for failing too many times in a row, or
+// otherwise holding enums.Locked, is restored to enums.User.
+func (u *User) Unlock(w http.ResponseWriter, r *http.Request) {
+	accountId, ok := mux.Vars(r)["account_id"]
+	if !ok {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		responses.NewGenericError("account_id was not provided").Encode(w)
+		return
+	}
+
+	if _, err := uuid.Parse(accountId); err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		responses.NewGenericError("the provided account_id failed to parse").Encode(w)
+		return
+	}
+
+	p, ok := middleware.GetPrincipal(r)
+	if !ok {
+		w.WriteHeader(http.StatusUnauthorized)
+		u.Warn("User.Unlock principal was expected and should have existed but was not found")
+		return
+	}
+
+	user, err := u.c.GetUserByAccountId(accountId)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		u.Error("failed to get user by account_id", "err", err)
+		return
+	}
+
+	if user == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	if err := u.throttle.Reset(user); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		u.Error("failed to reset login failures", "err", err)
+		return
+	}
+
+	if user.Role == enums.Locked {
+		if err := u.c.UpdateUserRole(user, enums.User, p.Subject, models.OriginFromRequest(r)); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			u.Error("failed to update user role", "err", err)
+			return
+		}
+	}
+
+	recordHistory(u.c, u.Logger, user.Id, r, enums.UnlockAccount)
+	u.Info("user unlocked", "account_id", accountId, "by", p.Subject)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Logout revokes the bearer token along with the refresh tokens of its session.
+func (u *User) Logout(w http.ResponseWriter, r *http.Request) {
+	p, ok := middleware.GetPrincipal(r)
+	if !ok {
+		w.WriteHeader(http.StatusUnauthorized)
+		u.Warn("User.Logout principal was expected and should have existed but was not found")
+		return
+	}
+
+	if err := u.tokens.RevokeToken(p.Token); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		u.Error("failed to revoke token", "err", err)
+		return
+	}
+
+	if p.SessionId != "" {
+		if err := u.tokens.RevokeRefreshTokenFamily(p.SessionId); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			u.Error("failed to revoke refresh token family", "err", err)
+			return
+		}
+	}
+
+	if !p.IsClient() {
+		user, err := u.c.GetUserByAccountId(p.Subject)
+		if err != nil {
+			u.Error("failed to get user by account_id", "err", err)
+		} else if user != nil {
+			recordHistory(u.c, u.Logger, user.Id, r, enums.Logout)
+		}
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// LogoutEverywhere revokes every token issued to the user of the bearer token.
+func (u *User) LogoutEverywhere(w http.ResponseWriter, r *http.Request) {
+	p, ok := middleware.GetPrincipal(r)
+	if !ok {
+		w.WriteHeader(http.StatusUnauthorized)
+		u.Warn("User.LogoutEverywhere principal was expected and should have existed but was not found")
+		return
+	}
+
+	user, err := u.c.GetUserByAccountId(p.Subject)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		u.Error("failed to get user by account_id", "err", err)
+		return
+	}
+
+	if user == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	if err := u.tokens.RevokeSessions(user, u.GetTokenDuration()); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		u.Error("failed to revoke sessions", "err", err)
+		return
+	}
+
+	recordHistory(u.c, u.Logger, user.Id, r, enums.Logout)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetHistory returns a page of the history of the user of the bearer token, most recent first.
+func (u *User) GetHistory(w http.ResponseWriter, r *http.Request) {
+	p, ok := middleware.GetPrincipal(r)
+	if !ok {
+		w.WriteHeader(http.StatusUnauthorized)
+		u.Warn("User.GetHistory principal was expected and should have existed but was not found")
+		return
+	}
+
+	user, err := u.c.GetUserByAccountId(p.Subject)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		u.Error("failed to get user by account_id", "err", err)
+		return
+	}
+
+	if user == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	u.writeHistory(w, r, user)
+}
+
+// GetHistoryByAccountId returns a page of the history of a user by their account_id, most recent first.
+func (u *User) GetHistoryByAccountId(w http.ResponseWriter, r *http.Request) {
+	accountId, ok := mux.Vars(r)["account_id"]
+	if !ok {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		responses.NewGenericError("account_id was not provided").Encode(w)
+		return
+	}
+
+	if _, err := uuid.Parse(accountId); err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		responses.NewGenericError("the provided account_id failed to parse").Encode(w)
+		return
+	}
+
+	user, err := u.c.GetUserByAccountId(accountId)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		u.Error("failed to get user by account_id", "err", err)
+		return
+	}
+
+	if user == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	u.writeHistory(w, r, user)
+}
+
+// writeHistory writes the page of the history of the user asked for by the request.
+func (u *User) writeHistory(w http.ResponseWriter, r *http.Request, user *models.User) {
+	page := models.PageFromRequest(r)
+	history, err := u.c.GetUserHistory(user.Id, page)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		u.Error("failed to get user history", "err", err)
+		return
+	}
+
+	if len(history) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	var dtos []*models.UserHistoryDTO
+	for _, entry := range history {
+		dtos = append(dtos, entry.DTO())
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(dtos)
+}
+
+// recordHistory adds the action to the history of the user. Failing to record it does not fail the request.
+func recordHistory(c *client.UserClient, l hclog.Logger, userId uint, r *http.Request, action enums.UserAction) {
+	if err := c.RecordHistory(userId, models.OriginFromRequest(r), action); err != nil {
+		l.Error("failed to record user history", "action", action, "err", err)
+	}
+}
+
+// sendEmailChange emails a link confirming the new email to that address, and lets the current address know a
+// change was requested.
+func (u *User) sendEmailChange(user *models.User, email string, origin models.Origin) error {
+	token, err := user.CreateEmailChangeToken(u.config, u.account.VerificationLifespan, email)
+	if err != nil {
+		return err
+	}
+
+	signed, err := u.Sign(token, keyring.TypeVerificationToken)
+	if err != nil {
+		return err
+	}
+
+	link, err := u.account.VerificationLink(string(signed))
+	if err != nil {
+		return err
+	}
+
+	err = u.mail.Send(mail.Message{
+		To:      email,
+		Subject: "Confirm your new email address",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nFollow the link below to use this email address for your account. It expires in %s.\n\n%s\n",
+			user.Username, u.account.VerificationLifespan, link,
+		),
+	})
+	if err != nil {
+		return err
+	}
+
+	err = u.mail.Send(mail.Message{
+		To:      user.Email,
+		Subject: "Your email address is being changed",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nA change of the email address of your account to %s was requested. It takes effect once "+
+				"confirmed from the new address.\n\nIf you did not request this, reset your password.\n",
+			user.Username, email,
+		),
+	})
+	if err != nil {
+		return err
+	}
+
+	return u.c.RecordHistory(user.Id, origin, enums.SendEmailChange)
+}
+
+// sendPasswordReset emails the user a link to reset their password, at most once per resend interval.
+func (u *User) sendPasswordReset(user *models.User, origin models.Origin) error {
+	count, err := u.c.CountRecentHistory(user.Id, enums.SendPasswordReset, u.account.ResendInterval)
+	if err != nil {
+		return err
+	}
+
+	if count > 0 {
+		return errThrottled
+	}
+
+	raw, err := u.c.CreatePasswordReset(user.Id, u.account.PasswordResetLifespan)
+	if err != nil {
+		return err
+	}
+
+	link, err := u.account.PasswordResetLink(raw)
+	if err != nil {
+		return err
+	}
+
+	err = u.mail.Send(mail.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nFollow the link below to choose a new password. It expires in %s.\n\n%s\n\n"+
+				"If you did not ask to reset your password you can ignore this email.\n",
+			user.Username, u.account.PasswordResetLifespan, link,
+		),
+	})
+	if err != nil {
+		return err
+	}
+
+	return u.c.RecordHistory(user.Id, origin, enums.SendPasswordReset)
+}
+
 func (u *User) Route(r *mux.Router) {
-	bearer := middleware.UseBearerToken(u.Logger)
+	bearer := middleware.UseLocalBearerToken(u.Logger, u.KeySet, u.tokens)
 
 	r.HandleFunc("/register", u.Register).Methods(http.MethodPost)
 	r.HandleFunc("/login", u.Login).Methods(http.MethodPost)
+	r.HandleFunc("/login/mfa", u.LoginMFA).Methods(http.MethodPost)
+	r.HandleFunc("/login/passkey/options", u.PasskeyLoginOptions).Methods(http.MethodPost)
+	r.HandleFunc("/login/passkey", u.PasskeyLogin).Methods(http.MethodPost)
+	r.HandleFunc("/verify-email", u.VerifyEmail).Methods(http.MethodGet, http.MethodPost)
+	r.HandleFunc("/verify-email/resend", u.ResendVerification).Methods(http.MethodPost)
+	r.HandleFunc("/password/forgot", u.ForgotPassword).Methods(http.MethodPost)
+	r.HandleFunc("/password/reset", u.ResetPassword).Methods(http.MethodPost)
+
+	// Registered ahead of /user/{account_id} which would otherwise match it.
+	historyRouter := r.PathPrefix("/user/history").Subrouter()
+	historyRouter.Use(
+		bearer.Middleware,
+		middleware.RequireRole(u.Logger, enums.User).Middleware,
+		middleware.RequireScope(u.Logger, enums.ScopeUserRead).Middleware,
+	)
+	historyRouter.HandleFunc("", u.GetHistory).Methods(http.MethodGet)
+
+	passkeysRouter := r.PathPrefix("/user/passkeys").Subrouter()
+	passkeysRouter.Use(
+		bearer.Middleware,
+		middleware.RequireRole(u.Logger, enums.User).Middleware,
+		middleware.RequireScope(u.Logger, enums.ScopeUserRead).Middleware,
+	)
+	passkeysRouter.HandleFunc("", u.GetPasskeys).Methods(http.MethodGet)
 
 	userRouter := r.PathPrefix("/user").Subrouter()
+	userRouter.Use(bearer.OptionalMiddleware)
 	userRouter.HandleFunc("/{account_id}", u.GetByAccountId).Methods(http.MethodGet)
+	userRouter.HandleFunc("/{account_id}/profile", u.GetProfile).Methods(http.MethodGet)
 	userRouter.HandleFunc("/username/{username}", u.GetByUsername).Methods(http.MethodGet)
 
 	searchRouter := userRouter.PathPrefix("/search").Subrouter()
 	searchRouter.HandleFunc("/{username}", u.GetLikeUsername).Methods(http.MethodGet)
 
+	logoutRouter := r.PathPrefix("/logout").Subrouter()
+	logoutRouter.Use(bearer.Middleware)
+	logoutRouter.HandleFunc("", u.Logout).Methods(http.MethodPost)
+	logoutRouter.HandleFunc("/all", u.LogoutEverywhere).Methods(http.MethodPost)
+
 	authorizedUserRouter := r.PathPrefix("/user").Subrouter()
-	authorizedUserRouter.Use(bearer.Middleware)
+	authorizedUserRouter.Use(
+		bearer.Middleware,
+		middleware.RequireRole(u.Logger, enums.User).Middleware,
+		middleware.RequireScope(u.Logger, enums.ScopeUserWrite).Middleware,
+	)
 	authorizedUserRouter.HandleFunc("", u.Update).Methods(http.MethodPut, http.MethodPatch)
+	authorizedUserRouter.HandleFunc("/profile", u.UpdateProfile).Methods(http.MethodPatch)
+	authorizedUserRouter.HandleFunc("/mfa/totp", u.EnrollTOTP).Methods(http.MethodPost)
+	authorizedUserRouter.HandleFunc("/mfa/totp", u.DisableTOTP).Methods(http.MethodDelete)
+	authorizedUserRouter.HandleFunc("/mfa/totp/confirm", u.ConfirmTOTP).Methods(http.MethodPost)
+	authorizedUserRouter.HandleFunc("/mfa/recovery-codes", u.RegenerateRecoveryCodes).Methods(http.MethodPost)
+	authorizedUserRouter.HandleFunc("/passkeys/options", u.PasskeyRegistrationOptions).Methods(http.MethodPost)
+	authorizedUserRouter.HandleFunc("/passkeys", u.RegisterPasskey).Methods(http.MethodPost)
+	authorizedUserRouter.HandleFunc("/passkeys/{credential_id}", u.DeletePasskey).Methods(http.MethodDelete)
+
+	moderationRouter := r.PathPrefix("/moderation/user").Subrouter()
+	moderationRouter.Use(
+		bearer.Middleware,
+		middleware.RequireRole(u.Logger, enums.Moderator).Middleware,
+		middleware.RequireScope(u.Logger, enums.ScopeUserRead).Middleware,
+	)
+	moderationRouter.HandleFunc("/{account_id}", u.GetByAccountId).Methods(http.MethodGet)
+
+	moderationHistoryRouter := r.PathPrefix("/moderation/user/{account_id}/history").Subrouter()
+	moderationHistoryRouter.Use(
+		bearer.Middleware,
+		middleware.RequireRole(u.Logger, enums.Admin).Middleware,
+		middleware.RequireScope(u.Logger, enums.ScopeUserRead).Middleware,
+	)
+	moderationHistoryRouter.HandleFunc("", u.GetHistoryByAccountId).Methods(http.MethodGet)
+
+	roleRouter := r.PathPrefix("/moderation/user/{account_id}/role").Subrouter()
+	roleRouter.Use(
+		bearer.Middleware,
+		middleware.RequireRole(u.Logger, enums.Moderator).Middleware,
+		middleware.RequireScope(u.Logger, enums.ScopeUserWrite).Middleware,
+	)
+	roleRouter.HandleFunc("", u.UpdateRole).Methods(http.MethodPut)
+
+	unlockRouter := r.PathPrefix("/moderation/user/{account_id}/unlock").Subrouter()
+	unlockRouter.Use(
+		bearer.Middleware,
+		middleware.RequireRole(u.Logger, enums.Admin).Middleware,
+		middleware.RequireScope(u.Logger, enums.ScopeUserWrite).Middleware,
+	)
+	unlockRouter.HandleFunc("", u.Unlock).Methods(http.MethodPost)
 }
 
 func NewUser(l hclog.Logger, ks *keyring.KeySet) *User {
@@ -284,9 +1259,23 @@ func NewUser(l hclog.Logger, ks *keyring.KeySet) *User {
 		panic(err)
 	}
 
+	relyingParty, err := webauthn.New(utils.WebAuthnConfigFromEnv())
+	if err != nil {
+		panic(err)
+	}
+
+	users := client.NewUserClient(db, l)
+
 	return &User{
-		Logger: l,
-		KeySet: ks,
-		c:      client.NewUserClient(db, l),
+		Logger:       l,
+		KeySet:       ks,
+		c:            users,
+		mfa:          newMFAClient(db, l),
+		throttle:     client.NewLoginThrottleClient(db, utils.LockoutConfigFromEnv(), l),
+		passkeys:     client.NewPasskeyClient(db, l),
+		relyingParty: relyingParty,
+		mail:         mail.NewSenderFromEnv(l),
+		account:      utils.AccountConfigFromEnv(),
+		issuer:       newIssuer(ks, utils.TokenConfigFromEnv(), users, client.NewTokenClient(db, l), l),
 	}
 }
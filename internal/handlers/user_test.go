@@ -0,0 +1,439 @@
+package handlers
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/hashicorp/go-hclog"
+	"github.com/knockbox/authentication/pkg/enums"
+	"github.com/knockbox/authentication/pkg/keyring"
+	"github.com/knockbox/authentication/pkg/middleware"
+	"github.com/knockbox/authentication/pkg/models"
+	"github.com/knockbox/authentication/pkg/utils"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestUser_VerifyEmail(t *testing.T) {
+	keyset, _ := keyring.NewSet(1000, 500, hclog.Default())
+	keyset.SetCurveTypes(keyring.P256)
+	if err := keyset.Load(1); err != nil {
+		t.Fatal(err)
+	}
+
+	config := &utils.TokenConfig{Issuer: "https://auth.knockbox.io", Audience: []string{"knockbox"}}
+	user := &models.User{AccountId: uuid.New(), Email: "user@knockbox.io"}
+
+	sign := func(typ string, duration time.Duration) string {
+		token, err := user.CreateVerificationToken(config, duration)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		signed, err := keyset.Sign(token, typ)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return string(signed)
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		query  string
+		body   string
+		want   int
+	}{
+		{
+			name:   "GET without a token",
+			method: http.MethodGet,
+			want:   http.StatusBadRequest,
+		},
+		{
+			name:   "POST without a token",
+			method: http.MethodPost,
+			body:   `{}`,
+			want:   http.StatusBadRequest,
+		},
+		{
+			name:   "GET with a malformed token",
+			method: http.MethodGet,
+			query:  "not-a-jwt",
+			want:   http.StatusBadRequest,
+		},
+		{
+			name:   "GET with an access token typ",
+			method: http.MethodGet,
+			query:  sign(keyring.TypeAccessToken, time.Hour),
+			want:   http.StatusBadRequest,
+		},
+		{
+			name:   "GET with an expired token",
+			method: http.MethodGet,
+			query:  sign(keyring.TypeVerificationToken, -time.Hour),
+			want:   http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(tt.method, "/verify-email?"+url.Values{"token": {tt.query}}.Encode(), strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Content-Type", "application/json")
+
+			rr := httptest.NewRecorder()
+			u := &User{Logger: hclog.Default(), KeySet: keyset, issuer: &issuer{config: config}}
+			http.HandlerFunc(u.VerifyEmail).ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.want, rr.Code)
+		})
+	}
+}
+
+func TestUser_ResetPassword(t *testing.T) {
+	u, store := newMemoryUser(t)
+	user := store.addUser(t, "the-current-password")
+	_, _ = store.passkeys.Create(models.Passkey{UserId: user.Id})
+
+	refresh, err := u.tokens.CreateRefreshToken(user.Id, "", "", "", amrPassword, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	access, err := user.CreateToken(u.config, time.Hour, "", amrPassword)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = access.Set(jwt.IssuedAtKey, time.Now().Add(-time.Second))
+
+	createReset := func(lifespan time.Duration) string {
+		raw, err := u.c.CreatePasswordReset(user.Id, lifespan)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return raw
+	}
+	expired, outstanding, valid := createReset(-time.Hour), createReset(time.Hour), createReset(time.Hour)
+
+	tests := []struct {
+		name  string
+		body  string
+		want  int
+		check func(t *testing.T)
+	}{
+		{
+			name: "missing token",
+			body: `{"password": "a-new-password"}`,
+			want: http.StatusBadRequest,
+		},
+		{
+			name: "password too short",
+			body: `{"token": "abc", "password": "short"}`,
+			want: http.StatusBadRequest,
+		},
+		{
+			name: "unknown token",
+			body: `{"token": "unknown", "password": "a-new-password"}`,
+			want: http.StatusBadRequest,
+		},
+		{
+			name: "expired token",
+			body: `{"token": "` + expired + `", "password": "a-new-password"}`,
+			want: http.StatusBadRequest,
+		},
+		{
+			name: "valid token",
+			body: `{"token": "` + valid + `", "password": "a-new-password"}`,
+			want: http.StatusNoContent,
+			check: func(t *testing.T) {
+				updated, _ := store.users.GetById(int(user.Id))
+				assert.True(t, utils.ComparePasswords(updated.Password, "a-new-password"))
+
+				token, _ := u.tokens.GetRefreshToken(refresh)
+				assert.NotNil(t, token.RevokedAt)
+
+				revoked, err := u.tokens.IsRevoked(access)
+				assert.NoError(t, err)
+				assert.True(t, revoked)
+
+				assert.Empty(t, store.passkeys)
+				assert.Equal(t, []enums.UserAction{enums.UpdatePassword, enums.RemovePasskey}, store.history.actions())
+			},
+		},
+		{
+			name: "token already used",
+			body: `{"token": "` + valid + `", "password": "another-password"}`,
+			want: http.StatusBadRequest,
+		},
+		{
+			name: "token outstanding before the reset",
+			body: `{"token": "` + outstanding + `", "password": "another-password"}`,
+			want: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodPost, "/password/reset", strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Content-Type", "application/json")
+
+			rr := httptest.NewRecorder()
+			http.HandlerFunc(u.ResetPassword).ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.want, rr.Code)
+			if tt.check != nil {
+				tt.check(t)
+			}
+		})
+	}
+}
+
+func TestUser_Update(t *testing.T) {
+	u, store := newMemoryUser(t)
+	user := store.addUser(t, "the-current-password")
+
+	taken := store.addUser(t, "another-password")
+	taken.Username, taken.Email = "taken", "taken@knockbox.io"
+	_, _ = store.users.Update(*taken)
+
+	access, err := user.CreateToken(u.config, time.Hour, "", amrPassword)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = access.Set(jwt.IssuedAtKey, time.Now().Add(-time.Second))
+
+	tests := []struct {
+		name  string
+		body  string
+		want  int
+		check func(t *testing.T)
+	}{
+		{
+			name: "password without current_password",
+			body: `{"password": "a-new-password"}`,
+			want: http.StatusBadRequest,
+		},
+		{
+			name: "email without current_password",
+			body: `{"email": "new@knockbox.io"}`,
+			want: http.StatusBadRequest,
+		},
+		{
+			name: "current_password only",
+			body: `{"current_password": "the-current-password"}`,
+			want: http.StatusBadRequest,
+		},
+		{
+			name: "wrong current_password",
+			body: `{"password": "a-new-password", "current_password": "not-the-password"}`,
+			want: http.StatusForbidden,
+			check: func(t *testing.T) {
+				updated, _ := store.users.GetById(int(user.Id))
+				assert.True(t, utils.ComparePasswords(updated.Password, "the-current-password"))
+			},
+		},
+		{
+			name: "email of another user",
+			body: `{"email": "taken@knockbox.io", "current_password": "the-current-password"}`,
+			want: http.StatusBadRequest,
+		},
+		{
+			name: "new email",
+			body: `{"email": "new@knockbox.io", "current_password": "the-current-password"}`,
+			want: http.StatusAccepted,
+			check: func(t *testing.T) {
+				updated, _ := store.users.GetById(int(user.Id))
+				assert.Equal(t, "user@knockbox.io", updated.Email)
+
+				if assert.Len(t, store.mail.messages, 2) {
+					assert.Equal(t, "new@knockbox.io", store.mail.messages[0].To)
+					assert.Contains(t, store.mail.messages[0].Body, u.account.VerificationURL+"?token=")
+					assert.Equal(t, "user@knockbox.io", store.mail.messages[1].To)
+				}
+
+				assert.Contains(t, store.history.actions(), enums.UserAction(enums.SendEmailChange))
+			},
+		},
+		{
+			name: "new email within the resend interval",
+			body: `{"email": "other@knockbox.io", "current_password": "the-current-password"}`,
+			want: http.StatusTooManyRequests,
+			check: func(t *testing.T) {
+				assert.Len(t, store.mail.messages, 2)
+			},
+		},
+		{
+			name: "new password",
+			body: `{"password": "a-new-password", "current_password": "the-current-password"}`,
+			want: http.StatusNoContent,
+			check: func(t *testing.T) {
+				updated, _ := store.users.GetById(int(user.Id))
+				assert.True(t, utils.ComparePasswords(updated.Password, "a-new-password"))
+
+				revoked, err := u.tokens.IsRevoked(access)
+				assert.NoError(t, err)
+				assert.True(t, revoked)
+
+				assert.Contains(t, store.history.actions(), enums.UserAction(enums.UpdatePassword))
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodPut, "/user", strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Content-Type", "application/json")
+
+			rr := httptest.NewRecorder()
+			http.HandlerFunc(u.Update).ServeHTTP(rr, withTestPrincipal(req, user))
+
+			assert.Equal(t, tt.want, rr.Code)
+			if tt.check != nil {
+				tt.check(t)
+			}
+		})
+	}
+}
+
+func TestUser_UpdateProfile(t *testing.T) {
+	u, store := newMemoryUser(t)
+	user := store.addUser(t, "the-current-password")
+
+	tests := []struct {
+		name  string
+		body  string
+		want  int
+		check func(t *testing.T, body string)
+	}{
+		{
+			name: "no changes",
+			body: `{}`,
+			want: http.StatusBadRequest,
+		},
+		{
+			name: "invalid url",
+			body: `{"github_url": "not a url"}`,
+			want: http.StatusBadRequest,
+		},
+		{
+			name: "full_name too long",
+			body: `{"full_name": "` + strings.Repeat("a", 65) + `"}`,
+			want: http.StatusBadRequest,
+		},
+		{
+			name: "full_name and website_url",
+			body: `{"full_name": "User", "website_url": "https://knockbox.io"}`,
+			want: http.StatusOK,
+			check: func(t *testing.T, body string) {
+				assert.Contains(t, body, `"full_name":"User"`)
+
+				details, _ := store.details.GetByUserId(user.Id)
+				assert.Equal(t, "User", details.FullName)
+				assert.Equal(t, "https://knockbox.io", details.WebsiteURL)
+				assert.True(t, details.Verified)
+
+				if assert.Len(t, store.history.entries, 1) {
+					assert.Equal(t, user.Id, store.history.entries[0].UserId)
+					assert.Equal(t, enums.UserAction(enums.UpdateProfile), store.history.entries[0].Action)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodPatch, "/user/profile", strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Content-Type", "application/json")
+
+			rr := httptest.NewRecorder()
+			http.HandlerFunc(u.UpdateProfile).ServeHTTP(rr, withTestPrincipal(req, user))
+
+			assert.Equal(t, tt.want, rr.Code)
+			if tt.check != nil {
+				tt.check(t, rr.Body.String())
+			}
+		})
+	}
+}
+
+func TestUser_UpdateRole(t *testing.T) {
+	u, store := newMemoryUser(t)
+	user := store.addUser(t, "the-current-password")
+	admin := &models.User{AccountId: uuid.New(), Role: enums.Admin}
+
+	tests := []struct {
+		name  string
+		body  string
+		want  int
+		check func(t *testing.T)
+	}{
+		{
+			name: "to a role at your own",
+			body: `{"role": "admin"}`,
+			want: http.StatusForbidden,
+			check: func(t *testing.T) {
+				assert.Empty(t, store.history.entries)
+			},
+		},
+		{
+			name: "to a role below your own",
+			body: `{"role": "moderator"}`,
+			want: http.StatusNoContent,
+			check: func(t *testing.T) {
+				assert.Equal(t, enums.UserRole(enums.Moderator), store.users[user.Id].Role)
+
+				if assert.Len(t, store.history.entries, 1) {
+					entry := store.history.entries[0]
+					assert.Equal(t, enums.UserAction(enums.UpdateRole), entry.Action)
+					assert.Equal(t, enums.UserRole(enums.User), *entry.PreviousRole)
+					assert.Equal(t, enums.UserRole(enums.Moderator), *entry.NewRole)
+					assert.Equal(t, admin.AccountId.String(), *entry.ChangedBy)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodPut, "/moderation/user/"+user.AccountId.String()+"/role", strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Content-Type", "application/json")
+			req = mux.SetURLVars(req, map[string]string{"account_id": user.AccountId.String()})
+
+			rr := httptest.NewRecorder()
+			http.HandlerFunc(u.UpdateRole).ServeHTTP(rr, withTestPrincipal(req, admin))
+
+			assert.Equal(t, tt.want, rr.Code)
+			if tt.check != nil {
+				tt.check(t)
+			}
+		})
+	}
+}
+
+// withTestPrincipal puts a principal for the user on the request, as the bearer token middleware would.
+func withTestPrincipal(req *http.Request, user *models.User) *http.Request {
+	p := &models.Principal{Subject: user.AccountId.String(), Role: user.Role}
+	return req.WithContext(context.WithValue(req.Context(), middleware.PrincipalContextKey, p))
+}
@@ -0,0 +1,52 @@
+package platform
+
+import (
+	"database/sql"
+	"github.com/hashicorp/go-hclog"
+	"github.com/jmoiron/sqlx"
+	"github.com/knockbox/authentication/internal/queries"
+	"github.com/knockbox/authentication/pkg/models"
+	"github.com/knockbox/authentication/pkg/utils"
+)
+
+type AuthorizationCodeSQLImpl struct {
+	*sqlx.DB
+	hclog.Logger
+}
+
+// Create inserts the code and purges expired codes in the same transaction.
+func (a AuthorizationCodeSQLImpl) Create(code models.AuthorizationCode) (sql.Result, error) {
+	return utils.Transact(a.DB, func(tx *sql.Tx) (sql.Result, error) {
+		if _, err := tx.Exec(queries.DeleteExpiredAuthorizationCodes); err != nil {
+			return nil, err
+		}
+
+		return tx.Exec(
+			queries.InsertAuthorizationCode,
+			code.CodeHash,
+			code.ClientId,
+			code.UserId,
+			code.FamilyId,
+			code.RedirectURI,
+			code.Scope,
+			code.CodeChallenge,
+			code.Nonce,
+			code.AuthTime,
+			code.Amr,
+			code.IssuedAt,
+			code.ExpiresAt,
+		)
+	})
+}
+
+func (a AuthorizationCodeSQLImpl) GetByHash(hash string) (*models.AuthorizationCode, error) {
+	code := &models.AuthorizationCode{}
+	err := a.Get(code, queries.GetAuthorizationCodeByHash, hash)
+	return code, err
+}
+
+func (a AuthorizationCodeSQLImpl) MarkUsed(id uint) (sql.Result, error) {
+	return utils.Transact(a.DB, func(tx *sql.Tx) (sql.Result, error) {
+		return tx.Exec(queries.MarkAuthorizationCodeUsed, id)
+	})
+}
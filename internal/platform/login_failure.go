@@ -0,0 +1,93 @@
+package platform
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/hashicorp/go-hclog"
+	"github.com/jmoiron/sqlx"
+	"github.com/knockbox/authentication/internal/queries"
+	"github.com/knockbox/authentication/pkg/models"
+	"github.com/knockbox/authentication/pkg/utils"
+	"time"
+)
+
+type LoginFailureSQLImpl struct {
+	*sqlx.DB
+	hclog.Logger
+}
+
+// Apply passes the failures of the subject to apply while holding a lock on their row, so concurrent logins see
+// each other's changes. Counts restart if the last failure was before since. The changes apply makes are saved if
+// it returns true. A subject without a row has not failed, apply is not called and nil is returned.
+func (l LoginFailureSQLImpl) Apply(kind, subject string, since time.Time, apply func(failure *models.LoginFailure) bool) (*models.LoginFailure, error) {
+	return l.apply(kind, subject, nil, since, apply)
+}
+
+// ApplyOrCreate is Apply, creating the row of the subject first if it has none.
+func (l LoginFailureSQLImpl) ApplyOrCreate(kind, subject string, now, since time.Time, apply func(failure *models.LoginFailure) bool) (*models.LoginFailure, error) {
+	return l.apply(kind, subject, &now, since, apply)
+}
+
+// apply is Apply, first creating the row failed at created if it is not nil.
+func (l LoginFailureSQLImpl) apply(kind, subject string, created *time.Time, since time.Time, apply func(failure *models.LoginFailure) bool) (*models.LoginFailure, error) {
+	var failure *models.LoginFailure
+	_, err := utils.Transact(l.DB, func(tx *sql.Tx) (sql.Result, error) {
+		if created != nil {
+			if _, err := tx.Exec(queries.InsertLoginFailureIfMissing, kind, subject, *created); err != nil {
+				return nil, err
+			}
+		}
+
+		found := &models.LoginFailure{}
+		err := tx.QueryRow(queries.GetLoginFailureBySubjectForUpdate, kind, subject).Scan(
+			&found.Id,
+			&found.Kind,
+			&found.Subject,
+			&found.Failures,
+			&found.Lockouts,
+			&found.RetryAt,
+			&found.LastFailedAt,
+		)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		failure = found
+		if failure.LastFailedAt.Before(since) {
+			failure.Failures = 0
+			failure.Lockouts = 0
+		}
+
+		if !apply(failure) {
+			return nil, nil
+		}
+
+		return tx.Exec(
+			queries.UpdateLoginFailure,
+			failure.Failures,
+			failure.Lockouts,
+			failure.RetryAt,
+			failure.LastFailedAt,
+			failure.Id,
+		)
+	})
+
+	return failure, err
+}
+
+func (l LoginFailureSQLImpl) DeleteBySubject(kind, subject string) (sql.Result, error) {
+	return utils.Transact(l.DB, func(tx *sql.Tx) (sql.Result, error) {
+		return tx.Exec(queries.DeleteLoginFailureBySubject, kind, subject)
+	})
+}
+
+// DeleteStale purges subjects with no failures since since and no lock left at now.
+func (l LoginFailureSQLImpl) DeleteStale(now, since time.Time) (sql.Result, error) {
+	return utils.Transact(l.DB, func(tx *sql.Tx) (sql.Result, error) {
+		return tx.Exec(queries.DeleteStaleLoginFailures, since, now)
+	})
+}
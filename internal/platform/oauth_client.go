@@ -0,0 +1,42 @@
+package platform
+
+import (
+	"database/sql"
+	"github.com/hashicorp/go-hclog"
+	"github.com/jmoiron/sqlx"
+	"github.com/knockbox/authentication/internal/queries"
+	"github.com/knockbox/authentication/pkg/models"
+	"github.com/knockbox/authentication/pkg/utils"
+)
+
+type OAuthClientSQLImpl struct {
+	*sqlx.DB
+	hclog.Logger
+}
+
+func (o OAuthClientSQLImpl) Create(client models.OAuthClient) (sql.Result, error) {
+	return utils.Transact(o.DB, func(tx *sql.Tx) (sql.Result, error) {
+		return tx.Exec(
+			queries.InsertOAuthClient,
+			client.ClientId,
+			client.ClientSecretHash,
+			client.Name,
+			client.RedirectURIs,
+			client.AllowedScopes,
+			client.FirstParty,
+			client.CreatedAt,
+		)
+	})
+}
+
+func (o OAuthClientSQLImpl) GetByClientId(clientId string) (*models.OAuthClient, error) {
+	client := &models.OAuthClient{}
+	err := o.Get(client, queries.GetOAuthClientByClientId, clientId)
+	return client, err
+}
+
+func (o OAuthClientSQLImpl) DeleteByClientId(clientId string) (sql.Result, error) {
+	return utils.Transact(o.DB, func(tx *sql.Tx) (sql.Result, error) {
+		return tx.Exec(queries.DeleteOAuthClientByClientId, clientId)
+	})
+}
@@ -0,0 +1,56 @@
+package platform
+
+import (
+	"database/sql"
+	"github.com/hashicorp/go-hclog"
+	"github.com/jmoiron/sqlx"
+	"github.com/knockbox/authentication/internal/queries"
+	"github.com/knockbox/authentication/pkg/models"
+	"github.com/knockbox/authentication/pkg/utils"
+)
+
+type PasskeySQLImpl struct {
+	*sqlx.DB
+	hclog.Logger
+}
+
+func (p PasskeySQLImpl) Create(passkey models.Passkey) (sql.Result, error) {
+	return utils.Transact(p.DB, func(tx *sql.Tx) (sql.Result, error) {
+		return tx.Exec(
+			queries.InsertPasskey,
+			passkey.UserId,
+			passkey.CredentialId,
+			passkey.Name,
+			passkey.PublicKey,
+			passkey.AttestationType,
+			passkey.Transports,
+			passkey.AAGUID,
+			passkey.SignCount,
+			passkey.Flags,
+		)
+	})
+}
+
+func (p PasskeySQLImpl) GetByUserId(userId uint) ([]models.Passkey, error) {
+	var passkeys []models.Passkey
+	err := p.Select(&passkeys, queries.GetPasskeysByUserId, userId)
+	return passkeys, err
+}
+
+func (p PasskeySQLImpl) UpdateUsage(id uint, signCount uint32, flags uint8) (sql.Result, error) {
+	return utils.Transact(p.DB, func(tx *sql.Tx) (sql.Result, error) {
+		return tx.Exec(queries.UpdatePasskeyUsage, signCount, flags, id)
+	})
+}
+
+func (p PasskeySQLImpl) DeleteByCredentialId(userId uint, credentialId []byte) (sql.Result, error) {
+	return utils.Transact(p.DB, func(tx *sql.Tx) (sql.Result, error) {
+		return tx.Exec(queries.DeletePasskeyByCredentialId, userId, credentialId)
+	})
+}
+
+func (p PasskeySQLImpl) DeleteByUserId(userId uint) (sql.Result, error) {
+	return utils.Transact(p.DB, func(tx *sql.Tx) (sql.Result, error) {
+		return tx.Exec(queries.DeletePasskeysByUserId, userId)
+	})
+}
@@ -0,0 +1,44 @@
+package platform
+
+import (
+	"database/sql"
+	"github.com/hashicorp/go-hclog"
+	"github.com/jmoiron/sqlx"
+	"github.com/knockbox/authentication/internal/queries"
+	"github.com/knockbox/authentication/pkg/models"
+	"github.com/knockbox/authentication/pkg/utils"
+)
+
+type PasswordResetSQLImpl struct {
+	*sqlx.DB
+	hclog.Logger
+}
+
+// Create inserts the reset and purges expired resets in the same transaction.
+func (p PasswordResetSQLImpl) Create(reset models.PasswordReset) (sql.Result, error) {
+	return utils.Transact(p.DB, func(tx *sql.Tx) (sql.Result, error) {
+		if _, err := tx.Exec(queries.DeleteExpiredPasswordResets); err != nil {
+			return nil, err
+		}
+
+		return tx.Exec(queries.InsertPasswordReset, reset.UserId, reset.TokenHash, reset.IssuedAt, reset.ExpiresAt)
+	})
+}
+
+func (p PasswordResetSQLImpl) GetByHash(hash string) (*models.PasswordReset, error) {
+	reset := &models.PasswordReset{}
+	err := p.Get(reset, queries.GetPasswordResetByHash, hash)
+	return reset, err
+}
+
+func (p PasswordResetSQLImpl) MarkUsed(id uint) (sql.Result, error) {
+	return utils.Transact(p.DB, func(tx *sql.Tx) (sql.Result, error) {
+		return tx.Exec(queries.MarkPasswordResetUsed, id)
+	})
+}
+
+func (p PasswordResetSQLImpl) MarkUsedByUserId(userId uint) (sql.Result, error) {
+	return utils.Transact(p.DB, func(tx *sql.Tx) (sql.Result, error) {
+		return tx.Exec(queries.MarkPasswordResetsUsedByUserId, userId)
+	})
+}
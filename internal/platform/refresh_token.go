@@ -0,0 +1,45 @@
+package platform
+
+import (
+	"database/sql"
+	"github.com/hashicorp/go-hclog"
+	"github.com/jmoiron/sqlx"
+	"github.com/knockbox/authentication/internal/queries"
+	"github.com/knockbox/authentication/pkg/models"
+	"github.com/knockbox/authentication/pkg/utils"
+)
+
+type RefreshTokenSQLImpl struct {
+	*sqlx.DB
+	hclog.Logger
+}
+
+func (r RefreshTokenSQLImpl) Create(token models.RefreshToken) (sql.Result, error) {
+	return utils.Transact(r.DB, func(tx *sql.Tx) (sql.Result, error) {
+		return tx.Exec(queries.InsertRefreshToken, token.UserId, token.FamilyId, token.ClientId, token.TokenHash, token.Scope, token.Amr, token.IssuedAt, token.ExpiresAt)
+	})
+}
+
+func (r RefreshTokenSQLImpl) GetByHash(hash string) (*models.RefreshToken, error) {
+	token := &models.RefreshToken{}
+	err := r.Get(token, queries.GetRefreshTokenByHash, hash)
+	return token, err
+}
+
+func (r RefreshTokenSQLImpl) MarkUsed(id uint) (sql.Result, error) {
+	return utils.Transact(r.DB, func(tx *sql.Tx) (sql.Result, error) {
+		return tx.Exec(queries.MarkRefreshTokenUsed, id)
+	})
+}
+
+func (r RefreshTokenSQLImpl) RevokeByFamilyId(familyId string) (sql.Result, error) {
+	return utils.Transact(r.DB, func(tx *sql.Tx) (sql.Result, error) {
+		return tx.Exec(queries.RevokeRefreshTokensByFamilyId, familyId)
+	})
+}
+
+func (r RefreshTokenSQLImpl) RevokeByUserId(userId uint) (sql.Result, error) {
+	return utils.Transact(r.DB, func(tx *sql.Tx) (sql.Result, error) {
+		return tx.Exec(queries.RevokeRefreshTokensByUserId, userId)
+	})
+}
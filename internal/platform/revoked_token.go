@@ -0,0 +1,33 @@
+package platform
+
+import (
+	"database/sql"
+	"github.com/hashicorp/go-hclog"
+	"github.com/jmoiron/sqlx"
+	"github.com/knockbox/authentication/internal/queries"
+	"github.com/knockbox/authentication/pkg/models"
+	"github.com/knockbox/authentication/pkg/utils"
+	"time"
+)
+
+type RevokedTokenSQLImpl struct {
+	*sqlx.DB
+	hclog.Logger
+}
+
+// Create inserts the entry and purges expired entries in the same transaction.
+func (r RevokedTokenSQLImpl) Create(token models.RevokedToken) (sql.Result, error) {
+	return utils.Transact(r.DB, func(tx *sql.Tx) (sql.Result, error) {
+		if _, err := tx.Exec(queries.DeleteExpiredRevokedTokens); err != nil {
+			return nil, err
+		}
+
+		return tx.Exec(queries.InsertRevokedToken, token.Jti, token.Subject, token.RevokedAt, token.ExpiresAt)
+	})
+}
+
+func (r RevokedTokenSQLImpl) CountActive(jti, subject string, issuedAt time.Time) (int, error) {
+	var count int
+	err := r.Get(&count, queries.CountActiveRevokedTokens, jti, subject, issuedAt)
+	return count, err
+}
@@ -0,0 +1,66 @@
+package platform
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/hashicorp/go-hclog"
+	"github.com/jmoiron/sqlx"
+	"github.com/knockbox/authentication/internal/queries"
+	"github.com/knockbox/authentication/pkg/models"
+	"github.com/knockbox/authentication/pkg/utils"
+)
+
+// lockTimeoutSeconds is how long WithLock waits for another instance to release the lock.
+const lockTimeoutSeconds = 10
+
+type SigningKeySQLImpl struct {
+	*sqlx.DB
+	hclog.Logger
+}
+
+func (s SigningKeySQLImpl) Upsert(key models.SigningKey) (sql.Result, error) {
+	return utils.Transact(s.DB, func(tx *sql.Tx) (sql.Result, error) {
+		return tx.Exec(queries.UpsertSigningKey, key.Kid, key.KeyData, key.ActiveUntil, key.ExpiresAt)
+	})
+}
+
+func (s SigningKeySQLImpl) GetAll() ([]models.SigningKey, error) {
+	var keys []models.SigningKey
+	err := s.Select(&keys, queries.GetAllSigningKeys)
+	return keys, err
+}
+
+func (s SigningKeySQLImpl) DeleteByKid(kid string) (sql.Result, error) {
+	return utils.Transact(s.DB, func(tx *sql.Tx) (sql.Result, error) {
+		return tx.Exec(queries.DeleteSigningKeyByKid, kid)
+	})
+}
+
+// WithLock runs fn while holding the named lock of the signing keys, waiting up to lockTimeoutSeconds for it. The
+// lock belongs to a connection, so it is taken and released on the same one.
+func (s SigningKeySQLImpl) WithLock(fn func() error) error {
+	ctx := context.Background()
+	conn, err := s.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	var acquired sql.NullInt64
+	if err := conn.QueryRowContext(ctx, queries.LockSigningKeys, lockTimeoutSeconds).Scan(&acquired); err != nil {
+		return err
+	}
+
+	if acquired.Int64 != 1 {
+		return errors.New("timed out waiting for the signing key lock")
+	}
+
+	defer func() {
+		if _, err := conn.ExecContext(ctx, queries.UnlockSigningKeys); err != nil {
+			s.Error("failed to release the signing key lock", "err", err)
+		}
+	}()
+
+	return fn()
+}
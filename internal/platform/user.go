@@ -45,6 +45,12 @@ func (u UserSQLImpl) GetByUsername(username string) (*models.User, error) {
 	return user, err
 }
 
+func (u UserSQLImpl) GetByEmail(email string) (*models.User, error) {
+	user := &models.User{}
+	err := u.Get(user, queries.GetUserByEmail, email)
+	return user, err
+}
+
 func (u UserSQLImpl) GetLikeUsername(username string, page models.Page) ([]models.User, error) {
 	var users []models.User
 	err := u.Select(&users, queries.GetUsersLikeUsername, fmt.Sprintf("%%%s%%", username), page.Limit, page.Offset)
@@ -25,3 +25,15 @@ func (u UserDetailsSQLImpl) Update(details models.UserDetails) (sql.Result, erro
 		return tx.Exec(queries.UpdateUserDetails, details.ProfilePicture, details.FullName, details.GithubURL, details.TwitterURL, details.WebsiteURL, details.UserId)
 	})
 }
+
+func (u UserDetailsSQLImpl) UpdateVerified(userId uint, verified bool) (sql.Result, error) {
+	return utils.Transact(u.DB, func(tx *sql.Tx) (sql.Result, error) {
+		return tx.Exec(queries.UpdateUserDetailsVerified, verified, userId)
+	})
+}
+
+func (u UserDetailsSQLImpl) GetByUserId(userId uint) (*models.UserDetails, error) {
+	details := &models.UserDetails{}
+	err := u.Get(details, queries.GetUserDetailsByUserId, userId)
+	return details, err
+}
@@ -5,8 +5,10 @@ import (
 	"github.com/hashicorp/go-hclog"
 	"github.com/jmoiron/sqlx"
 	"github.com/knockbox/authentication/internal/queries"
+	"github.com/knockbox/authentication/pkg/enums"
 	"github.com/knockbox/authentication/pkg/models"
 	"github.com/knockbox/authentication/pkg/utils"
+	"time"
 )
 
 type UserHistorySQLImpl struct {
@@ -16,12 +18,29 @@ type UserHistorySQLImpl struct {
 
 func (u UserHistorySQLImpl) Create(history models.UserHistory) (sql.Result, error) {
 	return utils.Transact(u.DB, func(tx *sql.Tx) (sql.Result, error) {
-		return tx.Exec(queries.InsertUserHistory, history.UserId, history.IpAddress, history.Action)
+		return tx.Exec(
+			queries.InsertUserHistory,
+			history.UserId,
+			history.IpAddress,
+			history.UserAgent,
+			history.Action,
+			history.PreviousRole,
+			history.NewRole,
+			history.ChangedBy,
+		)
 	})
 }
 
 func (u UserHistorySQLImpl) GetByUserId(id int, page models.Page) ([]models.UserHistory, error) {
 	var history []models.UserHistory
-	err := u.Select(history, queries.GetUserHistoryByUserId, id, page.Limit, page.Offset)
+	err := u.Select(&history, queries.GetUserHistoryByUserId, id, page.Limit, page.Offset)
 	return history, err
 }
+
+// CountRecent counts the actions of the user within the window, measured against the database clock that
+// timestamps them.
+func (u UserHistorySQLImpl) CountRecent(userId uint, action enums.UserAction, window time.Duration) (int, error) {
+	var count int
+	err := u.Get(&count, queries.CountRecentUserHistory, userId, action, int(window.Seconds()))
+	return count, err
+}
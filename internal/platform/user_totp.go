@@ -0,0 +1,94 @@
+package platform
+
+import (
+	"database/sql"
+	"github.com/hashicorp/go-hclog"
+	"github.com/jmoiron/sqlx"
+	"github.com/knockbox/authentication/internal/queries"
+	"github.com/knockbox/authentication/pkg/models"
+	"github.com/knockbox/authentication/pkg/utils"
+)
+
+type UserTOTPSQLImpl struct {
+	*sqlx.DB
+	hclog.Logger
+}
+
+// Upsert stores the unconfirmed authenticator, replacing any previous one of the user.
+func (u UserTOTPSQLImpl) Upsert(totp models.UserTOTP) (sql.Result, error) {
+	return utils.Transact(u.DB, func(tx *sql.Tx) (sql.Result, error) {
+		return tx.Exec(queries.UpsertUserTOTP, totp.UserId, totp.Secret)
+	})
+}
+
+func (u UserTOTPSQLImpl) GetAll() ([]models.UserTOTP, error) {
+	var all []models.UserTOTP
+	err := u.Select(&all, queries.GetAllUserTOTP)
+	return all, err
+}
+
+func (u UserTOTPSQLImpl) UpdateSecret(id uint, secret []byte) (sql.Result, error) {
+	return utils.Transact(u.DB, func(tx *sql.Tx) (sql.Result, error) {
+		return tx.Exec(queries.UpdateUserTOTPSecret, secret, id)
+	})
+}
+
+func (u UserTOTPSQLImpl) GetByUserId(userId uint) (*models.UserTOTP, error) {
+	totp := &models.UserTOTP{}
+	err := u.Get(totp, queries.GetUserTOTPByUserId, userId)
+	return totp, err
+}
+
+func (u UserTOTPSQLImpl) Confirm(userId uint, step int64) (sql.Result, error) {
+	return utils.Transact(u.DB, func(tx *sql.Tx) (sql.Result, error) {
+		return tx.Exec(queries.ConfirmUserTOTP, step, userId)
+	})
+}
+
+// UseStep advances the last step of a confirmed authenticator, it affects no rows if the step was already used.
+func (u UserTOTPSQLImpl) UseStep(userId uint, step int64) (sql.Result, error) {
+	return utils.Transact(u.DB, func(tx *sql.Tx) (sql.Result, error) {
+		return tx.Exec(queries.UseUserTOTPStep, step, userId, step)
+	})
+}
+
+func (u UserTOTPSQLImpl) DeleteByUserId(userId uint) (sql.Result, error) {
+	return utils.Transact(u.DB, func(tx *sql.Tx) (sql.Result, error) {
+		return tx.Exec(queries.DeleteUserTOTPByUserId, userId)
+	})
+}
+
+type RecoveryCodeSQLImpl struct {
+	*sqlx.DB
+	hclog.Logger
+}
+
+// ReplaceForUser deletes the codes of the user and inserts the new ones in the same transaction.
+func (r RecoveryCodeSQLImpl) ReplaceForUser(userId uint, hashes []string) (sql.Result, error) {
+	return utils.Transact(r.DB, func(tx *sql.Tx) (sql.Result, error) {
+		result, err := tx.Exec(queries.DeleteRecoveryCodesByUserId, userId)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, hash := range hashes {
+			if result, err = tx.Exec(queries.InsertRecoveryCode, userId, hash); err != nil {
+				return nil, err
+			}
+		}
+
+		return result, nil
+	})
+}
+
+func (r RecoveryCodeSQLImpl) MarkUsed(userId uint, hash string) (sql.Result, error) {
+	return utils.Transact(r.DB, func(tx *sql.Tx) (sql.Result, error) {
+		return tx.Exec(queries.MarkRecoveryCodeUsed, userId, hash)
+	})
+}
+
+func (r RecoveryCodeSQLImpl) DeleteByUserId(userId uint) (sql.Result, error) {
+	return utils.Transact(r.DB, func(tx *sql.Tx) (sql.Result, error) {
+		return tx.Exec(queries.DeleteRecoveryCodesByUserId, userId)
+	})
+}
@@ -0,0 +1 @@
+DELETE FROM authorization_codes WHERE expires_at <= UTC_TIMESTAMP()
\ No newline at end of file
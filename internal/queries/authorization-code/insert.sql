@@ -0,0 +1,3 @@
+INSERT INTO authorization_codes (code_hash, client_id, user_id, family_id, redirect_uri, scope, code_challenge, nonce,
+                                 auth_time, amr, issued_at, expires_at)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
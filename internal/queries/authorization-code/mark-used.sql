@@ -0,0 +1 @@
+UPDATE authorization_codes SET used_at = NOW() WHERE id = ? AND used_at IS NULL
\ No newline at end of file
@@ -0,0 +1 @@
+SELECT * FROM authorization_codes WHERE code_hash = ?
\ No newline at end of file
@@ -0,0 +1,15 @@
+package queries
+
+import _ "embed"
+
+//go:embed authorization-code/insert.sql
+var InsertAuthorizationCode string
+
+//go:embed authorization-code/select-by-code_hash.sql
+var GetAuthorizationCodeByHash string
+
+//go:embed authorization-code/mark-used.sql
+var MarkAuthorizationCodeUsed string
+
+//go:embed authorization-code/delete-expired.sql
+var DeleteExpiredAuthorizationCodes string
@@ -0,0 +1 @@
+DELETE FROM login_failures WHERE kind = ? AND subject = ?
\ No newline at end of file
@@ -0,0 +1 @@
+DELETE FROM login_failures WHERE last_failed_at < ? AND (retry_at IS NULL OR retry_at < ?)
\ No newline at end of file
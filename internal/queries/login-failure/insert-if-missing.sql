@@ -0,0 +1,3 @@
+INSERT INTO login_failures (kind, subject, failures, lockouts, retry_at, last_failed_at)
+VALUES (?, ?, 0, 0, NULL, ?)
+ON DUPLICATE KEY UPDATE id = id
\ No newline at end of file
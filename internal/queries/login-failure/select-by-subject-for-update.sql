@@ -0,0 +1,4 @@
+SELECT id, kind, subject, failures, lockouts, retry_at, last_failed_at
+FROM login_failures
+WHERE kind = ?
+  AND subject = ? FOR UPDATE
\ No newline at end of file
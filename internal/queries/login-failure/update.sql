@@ -0,0 +1 @@
+UPDATE login_failures SET failures = ?, lockouts = ?, retry_at = ?, last_failed_at = ? WHERE id = ?
\ No newline at end of file
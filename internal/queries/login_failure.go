@@ -0,0 +1,18 @@
+package queries
+
+import _ "embed"
+
+//go:embed login-failure/insert-if-missing.sql
+var InsertLoginFailureIfMissing string
+
+//go:embed login-failure/select-by-subject-for-update.sql
+var GetLoginFailureBySubjectForUpdate string
+
+//go:embed login-failure/update.sql
+var UpdateLoginFailure string
+
+//go:embed login-failure/delete-by-subject.sql
+var DeleteLoginFailureBySubject string
+
+//go:embed login-failure/delete-stale.sql
+var DeleteStaleLoginFailures string
@@ -0,0 +1 @@
+DELETE FROM oauth_clients WHERE client_id = ?
\ No newline at end of file
@@ -0,0 +1,2 @@
+INSERT INTO oauth_clients (client_id, client_secret_hash, name, redirect_uris, allowed_scopes, first_party, created_at)
+VALUES (?, ?, ?, ?, ?, ?, ?)
@@ -0,0 +1 @@
+SELECT * FROM oauth_clients WHERE client_id = ?
\ No newline at end of file
@@ -0,0 +1,12 @@
+package queries
+
+import _ "embed"
+
+//go:embed oauth-client/insert.sql
+var InsertOAuthClient string
+
+//go:embed oauth-client/select-by-client_id.sql
+var GetOAuthClientByClientId string
+
+//go:embed oauth-client/delete-by-client_id.sql
+var DeleteOAuthClientByClientId string
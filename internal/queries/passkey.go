@@ -0,0 +1,18 @@
+package queries
+
+import _ "embed"
+
+//go:embed passkey/insert.sql
+var InsertPasskey string
+
+//go:embed passkey/select-by-user_id.sql
+var GetPasskeysByUserId string
+
+//go:embed passkey/update-usage.sql
+var UpdatePasskeyUsage string
+
+//go:embed passkey/delete-by-credential_id.sql
+var DeletePasskeyByCredentialId string
+
+//go:embed passkey/delete-by-user_id.sql
+var DeletePasskeysByUserId string
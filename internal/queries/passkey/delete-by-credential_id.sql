@@ -0,0 +1 @@
+DELETE FROM passkeys WHERE user_id = ? AND credential_id = ?
\ No newline at end of file
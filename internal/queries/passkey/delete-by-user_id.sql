@@ -0,0 +1 @@
+DELETE FROM passkeys WHERE user_id = ?
\ No newline at end of file
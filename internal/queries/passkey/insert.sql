@@ -0,0 +1,2 @@
+INSERT INTO passkeys (user_id, credential_id, name, public_key, attestation_type, transports, aaguid, sign_count, flags, created_at)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, NOW())
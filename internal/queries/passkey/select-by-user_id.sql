@@ -0,0 +1 @@
+SELECT * FROM passkeys WHERE user_id = ? ORDER BY created_at, id
\ No newline at end of file
@@ -0,0 +1 @@
+UPDATE passkeys SET sign_count = ?, flags = ?, last_used_at = NOW() WHERE id = ?
\ No newline at end of file
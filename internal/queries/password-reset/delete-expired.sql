@@ -0,0 +1 @@
+DELETE FROM password_resets WHERE expires_at <= UTC_TIMESTAMP()
\ No newline at end of file
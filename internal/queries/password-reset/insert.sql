@@ -0,0 +1,2 @@
+INSERT INTO password_resets (user_id, token_hash, issued_at, expires_at)
+VALUES (?, ?, ?, ?)
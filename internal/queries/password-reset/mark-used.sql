@@ -0,0 +1 @@
+UPDATE password_resets SET used_at = NOW() WHERE id = ? AND used_at IS NULL
\ No newline at end of file
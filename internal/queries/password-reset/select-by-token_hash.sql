@@ -0,0 +1 @@
+SELECT * FROM password_resets WHERE token_hash = ?
\ No newline at end of file
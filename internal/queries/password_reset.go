@@ -0,0 +1,18 @@
+package queries
+
+import _ "embed"
+
+//go:embed password-reset/insert.sql
+var InsertPasswordReset string
+
+//go:embed password-reset/select-by-token_hash.sql
+var GetPasswordResetByHash string
+
+//go:embed password-reset/mark-used.sql
+var MarkPasswordResetUsed string
+
+//go:embed password-reset/mark-used-by-user_id.sql
+var MarkPasswordResetsUsedByUserId string
+
+//go:embed password-reset/delete-expired.sql
+var DeleteExpiredPasswordResets string
@@ -0,0 +1 @@
+DELETE FROM recovery_codes WHERE user_id = ?
\ No newline at end of file
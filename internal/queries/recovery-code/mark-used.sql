@@ -0,0 +1 @@
+UPDATE recovery_codes SET used_at = NOW() WHERE user_id = ? AND code_hash = ? AND used_at IS NULL
\ No newline at end of file
@@ -0,0 +1,12 @@
+package queries
+
+import _ "embed"
+
+//go:embed recovery-code/insert.sql
+var InsertRecoveryCode string
+
+//go:embed recovery-code/mark-used.sql
+var MarkRecoveryCodeUsed string
+
+//go:embed recovery-code/delete-by-user_id.sql
+var DeleteRecoveryCodesByUserId string
@@ -0,0 +1,2 @@
+INSERT INTO refresh_tokens (user_id, family_id, client_id, token_hash, scope, amr, issued_at, expires_at)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?)
\ No newline at end of file
@@ -0,0 +1 @@
+UPDATE refresh_tokens SET used_at = NOW() WHERE id = ? AND used_at IS NULL
\ No newline at end of file
@@ -0,0 +1 @@
+SELECT * FROM refresh_tokens WHERE token_hash = ?
\ No newline at end of file
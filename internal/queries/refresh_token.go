@@ -0,0 +1,18 @@
+package queries
+
+import _ "embed"
+
+//go:embed refresh-token/insert.sql
+var InsertRefreshToken string
+
+//go:embed refresh-token/select-by-token_hash.sql
+var GetRefreshTokenByHash string
+
+//go:embed refresh-token/mark-used.sql
+var MarkRefreshTokenUsed string
+
+//go:embed refresh-token/revoke-by-family_id.sql
+var RevokeRefreshTokensByFamilyId string
+
+//go:embed refresh-token/revoke-by-user_id.sql
+var RevokeRefreshTokensByUserId string
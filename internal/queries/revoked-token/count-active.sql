@@ -0,0 +1,2 @@
+SELECT COUNT(*) FROM revoked_tokens
+WHERE expires_at > UTC_TIMESTAMP() AND (jti = ? OR (subject = ? AND revoked_at >= ?))
\ No newline at end of file
@@ -0,0 +1 @@
+DELETE FROM revoked_tokens WHERE expires_at <= UTC_TIMESTAMP()
\ No newline at end of file
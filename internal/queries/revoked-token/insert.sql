@@ -0,0 +1,2 @@
+INSERT INTO revoked_tokens (jti, subject, revoked_at, expires_at)
+VALUES (?, ?, ?, ?)
\ No newline at end of file
@@ -0,0 +1,12 @@
+package queries
+
+import _ "embed"
+
+//go:embed revoked-token/insert.sql
+var InsertRevokedToken string
+
+//go:embed revoked-token/count-active.sql
+var CountActiveRevokedTokens string
+
+//go:embed revoked-token/delete-expired.sql
+var DeleteExpiredRevokedTokens string
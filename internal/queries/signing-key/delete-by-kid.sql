@@ -0,0 +1 @@
+DELETE FROM signing_keys WHERE kid = ?
\ No newline at end of file
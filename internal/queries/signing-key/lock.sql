@@ -0,0 +1 @@
+SELECT GET_LOCK('signing_keys', ?)
\ No newline at end of file
@@ -0,0 +1 @@
+SELECT * FROM signing_keys
\ No newline at end of file
@@ -0,0 +1 @@
+SELECT RELEASE_LOCK('signing_keys')
\ No newline at end of file
@@ -0,0 +1,3 @@
+INSERT INTO signing_keys (kid, key_data, active_until, expires_at)
+VALUES (?, ?, ?, ?)
+ON DUPLICATE KEY UPDATE key_data = VALUES(key_data), active_until = VALUES(active_until), expires_at = VALUES(expires_at)
\ No newline at end of file
@@ -0,0 +1,18 @@
+package queries
+
+import _ "embed"
+
+//go:embed signing-key/upsert.sql
+var UpsertSigningKey string
+
+//go:embed signing-key/select-all.sql
+var GetAllSigningKeys string
+
+//go:embed signing-key/delete-by-kid.sql
+var DeleteSigningKeyByKid string
+
+//go:embed signing-key/lock.sql
+var LockSigningKeys string
+
+//go:embed signing-key/unlock.sql
+var UnlockSigningKeys string
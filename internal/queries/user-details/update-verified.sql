@@ -0,0 +1 @@
+UPDATE user_details SET verified = ? WHERE user_id = ?
\ No newline at end of file
@@ -0,0 +1,2 @@
+SELECT COUNT(*) FROM user_history
+WHERE user_id = ? AND action = ? AND timestamp > NOW() - INTERVAL ? SECOND
\ No newline at end of file
@@ -1 +1,2 @@
-INSERT INTO user_history (user_id, ip_address, timestamp, action) VALUES (?, ?, NOW(), ?)
\ No newline at end of file
+INSERT INTO user_history (user_id, ip_address, user_agent, timestamp, action, previous_role, new_role, changed_by)
+VALUES (?, ?, ?, NOW(), ?, ?, ?, ?)
\ No newline at end of file
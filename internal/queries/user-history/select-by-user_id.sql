@@ -1 +1 @@
-SELECT * FROM user_history WHERE user_id = ? LIMIT ? OFFSET ?
\ No newline at end of file
+SELECT * FROM user_history WHERE user_id = ? ORDER BY timestamp DESC, id DESC LIMIT ? OFFSET ?
\ No newline at end of file
@@ -0,0 +1 @@
+UPDATE user_totp SET confirmed_at = NOW(), last_step = ? WHERE user_id = ? AND confirmed_at IS NULL
\ No newline at end of file
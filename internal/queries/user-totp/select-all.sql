@@ -0,0 +1 @@
+SELECT * FROM user_totp
\ No newline at end of file
@@ -0,0 +1 @@
+UPDATE user_totp SET secret = ? WHERE id = ?
\ No newline at end of file
@@ -0,0 +1,3 @@
+INSERT INTO user_totp (user_id, secret, last_step, created_at, confirmed_at)
+VALUES (?, ?, 0, NOW(), NULL)
+ON DUPLICATE KEY UPDATE secret = VALUES(secret), last_step = 0, created_at = NOW(), confirmed_at = NULL
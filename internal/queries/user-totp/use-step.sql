@@ -0,0 +1 @@
+UPDATE user_totp SET last_step = ? WHERE user_id = ? AND last_step < ? AND confirmed_at IS NOT NULL
\ No newline at end of file
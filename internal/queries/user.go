@@ -11,6 +11,9 @@ var UpdateUser string
 //go:embed user/select-by-id.sql
 var GetUserById string
 
+//go:embed user/select-by-email.sql
+var GetUserByEmail string
+
 //go:embed user/select-by-account_id.sql
 var GetUserByAccountId string
 
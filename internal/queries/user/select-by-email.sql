@@ -0,0 +1 @@
+SELECT * FROM users WHERE email = ?
\ No newline at end of file
@@ -7,3 +7,9 @@ var InsertUserDetails string
 
 //go:embed user-details/update.sql
 var UpdateUserDetails string
+
+//go:embed user-details/select-by-user_id.sql
+var GetUserDetailsByUserId string
+
+//go:embed user-details/update-verified.sql
+var UpdateUserDetailsVerified string
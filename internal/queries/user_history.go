@@ -7,3 +7,6 @@ var InsertUserHistory string
 
 //go:embed user-history/select-by-user_id.sql
 var GetUserHistoryByUserId string
+
+//go:embed user-history/count-recent.sql
+var CountRecentUserHistory string
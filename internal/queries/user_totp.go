@@ -0,0 +1,24 @@
+package queries
+
+import _ "embed"
+
+//go:embed user-totp/upsert.sql
+var UpsertUserTOTP string
+
+//go:embed user-totp/select-all.sql
+var GetAllUserTOTP string
+
+//go:embed user-totp/select-by-user_id.sql
+var GetUserTOTPByUserId string
+
+//go:embed user-totp/confirm.sql
+var ConfirmUserTOTP string
+
+//go:embed user-totp/use-step.sql
+var UseUserTOTPStep string
+
+//go:embed user-totp/update-secret.sql
+var UpdateUserTOTPSecret string
+
+//go:embed user-totp/delete-by-user_id.sql
+var DeleteUserTOTPByUserId string
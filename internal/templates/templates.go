@@ -0,0 +1,12 @@
+package templates
+
+import (
+	_ "embed"
+	"html/template"
+)
+
+//go:embed authorize.html
+var authorize string
+
+// Authorize is the login and consent form shown by the authorization endpoint.
+var Authorize = template.Must(template.New("authorize").Parse(authorize))
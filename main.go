@@ -1,24 +1,30 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"github.com/gorilla/mux"
 	"github.com/hashicorp/go-hclog"
 	"github.com/joho/godotenv"
+	"github.com/knockbox/authentication/internal/client"
 	"github.com/knockbox/authentication/internal/handlers"
 	"github.com/knockbox/authentication/pkg/keyring"
 	"github.com/knockbox/authentication/pkg/middleware"
 	"github.com/knockbox/authentication/pkg/utils"
 	"os"
+	"strings"
+	"time"
 )
 
 var bindAddress string
 var useDotEnv bool
+var rewrapKeys bool
 
 func init() {
 	const (
 		usageBindAddress = "the address to bind to, e.g. :9090"
 		usageUseDotEnv   = "read variables from a .env file in running directory"
+		usageRewrapKeys  = "re-wrap stored signing keys and TOTP secrets with the current key-encryption key and exit"
 	)
 
 	flag.StringVar(&bindAddress, "bindAddress", ":9090", usageBindAddress)
@@ -26,6 +32,8 @@ func init() {
 
 	flag.BoolVar(&useDotEnv, "dotenv", false, usageUseDotEnv)
 	flag.BoolVar(&useDotEnv, "denv", false, usageUseDotEnv)
+
+	flag.BoolVar(&rewrapKeys, "rewrap-keys", false, usageRewrapKeys)
 }
 
 func main() {
@@ -34,26 +42,80 @@ func main() {
 	l := hclog.Default()
 	l.SetLevel(hclog.Trace)
 
-	keyset, err := keyring.NewSet(129600, 86400, l)
-	if err != nil {
-		panic(err)
+	if useDotEnv {
+		l.Info("using .env")
+
+		if err := godotenv.Load(); err != nil {
+			l.Error(".env", "error", err)
+			os.Exit(1)
+		}
+	}
+
+	// Keys sign for 12 hours and then remain long enough to verify the last access token, verification link, MFA
+	// token or passkey ceremony they signed.
+	account := utils.AccountConfigFromEnv()
+	lifespan := utils.TokenConfigFromEnv().AccessLifespan
+	ceremony := utils.WebAuthnConfigFromEnv().Timeouts.Login.Timeout
+	for _, d := range []time.Duration{account.VerificationLifespan, account.MFATokenLifespan, ceremony} {
+		if d > lifespan {
+			lifespan = d
+		}
 	}
 
-	keyset.SetCurveTypes(keyring.P521)
-	if err := keyset.Generate(3); err != nil {
+	retention := int(lifespan.Seconds())
+	keyset, err := keyring.NewSet(retention+43200, retention, l)
+	if err != nil {
 		panic(err)
 	}
 
-	if useDotEnv {
-		l.Info("using .env")
+	store := newKeyStore(l)
+	if rewrapKeys {
+		n, err := keyring.Rewrap(store)
+		if err != nil {
+			l.Error("failed to re-wrap keys", "rewrapped", n, "err", err)
+			os.Exit(1)
+		}
 
-		if err := godotenv.Load(); err != nil {
-			l.Error(".env", "error", err)
+		l.Info("re-wrapped keys", "rewrapped", n)
+
+		if n, err = rewrapTOTPSecrets(l); err != nil {
+			l.Error("failed to re-wrap totp secrets", "rewrapped", n, "err", err)
 			os.Exit(1)
 		}
+
+		l.Info("re-wrapped totp secrets", "rewrapped", n)
+		return
+	}
+
+	// The default issuer and audience only suit local development. A persistent key store means a deployment,
+	// where tokens naming localhost would be accepted by any other misconfigured instance.
+	if missing := utils.MissingTokenEnv(); len(missing) > 0 {
+		if os.Getenv("KEYRING_STORE") != "" {
+			l.Error("the token issuer and audience must be set outside local development", "missing", missing)
+			os.Exit(1)
+		}
+
+		l.Warn("using the local development token issuer and audience", "missing", missing)
+	}
+
+	keyset.SetKeyTypes(keyTypesFromEnv()...)
+	keyset.SetStore(store)
+	if err := keyset.Load(3); err != nil {
+		panic(err)
+	}
+	go keyset.Sync(context.Background(), time.Minute)
+
+	proxies, err := utils.ProxyConfigFromEnv()
+	if err != nil {
+		panic(err)
+	}
+
+	if len(proxies.Trusted) == 0 {
+		l.Warn("no trusted proxies, behind a load balancer every client shares its address")
 	}
 
 	sm := mux.NewRouter()
+	sm.Use(middleware.UseProxy(proxies).Middleware)
 	sm.Use(middleware.UseLogging(l).Middleware)
 
 	// /api grouping
@@ -64,6 +126,74 @@ func main() {
 	handlers.NewHealthcheck().Route(apiRouter)
 	handlers.NewUser(l, keyset).Route(apiRouter)
 	handlers.NewToken(l, keyset).Route(apiRouter)
+	handlers.NewKeys(l, keyset).Route(apiRouter)
+	handlers.NewClients(l, keyset).Route(apiRouter)
+	handlers.NewOpenID(l, keyset).Route(sm)
+	handlers.NewOAuth(l, keyset).Route(sm)
 
 	utils.StartServerWithGracefulShutdown(middleware.CORSMiddleware(sm), bindAddress, l)
 }
+
+// keyTypesFromEnv parses the comma separated KEYRING_KEY_TYPES, defaulting to P-521.
+func keyTypesFromEnv() []keyring.KeyType {
+	raw := os.Getenv("KEYRING_KEY_TYPES")
+	if raw == "" {
+		return []keyring.KeyType{keyring.CurveType(keyring.P521)}
+	}
+
+	var keyTypes []keyring.KeyType
+	for _, name := range strings.Split(raw, ",") {
+		keyType, err := keyring.ParseKeyType(name)
+		if err != nil {
+			panic(err)
+		}
+
+		keyTypes = append(keyTypes, keyType)
+	}
+
+	return keyTypes
+}
+
+// newKeyStore returns the keyring.Store selected by KEYRING_STORE, defaulting to an in-memory store. Persistent
+// stores require a key-encryption key.
+func newKeyStore(l hclog.Logger) keyring.Store {
+	switch os.Getenv("KEYRING_STORE") {
+	case "mysql":
+		envelope, err := keyring.NewEnvelopeFromEnv()
+		if err != nil {
+			panic(err)
+		}
+
+		db, err := utils.MySQLConnection()
+		if err != nil {
+			panic(err)
+		}
+
+		return client.NewSigningKeyClient(db, envelope, l)
+	case "file":
+		envelope, err := keyring.NewEnvelopeFromEnv()
+		if err != nil {
+			panic(err)
+		}
+
+		return keyring.NewFileStore(os.Getenv("KEYRING_FILE"), envelope)
+	default:
+		l.Warn("using in-memory key store, issued tokens will not survive a restart")
+		return keyring.NewMemoryStore()
+	}
+}
+
+// rewrapTOTPSecrets re-seals the TOTP secrets in the database with the current key-encryption key.
+func rewrapTOTPSecrets(l hclog.Logger) (int, error) {
+	envelope, err := keyring.NewEnvelopeFromEnv()
+	if err != nil {
+		return 0, err
+	}
+
+	db, err := utils.MySQLConnection()
+	if err != nil {
+		return 0, err
+	}
+
+	return client.NewMFAClient(db, envelope, l).RewrapSecrets()
+}
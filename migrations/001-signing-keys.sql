@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS signing_keys
+(
+    id           INT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    kid          VARCHAR(36) NOT NULL UNIQUE,
+    key_data     BLOB        NOT NULL,
+    active_until DATETIME    NOT NULL,
+    expires_at   DATETIME    NOT NULL
+);
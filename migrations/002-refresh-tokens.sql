@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS refresh_tokens
+(
+    id         INT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    user_id    INT UNSIGNED NOT NULL,
+    family_id  VARCHAR(36)  NOT NULL,
+    token_hash CHAR(64)     NOT NULL UNIQUE,
+    issued_at  DATETIME     NOT NULL,
+    expires_at DATETIME     NOT NULL,
+    used_at    DATETIME     NULL,
+    revoked_at DATETIME     NULL,
+    INDEX (family_id),
+    INDEX (user_id)
+);
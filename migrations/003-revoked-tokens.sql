@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS revoked_tokens
+(
+    id         INT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    jti        VARCHAR(36) NULL UNIQUE,
+    subject    VARCHAR(64) NULL,
+    revoked_at DATETIME    NOT NULL,
+    expires_at DATETIME    NOT NULL,
+    INDEX (subject),
+    INDEX (expires_at)
+);
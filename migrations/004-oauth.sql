@@ -0,0 +1,25 @@
+CREATE TABLE IF NOT EXISTS oauth_clients
+(
+    id            INT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    client_id     VARCHAR(64)  NOT NULL UNIQUE,
+    name          VARCHAR(255) NOT NULL,
+    redirect_uris TEXT         NOT NULL,
+    first_party   BOOLEAN      NOT NULL DEFAULT FALSE,
+    created_at    DATETIME     NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS authorization_codes
+(
+    id             INT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    code_hash      CHAR(64)     NOT NULL UNIQUE,
+    client_id      VARCHAR(64)  NOT NULL,
+    user_id        INT UNSIGNED NOT NULL,
+    family_id      VARCHAR(36)  NOT NULL,
+    redirect_uri   TEXT         NOT NULL,
+    scope          VARCHAR(255) NOT NULL DEFAULT '',
+    code_challenge VARCHAR(128) NOT NULL,
+    issued_at      DATETIME     NOT NULL,
+    expires_at     DATETIME     NOT NULL,
+    used_at        DATETIME     NULL,
+    INDEX (expires_at)
+);
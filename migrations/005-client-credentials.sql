@@ -0,0 +1,3 @@
+ALTER TABLE oauth_clients
+    ADD COLUMN client_secret_hash CHAR(64)      NULL AFTER client_id,
+    ADD COLUMN allowed_scopes     VARCHAR(1024) NOT NULL DEFAULT '' AFTER redirect_uris;
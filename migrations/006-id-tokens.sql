@@ -0,0 +1,4 @@
+ALTER TABLE authorization_codes
+    ADD COLUMN nonce     VARCHAR(255) NOT NULL DEFAULT '' AFTER code_challenge,
+    ADD COLUMN auth_time DATETIME     NOT NULL AFTER nonce,
+    ADD COLUMN amr       VARCHAR(64)  NOT NULL DEFAULT '' AFTER auth_time;
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS password_resets
+(
+    id         INT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    user_id    INT UNSIGNED NOT NULL,
+    token_hash CHAR(64)     NOT NULL UNIQUE,
+    issued_at  DATETIME     NOT NULL,
+    expires_at DATETIME     NOT NULL,
+    used_at    DATETIME     NULL,
+    INDEX (user_id),
+    INDEX (expires_at)
+);
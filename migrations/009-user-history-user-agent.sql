@@ -0,0 +1,2 @@
+ALTER TABLE user_history
+    ADD COLUMN user_agent VARCHAR(512) NOT NULL DEFAULT '' AFTER ip_address;
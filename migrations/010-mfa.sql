@@ -0,0 +1,21 @@
+CREATE TABLE IF NOT EXISTS user_totp
+(
+    id           INT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    user_id      INT UNSIGNED NOT NULL UNIQUE,
+    secret       BLOB         NOT NULL,
+    last_step    BIGINT       NOT NULL DEFAULT 0,
+    created_at   DATETIME     NOT NULL,
+    confirmed_at DATETIME     NULL
+);
+
+CREATE TABLE IF NOT EXISTS recovery_codes
+(
+    id        INT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    user_id   INT UNSIGNED NOT NULL,
+    code_hash CHAR(64)     NOT NULL,
+    used_at   DATETIME     NULL,
+    UNIQUE (user_id, code_hash)
+);
+
+ALTER TABLE refresh_tokens
+    ADD COLUMN amr VARCHAR(64) NOT NULL DEFAULT 'pwd' AFTER scope;
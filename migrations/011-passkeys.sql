@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS passkeys
+(
+    id               INT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    user_id          INT UNSIGNED     NOT NULL,
+    credential_id    VARBINARY(1023)  NOT NULL UNIQUE,
+    name             VARCHAR(64)      NOT NULL,
+    public_key       BLOB             NOT NULL,
+    attestation_type VARCHAR(32)      NOT NULL,
+    transports       VARCHAR(255)     NOT NULL,
+    aaguid           VARBINARY(16)    NOT NULL,
+    sign_count       INT UNSIGNED     NOT NULL DEFAULT 0,
+    flags            TINYINT UNSIGNED NOT NULL,
+    created_at       DATETIME         NOT NULL,
+    last_used_at     DATETIME         NULL,
+    INDEX (user_id)
+);
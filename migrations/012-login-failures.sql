@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS login_failures
+(
+    id             INT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    kind           VARCHAR(16)  NOT NULL,
+    subject        VARCHAR(45)  NOT NULL,
+    failures       INT UNSIGNED NOT NULL DEFAULT 0,
+    lockouts       INT UNSIGNED NOT NULL DEFAULT 0,
+    retry_at       DATETIME     NULL,
+    last_failed_at DATETIME     NOT NULL,
+    UNIQUE (kind, subject)
+);
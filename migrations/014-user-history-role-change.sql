@@ -0,0 +1,4 @@
+ALTER TABLE user_history
+    ADD COLUMN previous_role VARCHAR(16) NULL AFTER action,
+    ADD COLUMN new_role      VARCHAR(16) NULL AFTER previous_role,
+    ADD COLUMN changed_by    VARCHAR(64) NULL AFTER new_role;
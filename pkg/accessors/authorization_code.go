@@ -0,0 +1,13 @@
+package accessors
+
+import (
+	"database/sql"
+	"github.com/knockbox/authentication/pkg/models"
+)
+
+// AuthorizationCodeAccessor defines all queries available for models.AuthorizationCode
+type AuthorizationCodeAccessor interface {
+	Create(code models.AuthorizationCode) (sql.Result, error)
+	GetByHash(hash string) (*models.AuthorizationCode, error)
+	MarkUsed(id uint) (sql.Result, error)
+}
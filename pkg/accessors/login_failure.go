@@ -0,0 +1,15 @@
+package accessors
+
+import (
+	"database/sql"
+	"github.com/knockbox/authentication/pkg/models"
+	"time"
+)
+
+// LoginFailureAccessor defines all queries available for models.LoginFailure
+type LoginFailureAccessor interface {
+	Apply(kind, subject string, since time.Time, apply func(failure *models.LoginFailure) bool) (*models.LoginFailure, error)
+	ApplyOrCreate(kind, subject string, now, since time.Time, apply func(failure *models.LoginFailure) bool) (*models.LoginFailure, error)
+	DeleteBySubject(kind, subject string) (sql.Result, error)
+	DeleteStale(now, since time.Time) (sql.Result, error)
+}
@@ -0,0 +1,13 @@
+package accessors
+
+import (
+	"database/sql"
+	"github.com/knockbox/authentication/pkg/models"
+)
+
+// OAuthClientAccessor defines all queries available for models.OAuthClient
+type OAuthClientAccessor interface {
+	Create(client models.OAuthClient) (sql.Result, error)
+	GetByClientId(clientId string) (*models.OAuthClient, error)
+	DeleteByClientId(clientId string) (sql.Result, error)
+}
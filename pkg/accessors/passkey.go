@@ -0,0 +1,15 @@
+package accessors
+
+import (
+	"database/sql"
+	"github.com/knockbox/authentication/pkg/models"
+)
+
+// PasskeyAccessor defines all queries available for models.Passkey
+type PasskeyAccessor interface {
+	Create(passkey models.Passkey) (sql.Result, error)
+	GetByUserId(userId uint) ([]models.Passkey, error)
+	UpdateUsage(id uint, signCount uint32, flags uint8) (sql.Result, error)
+	DeleteByCredentialId(userId uint, credentialId []byte) (sql.Result, error)
+	DeleteByUserId(userId uint) (sql.Result, error)
+}
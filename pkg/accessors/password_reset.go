@@ -0,0 +1,14 @@
+package accessors
+
+import (
+	"database/sql"
+	"github.com/knockbox/authentication/pkg/models"
+)
+
+// PasswordResetAccessor defines all queries available for models.PasswordReset
+type PasswordResetAccessor interface {
+	Create(reset models.PasswordReset) (sql.Result, error)
+	GetByHash(hash string) (*models.PasswordReset, error)
+	MarkUsed(id uint) (sql.Result, error)
+	MarkUsedByUserId(userId uint) (sql.Result, error)
+}
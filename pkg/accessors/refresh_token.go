@@ -0,0 +1,15 @@
+package accessors
+
+import (
+	"database/sql"
+	"github.com/knockbox/authentication/pkg/models"
+)
+
+// RefreshTokenAccessor defines all queries available for models.RefreshToken
+type RefreshTokenAccessor interface {
+	Create(token models.RefreshToken) (sql.Result, error)
+	GetByHash(hash string) (*models.RefreshToken, error)
+	MarkUsed(id uint) (sql.Result, error)
+	RevokeByFamilyId(familyId string) (sql.Result, error)
+	RevokeByUserId(userId uint) (sql.Result, error)
+}
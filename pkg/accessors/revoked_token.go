@@ -0,0 +1,13 @@
+package accessors
+
+import (
+	"database/sql"
+	"github.com/knockbox/authentication/pkg/models"
+	"time"
+)
+
+// RevokedTokenAccessor defines all queries available for models.RevokedToken
+type RevokedTokenAccessor interface {
+	Create(token models.RevokedToken) (sql.Result, error)
+	CountActive(jti, subject string, issuedAt time.Time) (int, error)
+}
@@ -0,0 +1,14 @@
+package accessors
+
+import (
+	"database/sql"
+	"github.com/knockbox/authentication/pkg/models"
+)
+
+// SigningKeyAccessor defines all queries available for models.SigningKey
+type SigningKeyAccessor interface {
+	Upsert(key models.SigningKey) (sql.Result, error)
+	GetAll() ([]models.SigningKey, error)
+	DeleteByKid(kid string) (sql.Result, error)
+	WithLock(fn func() error) error
+}
@@ -12,6 +12,7 @@ type UserAccessor interface {
 	GetById(id int) (*models.User, error)
 	GetByAccountId(accountId string) (*models.User, error)
 	GetByUsername(username string) (*models.User, error)
+	GetByEmail(email string) (*models.User, error)
 	GetLikeUsername(username string, page models.Page) ([]models.User, error)
 	DeleteById(id int) (sql.Result, error)
 }
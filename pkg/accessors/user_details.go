@@ -9,4 +9,6 @@ import (
 type UserDetailsAccessor interface {
 	CreateForUser(userId int) (sql.Result, error)
 	Update(details models.UserDetails) (sql.Result, error)
+	UpdateVerified(userId uint, verified bool) (sql.Result, error)
+	GetByUserId(userId uint) (*models.UserDetails, error)
 }
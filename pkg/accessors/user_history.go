@@ -2,11 +2,14 @@ package accessors
 
 import (
 	"database/sql"
+	"github.com/knockbox/authentication/pkg/enums"
 	"github.com/knockbox/authentication/pkg/models"
+	"time"
 )
 
 // UserHistoryAccessor defines all queries available for models.UserHistory
 type UserHistoryAccessor interface {
 	Create(history models.UserHistory) (sql.Result, error)
 	GetByUserId(id int, page models.Page) ([]models.UserHistory, error)
+	CountRecent(userId uint, action enums.UserAction, window time.Duration) (int, error)
 }
@@ -0,0 +1,24 @@
+package accessors
+
+import (
+	"database/sql"
+	"github.com/knockbox/authentication/pkg/models"
+)
+
+// UserTOTPAccessor defines all queries available for models.UserTOTP
+type UserTOTPAccessor interface {
+	Upsert(totp models.UserTOTP) (sql.Result, error)
+	GetAll() ([]models.UserTOTP, error)
+	GetByUserId(userId uint) (*models.UserTOTP, error)
+	UpdateSecret(id uint, secret []byte) (sql.Result, error)
+	Confirm(userId uint, step int64) (sql.Result, error)
+	UseStep(userId uint, step int64) (sql.Result, error)
+	DeleteByUserId(userId uint) (sql.Result, error)
+}
+
+// RecoveryCodeAccessor defines all queries available for models.RecoveryCode
+type RecoveryCodeAccessor interface {
+	ReplaceForUser(userId uint, hashes []string) (sql.Result, error)
+	MarkUsed(userId uint, hash string) (sql.Result, error)
+	DeleteByUserId(userId uint) (sql.Result, error)
+}
@@ -0,0 +1,50 @@
+package enums
+
+import "strings"
+
+type Scope string
+
+const (
+	ScopeOpenID       Scope = "openid"
+	ScopeProfile      Scope = "profile"
+	ScopeEmail        Scope = "email"
+	ScopeUserRead     Scope = "user:read"
+	ScopeUserWrite    Scope = "user:write"
+	ScopeAdminKeys    Scope = "admin:keys"
+	ScopeAdminClients Scope = "admin:clients"
+)
+
+// Scopes is every scope that may be granted to a user.
+var Scopes = []Scope{
+	ScopeOpenID,
+	ScopeProfile,
+	ScopeEmail,
+	ScopeUserRead,
+	ScopeUserWrite,
+	ScopeAdminKeys,
+	ScopeAdminClients,
+}
+
+// ScopesForRole returns the scopes that may be granted to a user with the role.
+func ScopesForRole(role UserRole) []Scope {
+	if role.IsForbidden() {
+		return nil
+	}
+
+	scopes := []Scope{ScopeOpenID, ScopeProfile, ScopeEmail, ScopeUserRead, ScopeUserWrite}
+	if role.IsDeveloperOrAdmin() {
+		scopes = append(scopes, ScopeAdminKeys, ScopeAdminClients)
+	}
+
+	return scopes
+}
+
+// ScopesToString joins the scopes into the space separated form of the scope claim.
+func ScopesToString(scopes []Scope) string {
+	values := make([]string, len(scopes))
+	for i, scope := range scopes {
+		values[i] = string(scope)
+	}
+
+	return strings.Join(values, " ")
+}
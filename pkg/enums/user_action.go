@@ -3,10 +3,24 @@ package enums
 type UserAction string
 
 const (
-	Register       UserAction = "register"
-	Login                     = "login"
-	Logout                    = "logout"
-	VerifyEmail               = "verify_email"
-	UpdateEmail               = "update_email"
-	UpdatePassword            = "update_password"
+	Register                UserAction = "register"
+	Login                              = "login"
+	LoginFailed                        = "login_failed"
+	Logout                             = "logout"
+	VerifyEmail                        = "verify_email"
+	UpdateEmail                        = "update_email"
+	UpdatePassword                     = "update_password"
+	UpdateProfile                      = "update_profile"
+	UpdateRole                         = "update_role"
+	SendVerification                   = "send_verification"
+	SendPasswordReset                  = "send_password_reset"
+	SendEmailChange                    = "send_email_change"
+	EnableMFA                          = "enable_mfa"
+	DisableMFA                         = "disable_mfa"
+	RegenerateRecoveryCodes            = "regenerate_recovery_codes"
+	UseRecoveryCode                    = "use_recovery_code"
+	AddPasskey                         = "add_passkey"
+	RemovePasskey                      = "remove_passkey"
+	LockAccount                        = "lock_account"
+	UnlockAccount                      = "unlock_account"
 )
@@ -53,6 +53,11 @@ func (u UserRole) HasRequiredRole(required UserRole) bool {
 	return userRoleMap[u] >= userRoleMap[required]
 }
 
+// Outranks determines if the role is strictly above the other role.
+func (u UserRole) Outranks(other UserRole) bool {
+	return userRoleMap[u] > userRoleMap[other]
+}
+
 // IsDeveloperOrAdmin check to see if the user is a developer or admin
 func (u UserRole) IsDeveloperOrAdmin() bool {
 	return u == Developer || u == Admin
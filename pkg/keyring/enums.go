@@ -1,10 +1,24 @@
 package keyring
 
 import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
 	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"fmt"
 	"github.com/lestrrat-go/jwx/v2/jwa"
+	"strconv"
+	"strings"
 )
 
+// KeyType is a kind of signing key a KeySet can generate, paired with the algorithm it signs with.
+type KeyType interface {
+	GetAlgorithm() jwa.SignatureAlgorithm
+	GenerateKey() (interface{}, error)
+	String() string
+}
+
 type CurveType string
 
 const (
@@ -38,3 +52,117 @@ func (c CurveType) GetAlgorithm() jwa.SignatureAlgorithm {
 
 	return jwa.ES512
 }
+
+// GenerateKey returns a new *ecdsa.PrivateKey on the curve.
+func (c CurveType) GenerateKey() (interface{}, error) {
+	return ecdsa.GenerateKey(c.GetEllipticCurve(), rand.Reader)
+}
+
+func (c CurveType) String() string {
+	return string(c)
+}
+
+// RSAKeyType is an RSA modulus size paired with an RS* or PS* algorithm.
+type RSAKeyType struct {
+	Bits      int
+	Algorithm jwa.SignatureAlgorithm
+}
+
+var (
+	RS256 = RSAKeyType{Bits: 2048, Algorithm: jwa.RS256}
+	RS384 = RSAKeyType{Bits: 3072, Algorithm: jwa.RS384}
+	RS512 = RSAKeyType{Bits: 4096, Algorithm: jwa.RS512}
+	PS256 = RSAKeyType{Bits: 2048, Algorithm: jwa.PS256}
+	PS384 = RSAKeyType{Bits: 3072, Algorithm: jwa.PS384}
+	PS512 = RSAKeyType{Bits: 4096, Algorithm: jwa.PS512}
+)
+
+// NewRSAKeyType validates the modulus size and algorithm, bits must be one of 2048, 3072 or 4096.
+func NewRSAKeyType(bits int, alg jwa.SignatureAlgorithm) (RSAKeyType, error) {
+	switch bits {
+	case 2048, 3072, 4096:
+	default:
+		return RSAKeyType{}, fmt.Errorf("unsupported rsa key size %d", bits)
+	}
+
+	switch alg {
+	case jwa.RS256, jwa.RS384, jwa.RS512, jwa.PS256, jwa.PS384, jwa.PS512:
+	default:
+		return RSAKeyType{}, fmt.Errorf("unsupported rsa algorithm %s", alg)
+	}
+
+	return RSAKeyType{Bits: bits, Algorithm: alg}, nil
+}
+
+func (r RSAKeyType) GetAlgorithm() jwa.SignatureAlgorithm {
+	return r.Algorithm
+}
+
+// GenerateKey returns a new *rsa.PrivateKey of the modulus size.
+func (r RSAKeyType) GenerateKey() (interface{}, error) {
+	return rsa.GenerateKey(rand.Reader, r.Bits)
+}
+
+func (r RSAKeyType) String() string {
+	return fmt.Sprintf("%s-%d", r.Algorithm, r.Bits)
+}
+
+type OKPType string
+
+const (
+	Ed25519 OKPType = "Ed25519"
+)
+
+func (o OKPType) GetAlgorithm() jwa.SignatureAlgorithm {
+	return jwa.EdDSA
+}
+
+// GenerateKey returns a new ed25519.PrivateKey.
+func (o OKPType) GenerateKey() (interface{}, error) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	return key, err
+}
+
+func (o OKPType) String() string {
+	return string(o)
+}
+
+// ParseKeyType converts a configured name to a KeyType. Accepts curve names (P-256, P-384, P-521), RSA
+// algorithms optionally suffixed with the modulus size (RS256, PS384-4096) and Ed25519 or EdDSA.
+func ParseKeyType(name string) (KeyType, error) {
+	name = strings.TrimSpace(name)
+
+	switch CurveType(name) {
+	case P256, P384, P521:
+		return CurveType(name), nil
+	}
+
+	if name == string(Ed25519) || name == jwa.EdDSA.String() {
+		return Ed25519, nil
+	}
+
+	alg, bits, hasBits := strings.Cut(name, "-")
+	for _, preset := range []RSAKeyType{RS256, RS384, RS512, PS256, PS384, PS512} {
+		if preset.Algorithm.String() != alg {
+			continue
+		}
+
+		if !hasBits {
+			return preset, nil
+		}
+
+		n, err := strconv.Atoi(bits)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rsa key size in %q", name)
+		}
+
+		keyType, err := NewRSAKeyType(n, preset.Algorithm)
+		if err != nil {
+			return nil, err
+		}
+
+		return keyType, nil
+	}
+
+	return nil, fmt.Errorf("unknown key type %q", name)
+}
@@ -0,0 +1,59 @@
+package keyring
+
+import (
+	"github.com/lestrrat-go/jwx/v2/jwa"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestParseKeyType(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      string
+		want    KeyType
+		wantErr bool
+	}{
+		{
+			name: "Should parse curve",
+			in:   "P-384",
+			want: CurveType(P384),
+		},
+		{
+			name: "Should parse rsa preset",
+			in:   "RS256",
+			want: RS256,
+		},
+		{
+			name: "Should parse rsa with size",
+			in:   "PS256-4096",
+			want: RSAKeyType{Bits: 4096, Algorithm: jwa.PS256},
+		},
+		{
+			name: "Should parse EdDSA",
+			in:   "EdDSA",
+			want: Ed25519,
+		},
+		{
+			name:    "Should reject unsupported rsa size",
+			in:      "RS256-1024",
+			wantErr: true,
+		},
+		{
+			name:    "Should reject unknown type",
+			in:      "HS256",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseKeyType(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseKeyType() error = %v, wantErr = %v", err, tt.wantErr)
+				return
+			}
+
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
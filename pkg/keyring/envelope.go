@@ -0,0 +1,250 @@
+package keyring
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/lestrrat-go/jwx/v2/jwk"
+	"os"
+	"strings"
+)
+
+// ErrUnwrapKey is returned when stored key material cannot be unwrapped by any known key-encryption key.
+var ErrUnwrapKey = errors.New("stored key could not be unwrapped, check the key-encryption key")
+
+// Sealer encrypts key material before it leaves memory.
+type Sealer interface {
+	Seal(plaintext []byte) ([]byte, error)
+	Open(sealed []byte) ([]byte, error)
+}
+
+// Envelope is a Sealer that encrypts each payload with a fresh data-encryption key (DEK) which is in turn
+// wrapped by the key-encryption key (KEK). Previous KEK(s) are kept so material sealed before a rotation
+// can still be opened and re-wrapped.
+type Envelope struct {
+	kekId    string
+	kek      cipher.AEAD
+	previous map[string]cipher.AEAD
+}
+
+// sealedEnvelope is the persisted form of a sealed payload.
+type sealedEnvelope struct {
+	KekId string `json:"kek"`
+	DEK   []byte `json:"dek"`
+	Data  []byte `json:"data"`
+}
+
+// NewEnvelope creates an Envelope sealing with kek and able to open material sealed with any of previous.
+// Every KEK must be 32 bytes (AES-256).
+func NewEnvelope(kek []byte, previous ...[]byte) (*Envelope, error) {
+	current, err := newAEAD(kek)
+	if err != nil {
+		return nil, fmt.Errorf("invalid key-encryption key, %w", err)
+	}
+
+	e := &Envelope{
+		kekId:    kekId(kek),
+		kek:      current,
+		previous: make(map[string]cipher.AEAD),
+	}
+
+	for _, p := range previous {
+		aead, err := newAEAD(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid previous key-encryption key, %w", err)
+		}
+
+		e.previous[kekId(p)] = aead
+	}
+
+	return e, nil
+}
+
+// NewEnvelopeFromEnv creates an Envelope from the base64 KEK in KEYRING_KEK or the file at KEYRING_KEK_FILE,
+// along with the comma separated base64 KEK(s) in KEYRING_PREVIOUS_KEKS.
+func NewEnvelopeFromEnv() (*Envelope, error) {
+	encoded := os.Getenv("KEYRING_KEK")
+	if path := os.Getenv("KEYRING_KEK_FILE"); encoded == "" && path != "" {
+		bs, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read key-encryption key file, %w", err)
+		}
+		encoded = string(bs)
+	}
+
+	if strings.TrimSpace(encoded) == "" {
+		return nil, errors.New("no key-encryption key provided, set KEYRING_KEK or KEYRING_KEK_FILE")
+	}
+
+	kek, err := decodeKEK(encoded)
+	if err != nil {
+		return nil, err
+	}
+
+	var previous [][]byte
+	for _, p := range strings.Split(os.Getenv("KEYRING_PREVIOUS_KEKS"), ",") {
+		if strings.TrimSpace(p) == "" {
+			continue
+		}
+
+		bs, err := decodeKEK(p)
+		if err != nil {
+			return nil, err
+		}
+		previous = append(previous, bs)
+	}
+
+	return NewEnvelope(kek, previous...)
+}
+
+func (e *Envelope) Seal(plaintext []byte) ([]byte, error) {
+	dek := make([]byte, 32)
+	if _, err := rand.Read(dek); err != nil {
+		return nil, err
+	}
+
+	aead, err := newAEAD(dek)
+	if err != nil {
+		return nil, err
+	}
+
+	wrapped, err := seal(e.kek, dek)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := seal(aead, plaintext)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(sealedEnvelope{
+		KekId: e.kekId,
+		DEK:   wrapped,
+		Data:  data,
+	})
+}
+
+func (e *Envelope) Open(sealed []byte) ([]byte, error) {
+	env := sealedEnvelope{}
+	if err := json.Unmarshal(sealed, &env); err != nil {
+		return nil, fmt.Errorf("%w: malformed envelope", ErrUnwrapKey)
+	}
+
+	kek, ok := e.previous[env.KekId]
+	if env.KekId == e.kekId {
+		kek, ok = e.kek, true
+	}
+
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown key-encryption key %q", ErrUnwrapKey, env.KekId)
+	}
+
+	dek, err := open(kek, env.DEK)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnwrapKey, err)
+	}
+
+	aead, err := newAEAD(dek)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnwrapKey, err)
+	}
+
+	plaintext, err := open(aead, env.Data)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnwrapKey, err)
+	}
+
+	return plaintext, nil
+}
+
+// SealKey serializes and seals the jwk.Key.
+func SealKey(s Sealer, key jwk.Key) ([]byte, error) {
+	bs, err := json.Marshal(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.Seal(bs)
+}
+
+// OpenKey opens and parses a jwk.Key sealed with SealKey.
+func OpenKey(s Sealer, sealed []byte) (jwk.Key, error) {
+	bs, err := s.Open(sealed)
+	if err != nil {
+		return nil, err
+	}
+
+	return jwk.ParseKey(bs)
+}
+
+// Rewrap re-saves every key held by the Store so they are sealed with the current KEK. Stores must treat
+// Save as an upsert for this to work.
+func Rewrap(store Store) (int, error) {
+	keys, err := store.Load()
+	if err != nil {
+		return 0, err
+	}
+
+	for i, key := range keys {
+		if err := store.Save(key); err != nil {
+			return i, fmt.Errorf("failed to re-wrap key %s, %w", key.Key.KeyID(), err)
+		}
+	}
+
+	return len(keys), nil
+}
+
+func decodeKEK(encoded string) ([]byte, error) {
+	kek, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return nil, fmt.Errorf("key-encryption key must be base64 encoded, %w", err)
+	}
+
+	return kek, nil
+}
+
+// kekId derives a stable identifier for the KEK without revealing it.
+func kekId(kek []byte) string {
+	sum := sha256.Sum256(kek)
+	return hex.EncodeToString(sum[:8])
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	if len(key) != 32 {
+		return nil, fmt.Errorf("expected a 32 byte key, got %d bytes", len(key))
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// seal encrypts the plaintext and prefixes the nonce.
+func seal(aead cipher.AEAD, plaintext []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	return aead.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+// open decrypts a payload produced by seal.
+func open(aead cipher.AEAD, sealed []byte) ([]byte, error) {
+	if len(sealed) < aead.NonceSize() {
+		return nil, errors.New("sealed payload is too short")
+	}
+
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, nil)
+}
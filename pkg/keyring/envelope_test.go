@@ -0,0 +1,133 @@
+package keyring
+
+import (
+	"crypto/rand"
+	"errors"
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+	"path/filepath"
+	"testing"
+)
+
+func TestNewEnvelope(t *testing.T) {
+	tests := []struct {
+		name    string
+		kekLen  int
+		wantErr bool
+	}{
+		{
+			name:    "Valid KEK",
+			kekLen:  32,
+			wantErr: false,
+		},
+		{
+			name:    "Short KEK",
+			kekLen:  16,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewEnvelope(make([]byte, tt.kekLen))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NewEnvelope() error = %v, wantErr = %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestEnvelope_Open(t *testing.T) {
+	oldKek, newKek, otherKek := make([]byte, 32), make([]byte, 32), make([]byte, 32)
+	_, _ = rand.Read(oldKek)
+	_, _ = rand.Read(newKek)
+	_, _ = rand.Read(otherKek)
+
+	old, _ := NewEnvelope(oldKek)
+	sealed, err := old.Seal([]byte("secret"))
+	if err != nil {
+		t.Fatalf("GetPrivateKeySet() error = %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		current []byte
+		prev    [][]byte
+		wantErr bool
+	}{
+		{
+			name:    "Should open with the sealing KEK",
+			current: oldKek,
+			wantErr: false,
+		},
+		{
+			name:    "Should open with a previous KEK",
+			current: newKek,
+			prev:    [][]byte{oldKek},
+			wantErr: false,
+		},
+		{
+			name:    "Should not open with an unknown KEK",
+			current: otherKek,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			envelope, _ := NewEnvelope(tt.current, tt.prev...)
+
+			got, err := envelope.Open(sealed)
+			if tt.wantErr {
+				assert.True(t, errors.Is(err, ErrUnwrapKey), "error should be ErrUnwrapKey")
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, "secret", string(got))
+		})
+	}
+}
+
+func TestRewrap(t *testing.T) {
+	oldKek, newKek := make([]byte, 32), make([]byte, 32)
+	_, _ = rand.Read(oldKek)
+	_, _ = rand.Read(newKek)
+
+	path := filepath.Join(t.TempDir(), "keys.json")
+	old, _ := NewEnvelope(oldKek)
+
+	keyset, _ := NewSet(1000, 500, hclog.Default())
+	keyset.SetCurveTypes(P256)
+	keyset.SetStore(NewFileStore(path, old))
+	if err := keyset.Load(2); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	rotating, _ := NewEnvelope(newKek, oldKek)
+	n, err := Rewrap(NewFileStore(path, rotating))
+	assert.NoError(t, err)
+	assert.Equal(t, 2, n)
+
+	rotated, _ := NewEnvelope(newKek)
+	keys, err := NewFileStore(path, rotated).Load()
+	assert.NoError(t, err)
+	assert.Len(t, keys, 2, "keys should open without the previous KEK")
+}
+
+func TestKeySet_GetPrivateKeySet(t *testing.T) {
+	envelope := testEnvelope(t)
+
+	keyset, _ := NewSet(1000, 500, hclog.Default())
+	keyset.SetCurveTypes(P521)
+	_ = keyset.Generate(2)
+
+	sealed, err := keyset.GetPrivateKeySet(envelope)
+	if err != nil {
+		t.Fatalf("GetPrivateKeySet() error = %v", err)
+	}
+
+	opened, err := OpenKeySet(envelope, sealed)
+	assert.NoError(t, err)
+	assert.Len(t, opened.Keys, 2)
+}
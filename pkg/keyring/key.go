@@ -1,7 +1,45 @@
 package keyring
 
-import "github.com/lestrrat-go/jwx/v2/jwk"
+import (
+	"github.com/lestrrat-go/jwx/v2/jwk"
+	"time"
+)
 
 type KeySetResponse struct {
 	Keys []jwk.Key `json:"keys"`
 }
+
+const (
+	KeyStateActive     = "active"
+	KeyStateVerifyOnly = "verify-only"
+)
+
+// KeyState describes where a key is within its lifecycle.
+type KeyState struct {
+	Kid         string    `json:"kid"`
+	Algorithm   string    `json:"alg"`
+	State       string    `json:"state"`
+	ActiveUntil time.Time `json:"active_until"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+// OpenKeySet opens a KeySetResponse sealed by KeySet.GetPrivateKeySet.
+func OpenKeySet(s Sealer, sealed []byte) (*KeySetResponse, error) {
+	bs, err := s.Open(sealed)
+	if err != nil {
+		return nil, err
+	}
+
+	set, err := jwk.Parse(bs)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &KeySetResponse{}
+	for i := 0; i < set.Len(); i++ {
+		key, _ := set.Key(i)
+		response.Keys = append(response.Keys, key)
+	}
+
+	return response, nil
+}
@@ -2,12 +2,13 @@ package keyring
 
 import (
 	"context"
-	"crypto/ecdsa"
-	"crypto/rand"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"github.com/google/uuid"
 	"github.com/hashicorp/go-hclog"
 	"github.com/knockbox/authentication/pkg/utils"
+	"github.com/lestrrat-go/jwx/v2/jwa"
 	"github.com/lestrrat-go/jwx/v2/jwk"
 	"sync"
 	"time"
@@ -16,17 +17,20 @@ import (
 // KeySet holds all the keys within the set. Provides functionality for rotating keys and more.
 type KeySet struct {
 	keys               jwk.Set
-	curveTypes         []CurveType
+	keyTypes           []KeyType
 	keyLifespanSeconds int
 	jwtLifespanSeconds int
+	poolSize           int
 	activeKeys         map[string]*time.Timer
 	expiringKeys       map[string]*time.Timer
+	deadlines          map[string]StoredKey
+	store              Store
 
 	mu sync.RWMutex
 	l  hclog.Logger
 }
 
-// NewSet creates a new KeySet and generates n ecdsa P521 keys.
+// NewSet creates a new KeySet backed by a MemoryStore.
 func NewSet(keyLifespanSeconds, jwtLifespanSeconds int, l hclog.Logger) (*KeySet, error) {
 	if keyLifespanSeconds < jwtLifespanSeconds {
 		return nil, errors.New("invalid jwt key lifespan, keys will expire for valid jwt(s)")
@@ -34,48 +38,178 @@ func NewSet(keyLifespanSeconds, jwtLifespanSeconds int, l hclog.Logger) (*KeySet
 
 	return &KeySet{
 		keys:               jwk.NewSet(),
-		curveTypes:         nil,
+		keyTypes:           nil,
 		keyLifespanSeconds: keyLifespanSeconds,
 		jwtLifespanSeconds: jwtLifespanSeconds,
+		poolSize:           0,
 		expiringKeys:       make(map[string]*time.Timer),
 		activeKeys:         make(map[string]*time.Timer),
+		deadlines:          make(map[string]StoredKey),
+		store:              NewMemoryStore(),
 		mu:                 sync.RWMutex{},
 		l:                  l,
 	}, nil
 }
 
+// SetKeyTypes assigns the given KeyType(s) to the KeySet. These will be used when generating Keys, a set
+// may mix types.
+func (k *KeySet) SetKeyTypes(types ...KeyType) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	k.keyTypes = types
+}
+
 // SetCurveTypes assigns the given elliptic.Curve(s) to the KeySet. These will be used when generating Keys.
 func (k *KeySet) SetCurveTypes(types ...CurveType) {
-	k.curveTypes = types
+	var keyTypes []KeyType
+	for _, t := range types {
+		keyTypes = append(keyTypes, t)
+	}
+
+	k.SetKeyTypes(keyTypes...)
+}
+
+// GetAlgorithms returns the distinct signing algorithms of the keys in the set. Keys of a KeyType no longer
+// assigned are included while they remain to verify jwt(s), and assigned KeyType(s) without keys yet are not.
+func (k *KeySet) GetAlgorithms() []jwa.SignatureAlgorithm {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	seen := make(map[jwa.SignatureAlgorithm]bool)
+	var algs []jwa.SignatureAlgorithm
+	for _, key := range k.privateKeys() {
+		if alg := jwa.SignatureAlgorithm(key.Algorithm().String()); !seen[alg] {
+			seen[alg] = true
+			algs = append(algs, alg)
+		}
+	}
+
+	return algs
+}
+
+// SetStore assigns the Store that keys are loaded from and written through to. This should be called before
+// any keys are generated or loaded.
+func (k *KeySet) SetStore(store Store) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	k.store = store
+}
+
+// Load restores the keys held by the Store and generates enough new keys for n of them to be active. The
+// KeySet will keep n keys active from here on.
+func (k *KeySet) Load(n int) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	k.poolSize = n
+	if err := k.sync(); err != nil {
+		return err
+	}
+
+	return k.replenish()
+}
+
+// Sync reloads the Store every interval until the context is done. This lets instances sharing a Store
+// converge on the same set of keys.
+func (k *KeySet) Sync(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			k.mu.Lock()
+			if err := k.sync(); err != nil {
+				k.l.Error("failed to sync key store", "err", err)
+			} else if err := k.replenish(); err != nil {
+				k.l.Error("failed to replenish active keys", "err", err)
+			}
+			k.mu.Unlock()
+		}
+	}
+}
+
+// SetPoolSize changes the number of keys kept active. Growing the pool generates keys immediately, shrinking
+// it takes effect as the current active keys leave the signing pool.
+func (k *KeySet) SetPoolSize(n int) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	k.poolSize = n
+	return k.replenish()
+}
+
+// GetPoolSize returns the number of keys kept active.
+func (k *KeySet) GetPoolSize() int {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	return k.poolSize
+}
+
+// Rotate moves every active key out of the signing pool and replaces them with new keys. The previous keys
+// remain in the set to verify jwt(s) they have already signed until they expire.
+func (k *KeySet) Rotate() error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	now := time.Now()
+	for kid := range k.activeKeys {
+		k.deactivate(kid)
+
+		stored := k.deadlines[kid]
+		stored.ActiveUntil = now
+		k.deadlines[kid] = stored
+
+		if err := k.store.Save(stored); err != nil {
+			return fmt.Errorf("failed to store rotated jwk, %w", err)
+		}
+		k.l.Info("rotated key", "kid", kid)
+	}
+
+	return k.replenish()
 }
 
-// Generate creates n keys and adds them to the set.
+// Generate creates n keys, writes them to the Store and adds them to the set.
 func (k *KeySet) Generate(n int) error {
-	if k.curveTypes == nil || len(k.curveTypes) == 0 {
-		return errors.New("no curve type(s) assigned to this set")
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	k.poolSize += n
+	return k.generate(n)
+}
+
+// generate creates n keys, the caller must hold the write lock.
+func (k *KeySet) generate(n int) error {
+	if k.keyTypes == nil || len(k.keyTypes) == 0 {
+		return errors.New("no key type(s) assigned to this set")
 	}
 
 	for i := 0; i < n; i++ {
-		idx, err := utils.CryptoRandom(len(k.curveTypes))
+		idx, err := utils.CryptoRandom(len(k.keyTypes))
 		if err != nil {
 			return err
 		}
 
 		// Generate the key
-		curve := k.curveTypes[idx]
-		rawKey, err := ecdsa.GenerateKey(curve.GetEllipticCurve(), rand.Reader)
+		keyType := k.keyTypes[idx]
+		rawKey, err := keyType.GenerateKey()
 		if err != nil {
-			return errors.New("failed to generate ecdsa private key")
+			return fmt.Errorf("failed to generate %s private key", keyType)
 		}
 
 		// Convert raw key to jwk
 		key, err := jwk.FromRaw(rawKey)
 		if err != nil {
-			return errors.New("failed to create jwk from raw ecdsa private key")
+			return fmt.Errorf("failed to create jwk from raw %s private key", keyType)
 		}
 
 		// Assign the algorithm
-		_ = key.Set(jwk.AlgorithmKey, curve.GetAlgorithm())
+		_ = key.Set(jwk.AlgorithmKey, keyType.GetAlgorithm())
 
 		// Set a random kid
 		if err := key.Set(jwk.KeyIDKey, uuid.NewString()); err != nil {
@@ -87,41 +221,162 @@ func (k *KeySet) Generate(n int) error {
 			return errors.New("failed to set jwk use")
 		}
 
-		// Add the key to the set
-		if err := k.keys.AddKey(key); err != nil {
-			return errors.New("failed to add jwk to set")
+		now := time.Now()
+		stored := StoredKey{
+			Key:         key,
+			ActiveUntil: now.Add(k.GetSigningDuration()),
+			ExpiresAt:   now.Add(k.GetLifespanDuration()),
+		}
+
+		// Write the key through before it can be used for signing
+		if err := k.store.Save(stored); err != nil {
+			return fmt.Errorf("failed to store jwk, %w", err)
+		}
+
+		if err := k.track(stored); err != nil {
+			return err
 		}
 		k.l.Debug("generated key", "kid", key.KeyID())
+	}
+
+	return nil
+}
 
-		signingLifespan := time.Now().Add(k.GetSigningDuration()).Sub(time.Now())
-		totalLifespan := time.Now().Add(k.GetLifespanDuration()).Sub(time.Now())
+// track adds the key to the set and schedules its timers from the stored deadlines, the caller must hold
+// the write lock.
+func (k *KeySet) track(stored StoredKey) error {
+	key := stored.Key
+	kid := key.KeyID()
 
-		// Func for moving keys out of the signing pool
-		k.activeKeys[key.KeyID()] = time.AfterFunc(signingLifespan, func() {
+	// Add the key to the set
+	if err := k.keys.AddKey(key); err != nil {
+		return errors.New("failed to add jwk to set")
+	}
+	k.deadlines[kid] = stored
+
+	// Func for moving keys out of the signing pool
+	if stored.IsActive() {
+		k.activeKeys[kid] = time.AfterFunc(time.Until(stored.ActiveUntil), func() {
 			k.mu.Lock()
 			defer k.mu.Unlock()
 
-			delete(k.activeKeys, key.KeyID())
-			k.l.Info("active key expired", "kid", key.KeyID())
+			delete(k.activeKeys, kid)
+			k.l.Info("active key expired", "kid", kid)
 
-			if err := k.Generate(1); err != nil {
-				k.l.Error("failed to replace active key", "kid", key.KeyID())
+			if err := k.replenish(); err != nil {
+				k.l.Error("failed to replace active key", "kid", kid, "err", err)
 			}
 		})
+	}
 
-		// Func for moving keys out of the set
-		k.expiringKeys[key.KeyID()] = time.AfterFunc(totalLifespan, func() {
-			k.mu.Lock()
-			defer k.mu.Unlock()
+	// Func for moving keys out of the set
+	k.expiringKeys[kid] = time.AfterFunc(time.Until(stored.ExpiresAt), func() {
+		k.mu.Lock()
+		defer k.mu.Unlock()
 
-			delete(k.expiringKeys, key.KeyID())
-			_ = k.keys.RemoveKey(key)
-		})
+		k.untrack(kid)
+		if err := k.store.Delete(kid); err != nil {
+			k.l.Error("failed to delete expired key", "kid", kid, "err", err)
+		}
+	})
+
+	return nil
+}
+
+// untrack removes the key from the set and stops its timers, the caller must hold the write lock.
+func (k *KeySet) untrack(kid string) {
+	if key, exists := k.keys.LookupKeyID(kid); exists {
+		_ = k.keys.RemoveKey(key)
+	}
+
+	k.deactivate(kid)
+	delete(k.deadlines, kid)
+
+	// Stop the expiring timer.
+	if expiringTimer, ok := k.expiringKeys[kid]; ok {
+		expiringTimer.Stop()
+	}
+	delete(k.expiringKeys, kid)
+}
+
+// deactivate moves the key out of the signing pool, the caller must hold the write lock.
+func (k *KeySet) deactivate(kid string) {
+	if aliveTimer, ok := k.activeKeys[kid]; ok {
+		aliveTimer.Stop()
+	}
+	delete(k.activeKeys, kid)
+}
+
+// sync reconciles the set with the Store. Keys missing from the set are tracked and keys missing from the
+// Store, such as those revoked by another instance, are untracked. The caller must hold the write lock.
+func (k *KeySet) sync() error {
+	stored, err := k.store.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load keys from store, %w", err)
+	}
+
+	known := make(map[string]bool)
+	for _, s := range stored {
+		kid := s.Key.KeyID()
+		if s.IsExpired() {
+			if err := k.store.Delete(kid); err != nil {
+				k.l.Warn("failed to delete expired key", "kid", kid, "err", err)
+			}
+			continue
+		}
+
+		known[kid] = true
+		if _, exists := k.keys.LookupKeyID(kid); exists {
+			// Another instance may have rotated the key out of the signing pool.
+			if _, active := k.activeKeys[kid]; active && !s.IsActive() {
+				k.deactivate(kid)
+				k.deadlines[kid] = s
+				k.l.Info("key rotated by store", "kid", kid)
+			}
+			continue
+		}
+
+		if err := k.track(s); err != nil {
+			return err
+		}
+		k.l.Debug("loaded key", "kid", kid, "active", s.IsActive())
+	}
+
+	for kid := range k.expiringKeys {
+		if !known[kid] {
+			k.untrack(kid)
+			k.l.Info("key removed from store", "kid", kid)
+		}
 	}
 
 	return nil
 }
 
+// replenish generates keys until the pool size is met, the caller must hold the write lock. With a SharedStore
+// the pool is counted again after syncing under its lock, so keys another instance generated meanwhile count.
+func (k *KeySet) replenish() error {
+	if k.poolSize-len(k.activeKeys) <= 0 {
+		return nil
+	}
+
+	shared, ok := k.store.(SharedStore)
+	if !ok {
+		return k.generate(k.poolSize - len(k.activeKeys))
+	}
+
+	return shared.WithLock(func() error {
+		if err := k.sync(); err != nil {
+			return err
+		}
+
+		if missing := k.poolSize - len(k.activeKeys); missing > 0 {
+			return k.generate(missing)
+		}
+
+		return nil
+	})
+}
+
 // GetRandomKey returns a random jwk.Key that is active in the current set.
 func (k *KeySet) GetRandomKey() jwk.Key {
 	k.mu.RLock()
@@ -142,10 +397,40 @@ func (k *KeySet) GetRandomKey() jwk.Key {
 		validKeys = append(validKeys, key)
 	}
 
-	idx, _ := utils.CryptoRandom(len(k.activeKeys))
+	if len(validKeys) == 0 {
+		return nil
+	}
+
+	idx, _ := utils.CryptoRandom(len(validKeys))
 	return validKeys[idx]
 }
 
+// GetKeyStates returns the state of every key in the set.
+func (k *KeySet) GetKeyStates() []KeyState {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	var states []KeyState
+	for _, key := range k.privateKeys() {
+		stored := k.deadlines[key.KeyID()]
+
+		state := KeyStateVerifyOnly
+		if _, active := k.activeKeys[key.KeyID()]; active {
+			state = KeyStateActive
+		}
+
+		states = append(states, KeyState{
+			Kid:         key.KeyID(),
+			Algorithm:   key.Algorithm().String(),
+			State:       state,
+			ActiveUntil: stored.ActiveUntil,
+			ExpiresAt:   stored.ExpiresAt,
+		})
+	}
+
+	return states
+}
+
 // GetKeyById returns the jwk.Key if one exists for the given kid, otherwise the second return value is false if the key
 // is not found.
 func (k *KeySet) GetKeyById(kid string) (jwk.Key, bool) {
@@ -155,58 +440,41 @@ func (k *KeySet) GetKeyById(kid string) (jwk.Key, bool) {
 	return k.keys.LookupKeyID(kid)
 }
 
-// RevokeKeyById removes a key from the set.
-func (k *KeySet) RevokeKeyById(kid string) {
+// RevokeKeyById removes a key from the set and the Store. An active key is replaced so the pool size is
+// kept. Returns false if the key was not found.
+func (k *KeySet) RevokeKeyById(kid string) bool {
 	k.mu.Lock()
 	defer k.mu.Unlock()
 
 	// Lookup the key and remove it if it exists.
-	key, exists := k.keys.LookupKeyID(kid)
-	if !exists {
-		return
+	if _, exists := k.keys.LookupKeyID(kid); !exists {
+		return false
 	}
-	_ = k.keys.RemoveKey(key)
+	k.untrack(kid)
 
-	// Stop the active timer.
-	if aliveTimer, ok := k.activeKeys[kid]; ok {
-		if !aliveTimer.Stop() {
-			<-aliveTimer.C
-		}
+	if err := k.store.Delete(kid); err != nil {
+		k.l.Error("failed to delete revoked key", "kid", kid, "err", err)
 	}
-	delete(k.activeKeys, kid)
 
-	// Stop the expiring timer.
-	if expiringTimer, ok := k.expiringKeys[kid]; ok {
-		if !expiringTimer.Stop() {
-			<-expiringTimer.C
-		}
+	if err := k.replenish(); err != nil {
+		k.l.Error("failed to replace revoked key", "kid", kid, "err", err)
 	}
-	delete(k.expiringKeys, kid)
+
+	return true
 }
 
-// GetPrivateKeySet returns all the jwk.Key(s) stored in the set containing the private key information.
-func (k *KeySet) GetPrivateKeySet() *KeySetResponse {
+// GetPrivateKeySet returns all the jwk.Key(s) stored in the set containing the private key information, sealed
+// by s so the private keys never leave memory in plaintext. Open it with OpenKeySet.
+func (k *KeySet) GetPrivateKeySet(s Sealer) ([]byte, error) {
 	k.mu.RLock()
 	defer k.mu.RUnlock()
 
-	ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(5*time.Second))
-	defer cancel()
-
-	var keys []jwk.Key
-	for it := k.keys.Keys(ctx); it.Next(ctx); {
-		pair := it.Pair()
-		if pair == nil {
-			continue
-		}
-
-		key, ok := pair.Value.(jwk.Key)
-		if !ok {
-			k.l.Debug("failed to iterate key", "pair", pair)
-		}
-		keys = append(keys, key)
+	bs, err := json.Marshal(&KeySetResponse{Keys: k.privateKeys()})
+	if err != nil {
+		return nil, err
 	}
 
-	return &KeySetResponse{Keys: keys}
+	return s.Seal(bs)
 }
 
 // GetPublicKeySet returns all the jwk.Key(s) stored in the set without any private key information.
@@ -214,13 +482,12 @@ func (k *KeySet) GetPublicKeySet() *KeySetResponse {
 	k.mu.RLock()
 	defer k.mu.RUnlock()
 
-	privateKeys := k.GetPrivateKeySet()
-
 	var publicKeys []jwk.Key
-	for _, pk := range privateKeys.Keys {
+	for _, pk := range k.privateKeys() {
 		key, err := pk.PublicKey()
 		if err != nil {
 			k.l.Debug("failed to convert key to public key", "kid", pk.KeyID())
+			continue
 		}
 
 		publicKeys = append(publicKeys, key)
@@ -229,6 +496,29 @@ func (k *KeySet) GetPublicKeySet() *KeySetResponse {
 	return &KeySetResponse{Keys: publicKeys}
 }
 
+// privateKeys returns all the jwk.Key(s) in the set, the caller must hold the read lock.
+func (k *KeySet) privateKeys() []jwk.Key {
+	ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(5*time.Second))
+	defer cancel()
+
+	var keys []jwk.Key
+	for it := k.keys.Keys(ctx); it.Next(ctx); {
+		pair := it.Pair()
+		if pair == nil {
+			continue
+		}
+
+		key, ok := pair.Value.(jwk.Key)
+		if !ok {
+			k.l.Debug("failed to iterate key", "pair", pair)
+			continue
+		}
+		keys = append(keys, key)
+	}
+
+	return keys
+}
+
 // GetTokenDuration returns the duration in seconds a jwt is valid for.
 func (k *KeySet) GetTokenDuration() time.Duration {
 	return time.Duration(k.jwtLifespanSeconds) * time.Second
@@ -2,8 +2,11 @@ package keyring
 
 import (
 	"github.com/hashicorp/go-hclog"
+	"github.com/lestrrat-go/jwx/v2/jwa"
 	"github.com/stretchr/testify/assert"
+	"sync"
 	"testing"
+	"time"
 )
 
 func TestNewKeySet(t *testing.T) {
@@ -70,7 +73,9 @@ func TestKeySet_SetCurveTypes(t *testing.T) {
 			}
 
 			keyset.SetCurveTypes(tt.args.curveTypes...)
-			assert.Equal(t, tt.args.curveTypes, keyset.curveTypes)
+			for i, curveType := range tt.args.curveTypes {
+				assert.Equal(t, curveType, keyset.keyTypes[i])
+			}
 		})
 	}
 }
@@ -117,7 +122,7 @@ func TestKeySet_Generate(t *testing.T) {
 				return
 			}
 
-			for _, key := range keyset.GetPrivateKeySet().Keys {
+			for _, key := range keyset.privateKeys() {
 				assert.NotNil(t, keyset.activeKeys[key.KeyID()], "should be active")
 				assert.NotNil(t, keyset.expiringKeys[key.KeyID()], "should be expiring")
 				assert.Equal(t, tt.args.curveType.GetAlgorithm(), key.Algorithm())
@@ -125,3 +130,295 @@ func TestKeySet_Generate(t *testing.T) {
 		})
 	}
 }
+
+func TestKeySet_Load(t *testing.T) {
+	tests := []struct {
+		name     string
+		existing int
+		n        int
+		want     int
+	}{
+		{
+			name:     "Should generate into an empty store",
+			existing: 0,
+			n:        3,
+			want:     3,
+		},
+		{
+			name:     "Should restore keys from the store",
+			existing: 3,
+			n:        3,
+			want:     3,
+		},
+		{
+			name:     "Should top up a partial store",
+			existing: 1,
+			n:        3,
+			want:     3,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := NewMemoryStore()
+
+			previous, _ := NewSet(1000, 500, hclog.Default())
+			previous.SetCurveTypes(P256)
+			previous.SetStore(store)
+			if err := previous.Generate(tt.existing); err != nil {
+				t.Errorf("Generate() error = %v", err)
+				return
+			}
+
+			keyset, _ := NewSet(1000, 500, hclog.Default())
+			keyset.SetCurveTypes(P256)
+			keyset.SetStore(store)
+			if err := keyset.Load(tt.n); err != nil {
+				t.Errorf("Load() error = %v", err)
+				return
+			}
+
+			stored, _ := store.Load()
+			assert.Len(t, keyset.activeKeys, tt.want)
+			assert.Len(t, stored, tt.want)
+
+			for _, key := range previous.privateKeys() {
+				_, ok := keyset.GetKeyById(key.KeyID())
+				assert.True(t, ok, "previous key should be restored")
+			}
+		})
+	}
+}
+
+func TestKeySet_RevokeKeyById(t *testing.T) {
+	store := NewMemoryStore()
+
+	keyset, _ := NewSet(1000, 500, hclog.Default())
+	keyset.SetCurveTypes(P256)
+	keyset.SetStore(store)
+	if err := keyset.Load(2); err != nil {
+		t.Errorf("Load() error = %v", err)
+		return
+	}
+
+	kid := keyset.GetRandomKey().KeyID()
+	assert.True(t, keyset.RevokeKeyById(kid))
+	assert.False(t, keyset.RevokeKeyById(kid), "revoking an unknown key should report not found")
+
+	_, ok := keyset.GetKeyById(kid)
+	assert.False(t, ok, "revoked key should be removed from the set")
+	assert.Nil(t, keyset.activeKeys[kid], "revoked key should not be active")
+	assert.Len(t, keyset.activeKeys, 2, "revoked key should be replaced")
+
+	stored, _ := store.Load()
+	for _, key := range stored {
+		assert.NotEqual(t, kid, key.Key.KeyID(), "revoked key should be removed from the store")
+	}
+}
+
+func TestKeySet_Rotate(t *testing.T) {
+	store := NewMemoryStore()
+
+	keyset, _ := NewSet(1000, 500, hclog.Default())
+	keyset.SetCurveTypes(P256)
+	keyset.SetStore(store)
+	if err := keyset.Load(2); err != nil {
+		t.Errorf("Load() error = %v", err)
+		return
+	}
+
+	var previous []string
+	for kid := range keyset.activeKeys {
+		previous = append(previous, kid)
+	}
+
+	if err := keyset.Rotate(); err != nil {
+		t.Errorf("Rotate() error = %v", err)
+		return
+	}
+
+	assert.Len(t, keyset.activeKeys, 2, "rotated keys should be replaced")
+	for _, kid := range previous {
+		assert.Nil(t, keyset.activeKeys[kid], "rotated key should not be active")
+
+		_, ok := keyset.GetKeyById(kid)
+		assert.True(t, ok, "rotated key should remain for verification")
+	}
+
+	states := keyset.GetKeyStates()
+	assert.Len(t, states, 4)
+	for _, state := range states {
+		if state.State == KeyStateVerifyOnly {
+			assert.Contains(t, previous, state.Kid)
+		}
+	}
+
+	// Another instance sharing the store should see the rotation.
+	replica, _ := NewSet(1000, 500, hclog.Default())
+	replica.SetCurveTypes(P256)
+	replica.SetStore(store)
+	_ = replica.Load(2)
+	for _, kid := range previous {
+		assert.Nil(t, replica.activeKeys[kid], "rotated key should not be active on a replica")
+	}
+}
+
+// lockingStore is a SharedStore over a MemoryStore.
+type lockingStore struct {
+	*MemoryStore
+	mu sync.Mutex
+}
+
+func (l *lockingStore) WithLock(fn func() error) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return fn()
+}
+
+func TestKeySet_Replenish(t *testing.T) {
+	tests := []struct {
+		name  string
+		store Store
+		want  int
+	}{
+		{
+			name:  "Should replace an expired key once across instances sharing a lock",
+			store: &lockingStore{MemoryStore: NewMemoryStore()},
+			want:  3,
+		},
+		{
+			name:  "Should replace an expired key on every instance without a lock",
+			store: NewMemoryStore(),
+			want:  4,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var replicas []*KeySet
+			for i := 0; i < 2; i++ {
+				keyset, _ := NewSet(1000, 500, hclog.Default())
+				keyset.SetCurveTypes(P256)
+				keyset.SetStore(tt.store)
+				if err := keyset.Load(2); err != nil {
+					t.Errorf("Load() error = %v", err)
+					return
+				}
+				replicas = append(replicas, keyset)
+			}
+
+			// Expire one key in the store and on every replica, as their timers would.
+			var kid string
+			for kid = range replicas[0].activeKeys {
+				break
+			}
+			stored := replicas[0].deadlines[kid]
+			stored.ActiveUntil = time.Now()
+			_ = tt.store.Save(stored)
+
+			for _, keyset := range replicas {
+				keyset.mu.Lock()
+				keyset.deactivate(kid)
+				if err := keyset.replenish(); err != nil {
+					t.Errorf("replenish() error = %v", err)
+				}
+				keyset.mu.Unlock()
+			}
+
+			keys, _ := tt.store.Load()
+			assert.Len(t, keys, tt.want)
+			for _, keyset := range replicas {
+				assert.Len(t, keyset.activeKeys, 2)
+			}
+		})
+	}
+}
+
+func TestKeySet_SetPoolSize(t *testing.T) {
+	keyset, _ := NewSet(1000, 500, hclog.Default())
+	keyset.SetCurveTypes(P256)
+	_ = keyset.Load(1)
+
+	if err := keyset.SetPoolSize(3); err != nil {
+		t.Errorf("SetPoolSize() error = %v", err)
+		return
+	}
+
+	assert.Equal(t, 3, keyset.GetPoolSize())
+	assert.Len(t, keyset.activeKeys, 3)
+}
+
+func TestKeySet_GetAlgorithms(t *testing.T) {
+	keyset, _ := NewSet(1000, 500, hclog.Default())
+	keyset.SetCurveTypes(P256)
+	_ = keyset.Load(1)
+
+	// Keys of the previous type still verify jwt(s), the new type has no keys yet.
+	keyset.SetKeyTypes(RS256)
+	assert.Equal(t, []jwa.SignatureAlgorithm{jwa.ES256}, keyset.GetAlgorithms())
+
+	if err := keyset.Rotate(); err != nil {
+		t.Errorf("Rotate() error = %v", err)
+		return
+	}
+	assert.ElementsMatch(t, []jwa.SignatureAlgorithm{jwa.ES256, jwa.RS256}, keyset.GetAlgorithms())
+
+	for _, state := range keyset.GetKeyStates() {
+		if state.State == KeyStateVerifyOnly {
+			_ = keyset.RevokeKeyById(state.Kid)
+		}
+	}
+	assert.Equal(t, []jwa.SignatureAlgorithm{jwa.RS256}, keyset.GetAlgorithms())
+}
+
+func TestKeySet_GenerateKeyTypes(t *testing.T) {
+	tests := []struct {
+		name     string
+		keyTypes []KeyType
+	}{
+		{
+			name:     "Should generate RS256",
+			keyTypes: []KeyType{RS256},
+		},
+		{
+			name:     "Should generate PS256",
+			keyTypes: []KeyType{PS256},
+		},
+		{
+			name:     "Should generate Ed25519",
+			keyTypes: []KeyType{Ed25519},
+		},
+		{
+			name:     "Should generate a mixed set",
+			keyTypes: []KeyType{CurveType(P256), RS256, Ed25519},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keyset, _ := NewSet(1000, 500, hclog.Default())
+			keyset.SetKeyTypes(tt.keyTypes...)
+
+			if err := keyset.Generate(3); err != nil {
+				t.Errorf("Generate() error = %v", err)
+				return
+			}
+
+			var algs []jwa.SignatureAlgorithm
+			for _, keyType := range tt.keyTypes {
+				algs = append(algs, keyType.GetAlgorithm())
+			}
+
+			generated := make(map[jwa.SignatureAlgorithm]bool)
+			for _, key := range keyset.privateKeys() {
+				assert.Contains(t, algs, key.Algorithm())
+				generated[jwa.SignatureAlgorithm(key.Algorithm().String())] = true
+			}
+
+			assert.Len(t, keyset.GetPublicKeySet().Keys, 3)
+			assert.NotNil(t, keyset.GetRandomKey())
+			assert.Len(t, keyset.GetAlgorithms(), len(generated))
+			for _, alg := range keyset.GetAlgorithms() {
+				assert.True(t, generated[alg])
+			}
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package keyring
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/lestrrat-go/jwx/v2/jws"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+)
+
+const (
+	// TypeAccessToken is the typ header of access tokens, see RFC 9068.
+	TypeAccessToken = "at+jwt"
+
+	// TypeIDToken is the typ header of OpenID Connect ID tokens, which are never accepted as access tokens.
+	TypeIDToken = "JWT"
+
+	// TypeVerificationToken is the typ header of the tokens in email verification links.
+	TypeVerificationToken = "verify+jwt"
+
+	// TypeMFAToken is the typ header of the tokens proving a password was accepted while a second factor is due.
+	TypeMFAToken = "mfa+jwt"
+
+	// TypeWebAuthnToken is the typ header of the tokens holding the state of a passkey ceremony.
+	TypeWebAuthnToken = "webauthn+jwt"
+)
+
+// Sign signs the jwt.Token with a random active key. The kid of the key and the given typ are set on the
+// protected header so verifiers can select the key without trying the whole set.
+func (k *KeySet) Sign(token jwt.Token, typ string) ([]byte, error) {
+	key := k.GetRandomKey()
+	if key == nil {
+		return nil, errors.New("no active key available for signing")
+	}
+
+	headers := jws.NewHeaders()
+	if err := headers.Set(jws.KeyIDKey, key.KeyID()); err != nil {
+		return nil, err
+	}
+
+	if err := headers.Set(jws.TypeKey, typ); err != nil {
+		return nil, err
+	}
+
+	return jwt.Sign(token, jwt.WithKey(key.Algorithm(), key, jws.WithProtectedHeaders(headers)))
+}
+
+// Verify parses the signed jwt, verifying it with exactly the key named by its kid. The typ and alg of the
+// protected header must match the expected typ and the key. Additional jwt.ParseOption(s) such as issuer
+// validation are applied.
+func (k *KeySet) Verify(signed []byte, typ string, options ...jwt.ParseOption) (jwt.Token, error) {
+	provider := jws.KeyProviderFunc(func(_ context.Context, sink jws.KeySink, sig *jws.Signature, _ *jws.Message) error {
+		headers := sig.ProtectedHeaders()
+		if headers.Type() != typ {
+			return fmt.Errorf("unexpected token typ %q", headers.Type())
+		}
+
+		key, ok := k.GetKeyById(headers.KeyID())
+		if !ok {
+			return fmt.Errorf("unknown kid %q", headers.KeyID())
+		}
+
+		if key.Algorithm().String() != headers.Algorithm().String() {
+			return fmt.Errorf("alg %q does not match key %q", headers.Algorithm(), headers.KeyID())
+		}
+
+		sink.Key(headers.Algorithm(), key)
+		return nil
+	})
+
+	return jwt.Parse(signed, append([]jwt.ParseOption{jwt.WithKeyProvider(provider)}, options...)...)
+}
@@ -0,0 +1,64 @@
+package keyring
+
+import (
+	"github.com/hashicorp/go-hclog"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestKeySet_Verify(t *testing.T) {
+	keyset, _ := NewSet(1000, 500, hclog.Default())
+	keyset.SetKeyTypes(RS256, Ed25519)
+	_ = keyset.Load(2)
+
+	other, _ := NewSet(1000, 500, hclog.Default())
+	other.SetKeyTypes(RS256)
+	_ = other.Load(1)
+
+	token, _ := jwt.NewBuilder().
+		Subject("account").
+		Expiration(time.Now().Add(time.Minute)).
+		Build()
+
+	signed, _ := keyset.Sign(token, TypeAccessToken)
+	wrongTyp, _ := keyset.Sign(token, "JWT")
+	unknownKid, _ := other.Sign(token, TypeAccessToken)
+
+	tests := []struct {
+		name    string
+		signed  []byte
+		wantErr bool
+	}{
+		{
+			name:    "Should verify signed token",
+			signed:  signed,
+			wantErr: false,
+		},
+		{
+			name:    "Should reject unexpected typ",
+			signed:  wrongTyp,
+			wantErr: true,
+		},
+		{
+			name:    "Should reject unknown kid",
+			signed:  unknownKid,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := keyset.Verify(tt.signed, TypeAccessToken)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Verify() error = %v, wantErr = %v", err, tt.wantErr)
+				return
+			}
+
+			if !tt.wantErr {
+				assert.Equal(t, "account", got.Subject())
+			}
+		})
+	}
+}
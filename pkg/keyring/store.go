@@ -0,0 +1,226 @@
+package keyring
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/lestrrat-go/jwx/v2/jwk"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// StoredKey is a jwk.Key along with the deadlines that drive its lifecycle within a KeySet.
+type StoredKey struct {
+	Key         jwk.Key
+	ActiveUntil time.Time
+	ExpiresAt   time.Time
+}
+
+// IsActive determines if the key may still be used for signing new jwt(s).
+func (s StoredKey) IsActive() bool {
+	return time.Now().Before(s.ActiveUntil)
+}
+
+// IsExpired determines if the key should no longer be used for verifying jwt(s).
+func (s StoredKey) IsExpired() bool {
+	return !time.Now().Before(s.ExpiresAt)
+}
+
+// Store persists the keys of a KeySet so they survive restarts and can be shared between instances.
+type Store interface {
+	// Load returns every key held by the store, including those that are no longer active.
+	Load() ([]StoredKey, error)
+	// Save writes the key through to the store.
+	Save(key StoredKey) error
+	// Delete removes the key from the store, deleting a key that does not exist is not an error.
+	Delete(kid string) error
+}
+
+// SharedStore is a Store shared between instances. Instances replenish their signing pool while holding its
+// lock, so a key leaving the pool is replaced once rather than by every instance.
+type SharedStore interface {
+	Store
+	// WithLock runs fn while holding a lock shared by every instance using the store.
+	WithLock(fn func() error) error
+}
+
+// MemoryStore is a Store that only lives as long as the process. This is the default Store of a KeySet.
+type MemoryStore struct {
+	keys map[string]StoredKey
+	mu   sync.Mutex
+}
+
+// NewMemoryStore creates an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		keys: make(map[string]StoredKey),
+	}
+}
+
+func (m *MemoryStore) Load() ([]StoredKey, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var keys []StoredKey
+	for _, key := range m.keys {
+		keys = append(keys, key)
+	}
+
+	return keys, nil
+}
+
+func (m *MemoryStore) Save(key StoredKey) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.keys[key.Key.KeyID()] = key
+	return nil
+}
+
+func (m *MemoryStore) Delete(kid string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.keys, kid)
+	return nil
+}
+
+// FileStore is a Store backed by a single file on the local disk, every key is sealed before it is written.
+// It is intended for single instance deployments, instances sharing a set should use a shared Store instead.
+type FileStore struct {
+	path   string
+	sealer Sealer
+	mu     sync.Mutex
+}
+
+// fileStoreKey is the persisted form of a StoredKey within a FileStore.
+type fileStoreKey struct {
+	Kid         string    `json:"kid"`
+	Key         []byte    `json:"key"`
+	ActiveUntil time.Time `json:"active_until"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+// NewFileStore creates a FileStore at the given path, the file is created on the first Save.
+func NewFileStore(path string, sealer Sealer) *FileStore {
+	return &FileStore{
+		path:   path,
+		sealer: sealer,
+	}
+}
+
+func (f *FileStore) Load() ([]StoredKey, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.read()
+}
+
+func (f *FileStore) Save(key StoredKey) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	keys, err := f.read()
+	if err != nil {
+		return err
+	}
+
+	return f.write(append(removeKid(keys, key.Key.KeyID()), key))
+}
+
+func (f *FileStore) Delete(kid string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	keys, err := f.read()
+	if err != nil {
+		return err
+	}
+
+	return f.write(removeKid(keys, kid))
+}
+
+// read decodes the file, a missing file is treated as an empty store.
+func (f *FileStore) read() ([]StoredKey, error) {
+	bs, err := os.ReadFile(f.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key file, %w", err)
+	}
+
+	var rows []fileStoreKey
+	if err := json.Unmarshal(bs, &rows); err != nil {
+		return nil, fmt.Errorf("failed to decode key file, %w", err)
+	}
+
+	var keys []StoredKey
+	for _, row := range rows {
+		key, err := OpenKey(f.sealer, row.Key)
+		if err != nil {
+			return nil, fmt.Errorf("kid %s, %w", row.Kid, err)
+		}
+
+		keys = append(keys, StoredKey{
+			Key:         key,
+			ActiveUntil: row.ActiveUntil,
+			ExpiresAt:   row.ExpiresAt,
+		})
+	}
+
+	return keys, nil
+}
+
+// write replaces the file atomically so a crash mid-write never leaves a truncated set behind.
+func (f *FileStore) write(keys []StoredKey) error {
+	var rows []fileStoreKey
+	for _, key := range keys {
+		sealed, err := SealKey(f.sealer, key.Key)
+		if err != nil {
+			return fmt.Errorf("failed to seal key %s, %w", key.Key.KeyID(), err)
+		}
+
+		rows = append(rows, fileStoreKey{
+			Kid:         key.Key.KeyID(),
+			Key:         sealed,
+			ActiveUntil: key.ActiveUntil,
+			ExpiresAt:   key.ExpiresAt,
+		})
+	}
+
+	bs, err := json.Marshal(rows)
+	if err != nil {
+		return fmt.Errorf("failed to encode key file, %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(f.path), filepath.Base(f.path)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to create key file, %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(bs); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("failed to write key file, %w", err)
+	}
+
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write key file, %w", err)
+	}
+
+	return os.Rename(tmp.Name(), f.path)
+}
+
+func removeKid(keys []StoredKey, kid string) []StoredKey {
+	var kept []StoredKey
+	for _, key := range keys {
+		if key.Key.KeyID() != kid {
+			kept = append(kept, key)
+		}
+	}
+
+	return kept
+}
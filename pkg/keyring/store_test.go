@@ -0,0 +1,74 @@
+package keyring
+
+import (
+	"crypto/rand"
+	"github.com/hashicorp/go-hclog"
+	"github.com/lestrrat-go/jwx/v2/jwk"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func testEnvelope(t *testing.T) *Envelope {
+	kek := make([]byte, 32)
+	_, _ = rand.Read(kek)
+
+	envelope, err := NewEnvelope(kek)
+	if err != nil {
+		t.Fatalf("NewEnvelope() error = %v", err)
+	}
+
+	return envelope
+}
+
+func TestFileStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys.json")
+	envelope := testEnvelope(t)
+
+	keyset, _ := NewSet(1000, 500, hclog.Default())
+	keyset.SetCurveTypes(P384)
+	keyset.SetStore(NewFileStore(path, envelope))
+	if err := keyset.Load(3); err != nil {
+		t.Errorf("Load() error = %v", err)
+		return
+	}
+
+	restored, _ := NewSet(1000, 500, hclog.Default())
+	restored.SetCurveTypes(P384)
+	restored.SetStore(NewFileStore(path, envelope))
+	if err := restored.Load(3); err != nil {
+		t.Errorf("Load() error = %v", err)
+		return
+	}
+
+	for _, key := range keyset.privateKeys() {
+		got, ok := restored.GetKeyById(key.KeyID())
+		if !assert.True(t, ok, "key should be restored from file") {
+			continue
+		}
+
+		_, isPrivate := got.(jwk.ECDSAPrivateKey)
+		assert.True(t, isPrivate, "restored key should contain private key information")
+		assert.Equal(t, key.Algorithm(), got.Algorithm())
+	}
+
+	kid := keyset.GetRandomKey().KeyID()
+	keyset.RevokeKeyById(kid)
+
+	stored, err := NewFileStore(path, envelope).Load()
+	assert.NoError(t, err)
+	assert.Len(t, stored, 3, "revoked key should be replaced")
+	for _, key := range stored {
+		assert.NotEqual(t, kid, key.Key.KeyID(), "revoked key should be removed from file")
+	}
+
+	bs, _ := os.ReadFile(path)
+	assert.NotContains(t, string(bs), `"d":`, "private key material should not be stored in plaintext")
+}
+
+func TestFileStore_Missing(t *testing.T) {
+	keys, err := NewFileStore(filepath.Join(t.TempDir(), "missing.json"), testEnvelope(t)).Load()
+	assert.NoError(t, err)
+	assert.Empty(t, keys)
+}
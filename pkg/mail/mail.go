@@ -0,0 +1,95 @@
+package mail
+
+import (
+	"fmt"
+	"github.com/hashicorp/go-hclog"
+	"mime"
+	"net"
+	"net/smtp"
+	"os"
+	"strings"
+	"time"
+)
+
+// Message is a plain text email.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Bytes encodes the Message as an RFC 5322 message from the given address.
+func (m Message) Bytes(from string) []byte {
+	var b strings.Builder
+	b.WriteString("From: " + from + "\r\n")
+	b.WriteString("To: " + m.To + "\r\n")
+	b.WriteString("Subject: " + mime.QEncoding.Encode("utf-8", m.Subject) + "\r\n")
+	b.WriteString("Date: " + time.Now().Format(time.RFC1123Z) + "\r\n")
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(m.Body, "\n", "\r\n"))
+
+	return []byte(b.String())
+}
+
+// Sender delivers a Message.
+type Sender interface {
+	Send(message Message) error
+}
+
+// LogSender writes messages to the log instead of delivering them, for local development.
+type LogSender struct {
+	hclog.Logger
+}
+
+func (s LogSender) Send(message Message) error {
+	s.Info("mail", "to", message.To, "subject", message.Subject, "body", message.Body)
+	return nil
+}
+
+// SMTPSender delivers messages through an SMTP relay, authenticating with PLAIN auth when a username is set.
+type SMTPSender struct {
+	Addr string
+	From string
+	Auth smtp.Auth
+}
+
+// NewSMTPSender creates an SMTPSender for the relay at host:port.
+func NewSMTPSender(host, port, username, password, from string) *SMTPSender {
+	sender := &SMTPSender{
+		Addr: net.JoinHostPort(host, port),
+		From: from,
+	}
+
+	if username != "" {
+		sender.Auth = smtp.PlainAuth("", username, password, host)
+	}
+
+	return sender
+}
+
+func (s *SMTPSender) Send(message Message) error {
+	if strings.ContainsAny(message.To, "\r\n") {
+		return fmt.Errorf("invalid recipient %q", message.To)
+	}
+
+	return smtp.SendMail(s.Addr, s.Auth, s.From, []string{message.To}, message.Bytes(s.From))
+}
+
+// NewSenderFromEnv returns the Sender selected by MAIL_SENDER. The smtp sender is configured by SMTP_HOST,
+// SMTP_PORT, SMTP_USERNAME, SMTP_PASSWORD and MAIL_FROM. Defaults to logging messages.
+func NewSenderFromEnv(l hclog.Logger) Sender {
+	switch os.Getenv("MAIL_SENDER") {
+	case "smtp":
+		port := os.Getenv("SMTP_PORT")
+		if port == "" {
+			port = "587"
+		}
+
+		return NewSMTPSender(os.Getenv("SMTP_HOST"), port, os.Getenv("SMTP_USERNAME"), os.Getenv("SMTP_PASSWORD"), os.Getenv("MAIL_FROM"))
+	default:
+		l.Warn("using log mail sender, emails will not be delivered")
+		return LogSender{Logger: l}
+	}
+}
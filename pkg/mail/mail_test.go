@@ -0,0 +1,45 @@
+package mail
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestMessage_Bytes(t *testing.T) {
+	tests := []struct {
+		name     string
+		message  Message
+		contains []string
+	}{
+		{
+			name:    "should write headers and body",
+			message: Message{To: "user@knockbox.io", Subject: "Verify your email", Body: "line one\nline two"},
+			contains: []string{
+				"From: no-reply@knockbox.io\r\n",
+				"To: user@knockbox.io\r\n",
+				"Subject: Verify your email\r\n",
+				"Content-Type: text/plain; charset=utf-8\r\n",
+				"\r\n\r\nline one\r\nline two",
+			},
+		},
+		{
+			name:     "should encode non-ascii subjects",
+			message:  Message{To: "user@knockbox.io", Subject: "Vérifier"},
+			contains: []string{"Subject: =?utf-8?q?V=C3=A9rifier?=\r\n"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := string(tt.message.Bytes("no-reply@knockbox.io"))
+			for _, want := range tt.contains {
+				assert.True(t, strings.Contains(got, want), "missing %q in %q", want, got)
+			}
+		})
+	}
+}
+
+func TestSMTPSender_Send(t *testing.T) {
+	err := NewSMTPSender("localhost", "25", "", "", "no-reply@knockbox.io").Send(Message{To: "user@knockbox.io\r\nBcc: x@y.z"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,58 @@
+package middleware
+
+import (
+	"github.com/hashicorp/go-hclog"
+	"net/http"
+)
+
+// ClientAuthenticator verifies the credentials of a service client.
+type ClientAuthenticator interface {
+	AuthenticateClient(clientId, secret string) (bool, error)
+}
+
+// BasicAuth is a middleware handler that requires a service client to authenticate with HTTP Basic.
+type BasicAuth struct {
+	l     hclog.Logger
+	auth  ClientAuthenticator
+	realm string
+}
+
+// Middleware rejects with 401 if the client credentials are missing or invalid.
+func (b *BasicAuth) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		clientId, secret, ok := r.BasicAuth()
+		if !ok {
+			b.unauthorized(w)
+			return
+		}
+
+		authenticated, err := b.auth.AuthenticateClient(clientId, secret)
+		if err != nil {
+			b.l.Error("failed to authenticate client", "client_id", clientId, "err", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		if !authenticated {
+			b.l.Info("client authentication failed", "client_id", clientId)
+			b.unauthorized(w)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (b *BasicAuth) unauthorized(w http.ResponseWriter) {
+	w.Header().Set("WWW-Authenticate", `Basic realm="`+b.realm+`"`)
+	w.WriteHeader(http.StatusUnauthorized)
+}
+
+// UseBasicAuth constructs a new BasicAuth middleware handler
+func UseBasicAuth(l hclog.Logger, auth ClientAuthenticator, realm string) *BasicAuth {
+	return &BasicAuth{
+		l:     l,
+		auth:  auth,
+		realm: realm,
+	}
+}
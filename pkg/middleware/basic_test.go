@@ -0,0 +1,73 @@
+package middleware
+
+import (
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// testClients is a ClientAuthenticator of client_id to secret pairs.
+type testClients map[string]string
+
+func (c testClients) AuthenticateClient(clientId, secret string) (bool, error) {
+	expected, ok := c[clientId]
+	return ok && expected == secret, nil
+}
+
+func TestBasicAuth_Middleware(t *testing.T) {
+	tests := []struct {
+		name     string
+		clientId string
+		secret   string
+		want     int
+	}{
+		{
+			name:     "Should accept known client",
+			clientId: "service-b",
+			secret:   "secret-b",
+			want:     http.StatusOK,
+		},
+		{
+			name:     "Should reject wrong secret",
+			clientId: "service-a",
+			secret:   "secret-b",
+			want:     http.StatusUnauthorized,
+		},
+		{
+			name:     "Should reject unknown client",
+			clientId: "service-c",
+			secret:   "secret-c",
+			want:     http.StatusUnauthorized,
+		},
+		{
+			name: "Should reject missing credentials",
+			want: http.StatusUnauthorized,
+		},
+	}
+
+	basic := UseBasicAuth(hclog.Default(), testClients{"service-a": "secret-a", "service-b": "secret-b"}, "test")
+	handler := basic.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodPost, "/", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if tt.clientId != "" {
+				req.SetBasicAuth(tt.clientId, tt.secret)
+			}
+
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			got := rr.Code
+			assert.Truef(t, got == tt.want, "got status %v, wanted %v", got, tt.want)
+		})
+	}
+}
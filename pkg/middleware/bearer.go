@@ -2,74 +2,214 @@ package middleware
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"github.com/hashicorp/go-hclog"
-	"github.com/knockbox/authentication/pkg/enums"
+	"github.com/knockbox/authentication/pkg/keyring"
+	"github.com/knockbox/authentication/pkg/models"
+	"github.com/knockbox/authentication/pkg/utils"
 	"github.com/lestrrat-go/jwx/v2/jwk"
+	"github.com/lestrrat-go/jwx/v2/jws"
 	"github.com/lestrrat-go/jwx/v2/jwt"
 	"net/http"
 	"os"
+	"sync"
+	"time"
 )
 
-var BearerTokenContextKey = "bearer-token"
+// jwksRefreshInterval is the minimum time between refreshes of the jwk.Cache caused by an unknown kid.
+const jwksRefreshInterval = 30 * time.Second
+
+// Denylist reports if an otherwise valid token has been revoked.
+type Denylist interface {
+	IsRevoked(token jwt.Token) (bool, error)
+}
+
+// KeyLookup finds a verification key by its kid, as keyring.KeySet does.
+type KeyLookup interface {
+	GetKeyById(kid string) (jwk.Key, bool)
+}
 
 type BearerToken struct {
-	l   hclog.Logger
-	set jwk.Set
+	l        hclog.Logger
+	keys     KeyLookup
+	set      jwk.Set
+	cache    *jwk.Cache
+	url      string
+	config   *utils.TokenConfig
+	denylist Denylist
+
+	mu          sync.Mutex
+	lastRefresh time.Time
 }
 
-// Middleware is the default handler that rejects with 401 if the token is missing or unverified.
+// Middleware is the default handler that rejects with 401 if the token is missing or unverified. The Principal of
+// the token is put on the request context.
 func (b *BearerToken) Middleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		token, err := jwt.ParseHeader(r.Header, "Authorization", jwt.WithKeySet(b.set))
+		token, err := b.parse(r)
 		if err != nil {
 			b.l.Info("bearer token middleware (required)", "err", err)
 			w.WriteHeader(http.StatusUnauthorized)
 			return
 		}
 
-		claims := token.PrivateClaims()
-		rawRole, ok := claims["role"].(string)
-		if !ok {
-			b.l.Warn("failed to extract role from claims")
+		p, err := models.NewPrincipal(token)
+		if err != nil {
+			b.l.Warn("failed to extract principal from claims", "err", err)
 			w.WriteHeader(http.StatusBadRequest)
 			return
 		}
 
-		if enums.UserRoleFromString(rawRole).IsForbidden() {
+		if !p.IsClient() && p.Role.IsForbidden() {
 			b.l.Debug("missing required role to access endpoint")
 			w.WriteHeader(http.StatusForbidden)
 			return
 		}
 
-		ctx := context.WithValue(r.Context(), BearerTokenContextKey, &token)
-		next.ServeHTTP(w, r.WithContext(ctx))
+		next.ServeHTTP(w, withPrincipal(r, p))
 	})
 }
 
 // OptionalMiddleware is the non-default handler that allows non-verified or missing tokens. It however
-// will only put the token if it is present.
+// will only put the Principal if the token is present and verified.
 func (b *BearerToken) OptionalMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		token, err := jwt.ParseHeader(r.Header, "Authorization", jwt.WithKeySet(b.set))
+		token, err := b.parse(r)
 		if err != nil {
-			ctx := context.WithValue(r.Context(), BearerTokenContextKey, nil)
-			next.ServeHTTP(w, r.WithContext(ctx))
+			next.ServeHTTP(w, r)
 			return
 		}
 
-		ctx := context.WithValue(r.Context(), BearerTokenContextKey, &token)
-		next.ServeHTTP(w, r.WithContext(ctx))
+		p, err := models.NewPrincipal(token)
+		if err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		next.ServeHTTP(w, withPrincipal(r, p))
 	})
 }
 
-func UseBearerToken(l hclog.Logger) *BearerToken {
+// parse verifies the token in the Authorization header against the key named by its kid, validates the
+// registered claims against the utils.TokenConfig and rejects tokens on the Denylist.
+func (b *BearerToken) parse(r *http.Request) (jwt.Token, error) {
+	token, err := jwt.ParseHeader(
+		r.Header,
+		"Authorization",
+		jwt.WithKeyProvider(jws.KeyProviderFunc(b.fetchKey)),
+		jwt.WithIssuer(b.config.Issuer),
+		jwt.WithAcceptableSkew(b.config.ClockSkew),
+		jwt.WithRequiredClaim(jwt.SubjectKey),
+		jwt.WithRequiredClaim(jwt.JwtIDKey),
+		jwt.WithValidator(jwt.ValidatorFunc(b.validateAudience)),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	revoked, err := b.denylist.IsRevoked(token)
+	if err != nil {
+		b.l.Error("failed to check denylist", "err", err)
+		return nil, err
+	}
+
+	if revoked {
+		return nil, errors.New("token has been revoked")
+	}
+
+	return token, nil
+}
+
+// validateAudience accepts tokens intended for any of the configured audiences.
+func (b *BearerToken) validateAudience(_ context.Context, token jwt.Token) jwt.ValidationError {
+	if !b.config.HasAudience(token.Audience()) {
+		return jwt.ErrInvalidAudience()
+	}
+
+	return nil
+}
+
+// fetchKey provides exactly the key named by the kid of the protected header. Tokens without a kid, with an
+// unknown kid, with an alg that does not match the key or that are not access tokens are rejected.
+func (b *BearerToken) fetchKey(ctx context.Context, sink jws.KeySink, sig *jws.Signature, _ *jws.Message) error {
+	headers := sig.ProtectedHeaders()
+	if headers.Type() != keyring.TypeAccessToken {
+		return fmt.Errorf("unexpected token typ %q", headers.Type())
+	}
+
+	kid := headers.KeyID()
+	if kid == "" {
+		return errors.New("token is missing kid")
+	}
+
+	key, ok := b.lookup(ctx, kid)
+	if !ok {
+		return fmt.Errorf("unknown kid %q", kid)
+	}
+
+	if key.Algorithm().String() != headers.Algorithm().String() {
+		return fmt.Errorf("alg %q does not match key %q", headers.Algorithm(), kid)
+	}
+
+	sink.Key(headers.Algorithm(), key)
+	return nil
+}
+
+// lookup finds the key by kid, in the local KeyLookup when there is one. Otherwise a miss refreshes the cached
+// set, at most once per jwksRefreshInterval, as the kid may belong to a key generated since the last refresh.
+func (b *BearerToken) lookup(ctx context.Context, kid string) (jwk.Key, bool) {
+	if b.keys != nil {
+		return b.keys.GetKeyById(kid)
+	}
+
+	if key, ok := b.set.LookupKeyID(kid); ok {
+		return key, true
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if time.Since(b.lastRefresh) < jwksRefreshInterval {
+		return nil, false
+	}
+	b.lastRefresh = time.Now()
+
+	set, err := b.cache.Refresh(ctx, b.url)
+	if err != nil {
+		b.l.Warn("failed to refresh jwks", "err", err)
+		return nil, false
+	}
+
+	return set.LookupKeyID(kid)
+}
+
+// UseLocalBearerToken verifies tokens against the keys of the issuer itself rather than fetching JWKS_URL, so a
+// revoked key is refused at once instead of once the cached set refreshes.
+func UseLocalBearerToken(l hclog.Logger, keys KeyLookup, denylist Denylist) *BearerToken {
+	return &BearerToken{
+		l:        l,
+		keys:     keys,
+		config:   utils.TokenConfigFromEnv(),
+		denylist: denylist,
+	}
+}
+
+// UseBearerToken verifies tokens against the keys published at JWKS_URL, for services other than the issuer.
+func UseBearerToken(l hclog.Logger, denylist Denylist) *BearerToken {
+	url := os.Getenv("JWKS_URL")
+
 	c := jwk.NewCache(context.Background())
-	if err := c.Register(os.Getenv("JWKS_URL")); err != nil {
+	if err := c.Register(url); err != nil {
 		panic(err)
 	}
 
 	return &BearerToken{
-		l:   l,
-		set: jwk.NewCachedSet(c, os.Getenv("JWKS_URL")),
+		l:        l,
+		set:      jwk.NewCachedSet(c, url),
+		cache:    c,
+		url:      url,
+		config:   utils.TokenConfigFromEnv(),
+		denylist: denylist,
 	}
 }
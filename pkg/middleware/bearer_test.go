@@ -0,0 +1,239 @@
+package middleware
+
+import (
+	"encoding/json"
+	"github.com/google/uuid"
+	"github.com/hashicorp/go-hclog"
+	"github.com/knockbox/authentication/pkg/keyring"
+	"github.com/lestrrat-go/jwx/v2/jws"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type testDenylist map[string]bool
+
+func (d testDenylist) IsRevoked(token jwt.Token) (bool, error) {
+	return d[token.JwtID()], nil
+}
+
+func TestBearerToken_Middleware(t *testing.T) {
+	keyset, _ := keyring.NewSet(1000, 500, hclog.Default())
+	keyset.SetKeyTypes(keyring.CurveType(keyring.P256), keyring.Ed25519)
+	if err := keyset.Load(2); err != nil {
+		t.Fatal(err)
+	}
+
+	jwks := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(keyset.GetPublicKeySet())
+	}))
+	defer jwks.Close()
+	t.Setenv("JWKS_URL", jwks.URL)
+
+	t.Setenv("TOKEN_ISSUER", "https://auth.knockbox.io")
+	t.Setenv("TOKEN_AUDIENCE", "knockbox")
+	t.Setenv("TOKEN_CLOCK_SKEW", "5s")
+
+	newToken := func(iss, aud string, nbf time.Time) jwt.Token {
+		token, _ := jwt.NewBuilder().
+			Issuer(iss).
+			Audience([]string{aud}).
+			Subject("account").
+			JwtID(uuid.NewString()).
+			IssuedAt(time.Now()).
+			NotBefore(nbf).
+			Expiration(time.Now().Add(time.Minute)).
+			Claim("role", "user").
+			Build()
+
+		return token
+	}
+
+	token := newToken("https://auth.knockbox.io", "knockbox", time.Now())
+	signed, err := keyset.Sign(token, keyring.TypeAccessToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	revokedToken := newToken("https://auth.knockbox.io", "knockbox", time.Now())
+	revoked, _ := keyset.Sign(revokedToken, keyring.TypeAccessToken)
+
+	wrongIssuer, _ := keyset.Sign(newToken("https://evil.io", "knockbox", time.Now()), keyring.TypeAccessToken)
+	wrongAudience, _ := keyset.Sign(newToken("https://auth.knockbox.io", "other", time.Now()), keyring.TypeAccessToken)
+	withinSkew, _ := keyset.Sign(newToken("https://auth.knockbox.io", "knockbox", time.Now().Add(3*time.Second)), keyring.TypeAccessToken)
+	notYetValid, _ := keyset.Sign(newToken("https://auth.knockbox.io", "knockbox", time.Now().Add(time.Minute)), keyring.TypeAccessToken)
+
+	headers := jws.NewHeaders()
+	_ = headers.Set(jws.TypeKey, keyring.TypeAccessToken)
+
+	var rawKey interface{}
+	key := keyset.GetRandomKey()
+	_ = key.Raw(&rawKey)
+	withoutKid, _ := jwt.Sign(token, jwt.WithKey(key.Algorithm(), rawKey, jws.WithProtectedHeaders(headers)))
+
+	other, _ := keyring.NewSet(1000, 500, hclog.Default())
+	other.SetKeyTypes(keyring.CurveType(keyring.P256))
+	_ = other.Load(1)
+	unknownKid, _ := other.Sign(token, keyring.TypeAccessToken)
+
+	wrongTyp, _ := keyset.Sign(token, "JWT")
+
+	newClientToken := func(sub string) []byte {
+		token, _ := jwt.NewBuilder().
+			Issuer("https://auth.knockbox.io").
+			Audience([]string{"knockbox"}).
+			Subject(sub).
+			JwtID(uuid.NewString()).
+			IssuedAt(time.Now()).
+			NotBefore(time.Now()).
+			Expiration(time.Now().Add(time.Minute)).
+			Claim("client_id", "service").
+			Build()
+
+		signed, _ := keyset.Sign(token, keyring.TypeAccessToken)
+		return signed
+	}
+
+	clientToken := newClientToken("service")
+	clientTokenForAccount := newClientToken("account")
+
+	tests := []struct {
+		name  string
+		token []byte
+		want  int
+	}{
+		{
+			name:  "Should accept token with kid and typ",
+			token: signed,
+			want:  http.StatusOK,
+		},
+		{
+			name:  "Should reject token without kid",
+			token: withoutKid,
+			want:  http.StatusUnauthorized,
+		},
+		{
+			name:  "Should reject token with unknown kid",
+			token: unknownKid,
+			want:  http.StatusUnauthorized,
+		},
+		{
+			name:  "Should reject token that is not an access token",
+			token: wrongTyp,
+			want:  http.StatusUnauthorized,
+		},
+		{
+			name:  "Should reject revoked token",
+			token: revoked,
+			want:  http.StatusUnauthorized,
+		},
+		{
+			name:  "Should reject token from another issuer",
+			token: wrongIssuer,
+			want:  http.StatusUnauthorized,
+		},
+		{
+			name:  "Should reject token for another audience",
+			token: wrongAudience,
+			want:  http.StatusUnauthorized,
+		},
+		{
+			name:  "Should accept token not yet valid within clock skew",
+			token: withinSkew,
+			want:  http.StatusOK,
+		},
+		{
+			name:  "Should reject token not yet valid",
+			token: notYetValid,
+			want:  http.StatusUnauthorized,
+		},
+		{
+			name:  "Should accept client token without role",
+			token: clientToken,
+			want:  http.StatusOK,
+		},
+		{
+			name:  "Should reject user token without role",
+			token: clientTokenForAccount,
+			want:  http.StatusBadRequest,
+		},
+	}
+
+	bearer := UseBearerToken(hclog.Default(), testDenylist{revokedToken.JwtID(): true})
+	handler := bearer.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, "/", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Authorization", "Bearer "+string(tt.token))
+
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			got := rr.Code
+			assert.Truef(t, got == tt.want, "got status %v, wanted %v", got, tt.want)
+		})
+	}
+}
+
+func TestBearerToken_MiddlewareWithLocalKeys(t *testing.T) {
+	keyset, _ := keyring.NewSet(1000, 500, hclog.Default())
+	keyset.SetCurveTypes(keyring.P256)
+	if err := keyset.Load(2); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("TOKEN_ISSUER", "https://auth.knockbox.io")
+	t.Setenv("TOKEN_AUDIENCE", "knockbox")
+
+	token, _ := jwt.NewBuilder().
+		Issuer("https://auth.knockbox.io").
+		Audience([]string{"knockbox"}).
+		Subject("account").
+		JwtID(uuid.NewString()).
+		IssuedAt(time.Now()).
+		Expiration(time.Now().Add(time.Minute)).
+		Claim("role", "user").
+		Build()
+
+	signed, err := keyset.Sign(token, keyring.TypeAccessToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bearer := UseLocalBearerToken(hclog.Default(), keyset, testDenylist{})
+	handler := bearer.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	serve := func() int {
+		req, err := http.NewRequest(http.MethodGet, "/", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+string(signed))
+
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	assert.Equal(t, http.StatusOK, serve())
+
+	msg, err := jws.Parse(signed)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.True(t, keyset.RevokeKeyById(msg.Signatures()[0].ProtectedHeaders().KeyID()))
+	assert.Equal(t, http.StatusUnauthorized, serve(), "a token signed by a revoked key should be refused at once")
+}
@@ -0,0 +1,39 @@
+package middleware
+
+import (
+	"github.com/hashicorp/go-hclog"
+	"net/http"
+)
+
+// MFAGuard is a middleware handler that requires the user of the bearer token to have given a second factor. It
+// must be used after BearerToken.Middleware.
+type MFAGuard struct {
+	l hclog.Logger
+}
+
+// Middleware rejects with 401 if there is no bearer token, or if the token lacks the "mfa" amr, with the
+// insufficient_user_authentication challenge of RFC 9470 so the client can sign the user in again.
+func (m *MFAGuard) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p, ok := GetPrincipal(r)
+		if !ok {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		if !p.HasAuthenticatedWith("mfa") {
+			m.l.Debug("missing second factor to access endpoint", "sub", p.Subject)
+
+			w.Header().Set("WWW-Authenticate", `Bearer error="insufficient_user_authentication", acr_values="mfa"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// RequireMFA constructs a new MFAGuard middleware handler.
+func RequireMFA(l hclog.Logger) *MFAGuard {
+	return &MFAGuard{l: l}
+}
@@ -0,0 +1,83 @@
+package middleware
+
+import (
+	"encoding/json"
+	"github.com/hashicorp/go-hclog"
+	"github.com/knockbox/authentication/pkg/models"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMFAGuard_Middleware(t *testing.T) {
+	newPrincipal := func(amr ...string) *models.Principal {
+		builder := jwt.NewBuilder().Subject("account").Claim("role", "user")
+		if amr != nil {
+			builder = builder.Claim("amr", amr)
+		}
+
+		token, _ := builder.Build()
+
+		// Round trip through JSON as a verified token would be.
+		bs, _ := json.Marshal(token)
+		parsed, _ := jwt.Parse(bs, jwt.WithVerify(false))
+
+		p, _ := models.NewPrincipal(parsed)
+		return p
+	}
+
+	tests := []struct {
+		name      string
+		principal *models.Principal
+		want      int
+		challenge string
+	}{
+		{
+			name:      "Should accept token with a second factor",
+			principal: newPrincipal("pwd", "otp", "mfa"),
+			want:      http.StatusOK,
+		},
+		{
+			name:      "Should reject token with only a password",
+			principal: newPrincipal("pwd"),
+			want:      http.StatusUnauthorized,
+			challenge: `Bearer error="insufficient_user_authentication", acr_values="mfa"`,
+		},
+		{
+			name:      "Should reject token without amr",
+			principal: newPrincipal(),
+			want:      http.StatusUnauthorized,
+			challenge: `Bearer error="insufficient_user_authentication", acr_values="mfa"`,
+		},
+		{
+			name:      "Should reject missing token",
+			principal: nil,
+			want:      http.StatusUnauthorized,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, "/", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if tt.principal != nil {
+				req = withPrincipal(req, tt.principal)
+			}
+
+			handler := RequireMFA(hclog.Default()).Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}))
+
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.want, rr.Code)
+			assert.Equal(t, tt.challenge, rr.Header().Get("WWW-Authenticate"))
+		})
+	}
+}
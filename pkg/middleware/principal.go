@@ -0,0 +1,19 @@
+package middleware
+
+import (
+	"context"
+	"github.com/knockbox/authentication/pkg/models"
+	"net/http"
+)
+
+var PrincipalContextKey = "principal"
+
+// GetPrincipal returns the models.Principal put on the request context by BearerToken.
+func GetPrincipal(r *http.Request) (*models.Principal, bool) {
+	p, ok := r.Context().Value(PrincipalContextKey).(*models.Principal)
+	return p, ok && p != nil
+}
+
+func withPrincipal(r *http.Request, p *models.Principal) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), PrincipalContextKey, p))
+}
@@ -0,0 +1,26 @@
+package middleware
+
+import (
+	"github.com/knockbox/authentication/pkg/utils"
+	"net/http"
+)
+
+// Proxy is a middleware handler giving requests forwarded by a trusted proxy the remote address of the client,
+// so the login throttle and user history tell clients behind the load balancer apart.
+type Proxy struct {
+	config *utils.ProxyConfig
+}
+
+func (p *Proxy) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.RemoteAddr = p.config.ClientIpAddress(r)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// UseProxy constructs a new Proxy middleware handler
+func UseProxy(config *utils.ProxyConfig) *Proxy {
+	return &Proxy{
+		config,
+	}
+}
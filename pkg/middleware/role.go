@@ -0,0 +1,41 @@
+package middleware
+
+import (
+	"github.com/hashicorp/go-hclog"
+	"github.com/knockbox/authentication/pkg/enums"
+	"net/http"
+)
+
+// RoleGuard is a middleware handler that requires the Principal to be a user with at least its role in the
+// enums.UserRole hierarchy. It must be used after BearerToken.Middleware.
+type RoleGuard struct {
+	l   hclog.Logger
+	min enums.UserRole
+}
+
+// Middleware rejects with 401 if there is no Principal, or 403 if it is a client or a user below the role.
+func (g *RoleGuard) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p, ok := GetPrincipal(r)
+		if !ok {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		if !p.HasRole(g.min) {
+			g.l.Debug("missing required role to access endpoint", "role", p.Role, "required", g.min)
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// RequireRole constructs a new RoleGuard middleware handler requiring at least the role.
+func RequireRole(l hclog.Logger, min enums.UserRole) *RoleGuard {
+	return &RoleGuard{
+		l:   l,
+		min: min,
+	}
+}
@@ -0,0 +1,95 @@
+package middleware
+
+import (
+	"github.com/hashicorp/go-hclog"
+	"github.com/knockbox/authentication/pkg/enums"
+	"github.com/knockbox/authentication/pkg/models"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRoleGuard_Middleware(t *testing.T) {
+	newPrincipal := func(sub string, claims map[string]interface{}) *models.Principal {
+		builder := jwt.NewBuilder().Subject(sub)
+		for k, v := range claims {
+			builder = builder.Claim(k, v)
+		}
+
+		token, _ := builder.Build()
+		p, err := models.NewPrincipal(token)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return p
+	}
+
+	tests := []struct {
+		name      string
+		principal *models.Principal
+		min       enums.UserRole
+		want      int
+	}{
+		{
+			name:      "Should accept user with the role",
+			principal: newPrincipal("account", map[string]interface{}{"role": "moderator"}),
+			min:       enums.Moderator,
+			want:      http.StatusOK,
+		},
+		{
+			name:      "Should accept user above the role",
+			principal: newPrincipal("account", map[string]interface{}{"role": "developer"}),
+			min:       enums.Admin,
+			want:      http.StatusOK,
+		},
+		{
+			name:      "Should reject user below the role",
+			principal: newPrincipal("account", map[string]interface{}{"role": "user"}),
+			min:       enums.Moderator,
+			want:      http.StatusForbidden,
+		},
+		{
+			name:      "Should reject user with an unknown role",
+			principal: newPrincipal("account", map[string]interface{}{"role": "superuser"}),
+			min:       enums.User,
+			want:      http.StatusForbidden,
+		},
+		{
+			name:      "Should reject client",
+			principal: newPrincipal("service", map[string]interface{}{"client_id": "service"}),
+			min:       enums.User,
+			want:      http.StatusForbidden,
+		},
+		{
+			name:      "Should reject missing principal",
+			principal: nil,
+			min:       enums.User,
+			want:      http.StatusUnauthorized,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, "/", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if tt.principal != nil {
+				req = withPrincipal(req, tt.principal)
+			}
+
+			handler := RequireRole(hclog.Default(), tt.min).Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}))
+
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.want, rr.Code)
+		})
+	}
+}
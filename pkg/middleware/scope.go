@@ -0,0 +1,47 @@
+package middleware
+
+import (
+	"github.com/hashicorp/go-hclog"
+	"github.com/knockbox/authentication/pkg/enums"
+	"net/http"
+)
+
+// ScopeGuard is a middleware handler that requires the bearer token to have been granted every one of its scopes.
+// It must be used after BearerToken.Middleware.
+type ScopeGuard struct {
+	l      hclog.Logger
+	scopes []enums.Scope
+}
+
+// Middleware rejects with 401 if there is no bearer token, or 403 with an insufficient_scope challenge as defined
+// by RFC 6750 if a scope is missing.
+func (s *ScopeGuard) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p, ok := GetPrincipal(r)
+		if !ok {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		for _, scope := range s.scopes {
+			if !p.HasScope(scope) {
+				s.l.Debug("missing required scope to access endpoint", "scope", scope)
+
+				required := enums.ScopesToString(s.scopes)
+				w.Header().Set("WWW-Authenticate", `Bearer error="insufficient_scope", scope="`+required+`"`)
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// RequireScope constructs a new ScopeGuard middleware handler requiring every one of the scopes.
+func RequireScope(l hclog.Logger, scopes ...enums.Scope) *ScopeGuard {
+	return &ScopeGuard{
+		l:      l,
+		scopes: scopes,
+	}
+}
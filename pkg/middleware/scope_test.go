@@ -0,0 +1,84 @@
+package middleware
+
+import (
+	"github.com/hashicorp/go-hclog"
+	"github.com/knockbox/authentication/pkg/enums"
+	"github.com/knockbox/authentication/pkg/models"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestScopeGuard_Middleware(t *testing.T) {
+	newPrincipal := func(scope string) *models.Principal {
+		token, _ := jwt.NewBuilder().Subject("account").Claim("role", "user").Claim("scope", scope).Build()
+		p, _ := models.NewPrincipal(token)
+		return p
+	}
+
+	tests := []struct {
+		name      string
+		principal *models.Principal
+		scopes    []enums.Scope
+		want      int
+		challenge string
+	}{
+		{
+			name:      "Should accept token with scope",
+			principal: newPrincipal("user:read user:write"),
+			scopes:    []enums.Scope{enums.ScopeUserWrite},
+			want:      http.StatusOK,
+		},
+		{
+			name:      "Should accept token with every scope",
+			principal: newPrincipal("admin:keys user:read"),
+			scopes:    []enums.Scope{enums.ScopeUserRead, enums.ScopeAdminKeys},
+			want:      http.StatusOK,
+		},
+		{
+			name:      "Should reject token missing a scope",
+			principal: newPrincipal("user:read"),
+			scopes:    []enums.Scope{enums.ScopeUserRead, enums.ScopeAdminKeys},
+			want:      http.StatusForbidden,
+			challenge: `Bearer error="insufficient_scope", scope="user:read admin:keys"`,
+		},
+		{
+			name:      "Should reject token with a scope prefix",
+			principal: newPrincipal("admin:keysx"),
+			scopes:    []enums.Scope{enums.ScopeAdminKeys},
+			want:      http.StatusForbidden,
+			challenge: `Bearer error="insufficient_scope", scope="admin:keys"`,
+		},
+		{
+			name:      "Should reject missing token",
+			principal: nil,
+			scopes:    []enums.Scope{enums.ScopeUserRead},
+			want:      http.StatusUnauthorized,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, "/", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if tt.principal != nil {
+				req = withPrincipal(req, tt.principal)
+			}
+
+			handler := RequireScope(hclog.Default(), tt.scopes...).Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}))
+
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.want, rr.Code)
+			assert.Equal(t, tt.challenge, rr.Header().Get("WWW-Authenticate"))
+		})
+	}
+}
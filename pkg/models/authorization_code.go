@@ -0,0 +1,53 @@
+package models
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"strings"
+	"time"
+)
+
+// AuthorizationCode defines an issued authorization code in our database. Only the hash of the code is stored
+// along with the S256 PKCE challenge it was requested with. Tokens exchanged for the code begin the FamilyId.
+// The Nonce, AuthTime and Amr (space separated) describe the authentication for the ID token.
+type AuthorizationCode struct {
+	Id            uint       `db:"id"`
+	CodeHash      string     `db:"code_hash"`
+	ClientId      string     `db:"client_id"`
+	UserId        uint       `db:"user_id"`
+	FamilyId      string     `db:"family_id"`
+	RedirectURI   string     `db:"redirect_uri"`
+	Scope         string     `db:"scope"`
+	CodeChallenge string     `db:"code_challenge"`
+	Nonce         string     `db:"nonce"`
+	AuthTime      time.Time  `db:"auth_time"`
+	Amr           string     `db:"amr"`
+	IssuedAt      time.Time  `db:"issued_at"`
+	ExpiresAt     time.Time  `db:"expires_at"`
+	UsedAt        *time.Time `db:"used_at"`
+}
+
+// IsExpired determines if the AuthorizationCode is past its expiry.
+func (c *AuthorizationCode) IsExpired() bool {
+	return !time.Now().Before(c.ExpiresAt)
+}
+
+// VerifyChallenge determines if the code_verifier hashes to the S256 code_challenge.
+func (c *AuthorizationCode) VerifyChallenge(verifier string) bool {
+	sum := sha256.Sum256([]byte(verifier))
+	challenge := base64.RawURLEncoding.EncodeToString(sum[:])
+
+	return subtle.ConstantTimeCompare([]byte(challenge), []byte(c.CodeChallenge)) == 1
+}
+
+// HasScope determines if the scope was requested along with the code.
+func (c *AuthorizationCode) HasScope(scope string) bool {
+	for _, requested := range strings.Fields(c.Scope) {
+		if requested == scope {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,43 @@
+package models
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestAuthorizationCode_VerifyChallenge(t *testing.T) {
+	code := &AuthorizationCode{CodeChallenge: "y0f12ONFOEtBo-QIUSTyH953cPYjDUipiSxpv4ujUdo"}
+
+	tests := []struct {
+		name     string
+		verifier string
+		want     bool
+	}{
+		{
+			name:     "matching verifier",
+			verifier: "dBjftJeZ4CVP-mJ0kwXttuiu7_ZHmX7F9RRC8E3Pi2Y",
+			want:     true,
+		},
+		{
+			name:     "different verifier",
+			verifier: "dBjftJeZ4CVP-mJ0kwXttuiu7_ZHmX7F9RRC8E3Pi2Z",
+			want:     false,
+		},
+		{
+			name:     "challenge as verifier",
+			verifier: "y0f12ONFOEtBo-QIUSTyH953cPYjDUipiSxpv4ujUdo",
+			want:     false,
+		},
+		{
+			name:     "empty verifier",
+			verifier: "",
+			want:     false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, code.VerifyChallenge(tt.verifier))
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package models
+
+import (
+	"github.com/google/uuid"
+	"github.com/knockbox/authentication/pkg/utils"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+	"time"
+)
+
+// CreateVerificationToken returns a jwt.Token proving the holder received mail at the current email of the User.
+// Its audience is the issuer itself so it is never accepted as an access token elsewhere.
+func (u *User) CreateVerificationToken(config *utils.TokenConfig, duration time.Duration) (jwt.Token, error) {
+	return u.verificationTokenBuilder(config, duration, u.Email).Build()
+}
+
+// CreateEmailChangeToken returns a jwt.Token proving the holder received mail at the new email, which replaces
+// the current email of the User once confirmed.
+func (u *User) CreateEmailChangeToken(config *utils.TokenConfig, duration time.Duration, email string) (jwt.Token, error) {
+	return u.verificationTokenBuilder(config, duration, email).
+		Claim("previous_email", u.Email).
+		Build()
+}
+
+func (u *User) verificationTokenBuilder(config *utils.TokenConfig, duration time.Duration, email string) *jwt.Builder {
+	now := time.Now()
+
+	return jwt.NewBuilder().
+		Issuer(config.Issuer).
+		Audience([]string{config.Issuer}).
+		Subject(u.AccountId.String()).
+		JwtID(uuid.NewString()).
+		IssuedAt(now).
+		Expiration(now.Add(duration)).
+		Claim("email", email)
+}
+
+// IsVerifiedBy determines if the verification token was issued to the User for their current email. A token sent
+// before the email changed no longer verifies it.
+func (u *User) IsVerifiedBy(token jwt.Token) bool {
+	claims := token.PrivateClaims()
+	if _, ok := claims["previous_email"]; ok {
+		return false
+	}
+
+	email, ok := claims["email"].(string)
+	return ok && token.Subject() == u.AccountId.String() && email == u.Email
+}
+
+// EmailChangedBy returns the new email confirmed by the email change token. It is only valid while the User still
+// has the email the change was requested from.
+func (u *User) EmailChangedBy(token jwt.Token) (string, bool) {
+	claims := token.PrivateClaims()
+	previous, ok := claims["previous_email"].(string)
+	if !ok || token.Subject() != u.AccountId.String() || previous != u.Email {
+		return "", false
+	}
+
+	email, ok := claims["email"].(string)
+	return email, ok && email != ""
+}
@@ -0,0 +1,103 @@
+package models
+
+import (
+	"github.com/google/uuid"
+	"github.com/knockbox/authentication/pkg/utils"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestUser_IsVerifiedBy(t *testing.T) {
+	config := &utils.TokenConfig{Issuer: "https://auth.knockbox.io"}
+	user := &User{AccountId: uuid.New(), Email: "user@knockbox.io"}
+
+	token, err := user.CreateVerificationToken(config, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	change, err := user.CreateEmailChangeToken(config, time.Hour, "user@knockbox.io")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, []string{config.Issuer}, token.Audience())
+	assert.False(t, user.IsVerifiedBy(change), "email change tokens should not verify")
+
+	tests := []struct {
+		name string
+		user *User
+		want bool
+	}{
+		{
+			name: "should verify the same user and email",
+			user: &User{AccountId: user.AccountId, Email: "user@knockbox.io"},
+			want: true,
+		},
+		{
+			name: "should not verify a changed email",
+			user: &User{AccountId: user.AccountId, Email: "other@knockbox.io"},
+			want: false,
+		},
+		{
+			name: "should not verify another user",
+			user: &User{AccountId: uuid.New(), Email: "user@knockbox.io"},
+			want: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.user.IsVerifiedBy(token))
+		})
+	}
+}
+
+func TestUser_EmailChangedBy(t *testing.T) {
+	config := &utils.TokenConfig{Issuer: "https://auth.knockbox.io"}
+	user := &User{AccountId: uuid.New(), Email: "user@knockbox.io"}
+
+	token, err := user.CreateEmailChangeToken(config, time.Hour, "new@knockbox.io")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	verification, err := user.CreateVerificationToken(config, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, ok := user.EmailChangedBy(verification)
+	assert.False(t, ok, "verification tokens should not change the email")
+
+	tests := []struct {
+		name string
+		user *User
+		want string
+		ok   bool
+	}{
+		{
+			name: "should change the email of the same user",
+			user: &User{AccountId: user.AccountId, Email: "user@knockbox.io"},
+			want: "new@knockbox.io",
+			ok:   true,
+		},
+		{
+			name: "should not change an email that already changed",
+			user: &User{AccountId: user.AccountId, Email: "other@knockbox.io"},
+			ok:   false,
+		},
+		{
+			name: "should not change the email of another user",
+			user: &User{AccountId: uuid.New(), Email: "user@knockbox.io"},
+			ok:   false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := tt.user.EmailChangedBy(token)
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
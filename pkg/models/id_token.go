@@ -0,0 +1,55 @@
+package models
+
+import (
+	"github.com/google/uuid"
+	"github.com/knockbox/authentication/pkg/utils"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+	"strings"
+	"time"
+)
+
+// CreateIDToken returns an OpenID Connect ID token for the User, who authenticated to the client of the
+// AuthorizationCode. The profile and email claims of the UserInfo are only included if their scope was requested.
+func (u *User) CreateIDToken(config *utils.TokenConfig, duration time.Duration, code *AuthorizationCode, details *UserDetails) (jwt.Token, error) {
+	now := time.Now()
+	info := u.UserInfo(details, code.HasScope("profile"), code.HasScope("email"))
+
+	builder := jwt.NewBuilder().
+		Issuer(config.Issuer).
+		Audience([]string{code.ClientId}).
+		Subject(info.Sub).
+		JwtID(uuid.NewString()).
+		IssuedAt(now).
+		Expiration(now.Add(duration)).
+		Claim("azp", code.ClientId).
+		Claim("auth_time", code.AuthTime.Unix())
+
+	if code.Nonce != "" {
+		builder = builder.Claim("nonce", code.Nonce)
+	}
+
+	if amr := strings.Fields(code.Amr); len(amr) > 0 {
+		builder = builder.Claim("amr", amr)
+	}
+
+	if info.PreferredUsername != "" {
+		builder = builder.Claim("preferred_username", info.PreferredUsername)
+	}
+	if info.Name != "" {
+		builder = builder.Claim("name", info.Name)
+	}
+	if info.Picture != "" {
+		builder = builder.Claim("picture", info.Picture)
+	}
+	if info.Website != "" {
+		builder = builder.Claim("website", info.Website)
+	}
+
+	if info.EmailVerified != nil {
+		builder = builder.
+			Claim("email", info.Email).
+			Claim("email_verified", *info.EmailVerified)
+	}
+
+	return builder.Build()
+}
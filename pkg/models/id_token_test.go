@@ -0,0 +1,67 @@
+package models
+
+import (
+	"github.com/google/uuid"
+	"github.com/knockbox/authentication/pkg/utils"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestUser_CreateIDToken(t *testing.T) {
+	config := &utils.TokenConfig{Issuer: "https://auth.knockbox.io"}
+	user := &User{AccountId: uuid.New(), Username: "user", Email: "user@knockbox.io"}
+	details := &UserDetails{FullName: "User", Verified: true}
+	authTime := time.Now().Add(-time.Minute).Truncate(time.Second)
+
+	tests := []struct {
+		name    string
+		code    *AuthorizationCode
+		present []string
+		absent  []string
+	}{
+		{
+			name:    "openid only",
+			code:    &AuthorizationCode{ClientId: "web", Scope: "openid", AuthTime: authTime, Amr: "pwd"},
+			present: []string{"auth_time", "amr", "azp"},
+			absent:  []string{"nonce", "preferred_username", "name", "email", "email_verified"},
+		},
+		{
+			name:    "profile and nonce",
+			code:    &AuthorizationCode{ClientId: "web", Scope: "openid profile", Nonce: "n-0S6", AuthTime: authTime, Amr: "pwd"},
+			present: []string{"nonce", "preferred_username", "name"},
+			absent:  []string{"email", "email_verified"},
+		},
+		{
+			name:    "email",
+			code:    &AuthorizationCode{ClientId: "web", Scope: "openid email", AuthTime: authTime, Amr: "pwd"},
+			present: []string{"email", "email_verified"},
+			absent:  []string{"preferred_username", "name"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, err := user.CreateIDToken(config, time.Minute, tt.code, details)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assert.Equal(t, []string{tt.code.ClientId}, token.Audience())
+			assert.Equal(t, user.AccountId.String(), token.Subject())
+
+			claims := token.PrivateClaims()
+			for _, claim := range tt.present {
+				assert.Containsf(t, claims, claim, "expected claim %s", claim)
+			}
+			for _, claim := range tt.absent {
+				assert.NotContainsf(t, claims, claim, "unexpected claim %s", claim)
+			}
+
+			if tt.code.Nonce != "" {
+				assert.Equal(t, tt.code.Nonce, claims["nonce"])
+			}
+			assert.Equal(t, authTime.Unix(), claims["auth_time"])
+		})
+	}
+}
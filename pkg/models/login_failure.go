@@ -0,0 +1,85 @@
+package models
+
+import (
+	"github.com/knockbox/authentication/pkg/utils"
+	"time"
+)
+
+// Kinds of subject failed logins are counted against.
+const (
+	LoginFailureAccount = "account"
+	LoginFailureIp      = "ip"
+)
+
+// LoginFailure defines the failed logins of an account or IP address in our database. Failures counts the
+// failures since the last lock, Lockouts the locks within the window, and no attempt is allowed before RetryAt.
+type LoginFailure struct {
+	Id           uint       `db:"id"`
+	Kind         string     `db:"kind"`
+	Subject      string     `db:"subject"`
+	Failures     uint       `db:"failures"`
+	Lockouts     uint       `db:"lockouts"`
+	RetryAt      *time.Time `db:"retry_at"`
+	LastFailedAt time.Time  `db:"last_failed_at"`
+}
+
+// RetryAfter returns how long until another attempt is allowed, 0 if one is allowed now.
+func (f *LoginFailure) RetryAfter(now time.Time) time.Duration {
+	if f.RetryAt == nil || !now.Before(*f.RetryAt) {
+		return 0
+	}
+
+	return f.RetryAt.Sub(now)
+}
+
+// Penalize sets RetryAt after the latest failure. Each failure doubles the back-off, from config.Backoff up to
+// config.Duration, until the threshold locks the subject. Each lock doubles in turn, from config.Duration up to
+// config.Window, and restarts the count of failures. Returns true if the failure locked the subject.
+func (f *LoginFailure) Penalize(config *utils.LockoutConfig, threshold uint, now time.Time) bool {
+	if f.Failures >= threshold {
+		f.Failures = 0
+		f.Lockouts++
+
+		until := now.Add(doubled(config.Duration, f.Lockouts-1, config.Window))
+		f.RetryAt = &until
+		return true
+	}
+
+	until := now.Add(doubled(config.Backoff, f.Failures-1, config.Duration))
+	f.RetryAt = &until
+	return false
+}
+
+// Reserve holds off other attempts for the back-off the pending attempt earns if it fails, so concurrent
+// guesses cannot all get through before any of them is counted.
+func (f *LoginFailure) Reserve(config *utils.LockoutConfig, now time.Time) {
+	until := now.Add(doubled(config.Backoff, f.Failures, config.Duration))
+	f.RetryAt = &until
+}
+
+// Release undoes Reserve once the pending attempt did not fail, restoring the back-off of the latest failure. A
+// lock is kept, another attempt may have set it meanwhile.
+func (f *LoginFailure) Release(config *utils.LockoutConfig) {
+	if f.Failures == 0 {
+		if f.Lockouts == 0 {
+			f.RetryAt = nil
+		}
+		return
+	}
+
+	until := f.LastFailedAt.Add(doubled(config.Backoff, f.Failures-1, config.Duration))
+	f.RetryAt = &until
+}
+
+// doubled returns d doubled n times, capped at max.
+func doubled(d time.Duration, n uint, max time.Duration) time.Duration {
+	for ; n > 0 && d < max; n-- {
+		d *= 2
+	}
+
+	if d > max {
+		return max
+	}
+
+	return d
+}
@@ -0,0 +1,154 @@
+package models
+
+import (
+	"github.com/knockbox/authentication/pkg/utils"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestLoginFailure_Penalize(t *testing.T) {
+	config := &utils.LockoutConfig{
+		Backoff:  time.Second,
+		Duration: 15 * time.Minute,
+		Window:   24 * time.Hour,
+	}
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name         string
+		failure      LoginFailure
+		wantLocked   bool
+		wantWait     time.Duration
+		wantFailures uint
+		wantLockouts uint
+	}{
+		{
+			name:         "should back off after the first failure",
+			failure:      LoginFailure{Failures: 1},
+			wantWait:     time.Second,
+			wantFailures: 1,
+		},
+		{
+			name:         "should double the back-off with each failure",
+			failure:      LoginFailure{Failures: 4},
+			wantWait:     8 * time.Second,
+			wantFailures: 4,
+		},
+		{
+			name:         "should cap the back-off at the lock duration",
+			failure:      LoginFailure{Failures: 19},
+			wantWait:     15 * time.Minute,
+			wantFailures: 19,
+		},
+		{
+			name:         "should lock at the threshold",
+			failure:      LoginFailure{Failures: 20},
+			wantLocked:   true,
+			wantWait:     15 * time.Minute,
+			wantLockouts: 1,
+		},
+		{
+			name:         "should double each lock",
+			failure:      LoginFailure{Failures: 20, Lockouts: 2},
+			wantLocked:   true,
+			wantWait:     time.Hour,
+			wantLockouts: 3,
+		},
+		{
+			name:         "should cap locks at the window",
+			failure:      LoginFailure{Failures: 20, Lockouts: 10},
+			wantLocked:   true,
+			wantWait:     24 * time.Hour,
+			wantLockouts: 11,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := tt.failure
+			assert.Equal(t, tt.wantLocked, f.Penalize(config, 20, now))
+			assert.Equal(t, tt.wantWait, f.RetryAfter(now))
+			assert.Equal(t, tt.wantFailures, f.Failures)
+			assert.Equal(t, tt.wantLockouts, f.Lockouts)
+		})
+	}
+}
+
+func TestLoginFailure_RetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	past, future := now.Add(-time.Second), now.Add(time.Minute)
+
+	tests := []struct {
+		name    string
+		retryAt *time.Time
+		want    time.Duration
+	}{
+		{name: "should allow without a penalty", retryAt: nil, want: 0},
+		{name: "should allow once the penalty passed", retryAt: &past, want: 0},
+		{name: "should wait out the penalty", retryAt: &future, want: time.Minute},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := &LoginFailure{RetryAt: tt.retryAt}
+			assert.Equal(t, tt.want, f.RetryAfter(now))
+		})
+	}
+}
+
+func TestLoginFailure_Reserve(t *testing.T) {
+	config := &utils.LockoutConfig{
+		Backoff:  time.Second,
+		Duration: 15 * time.Minute,
+		Window:   24 * time.Hour,
+	}
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		failures uint
+		want     time.Duration
+	}{
+		{name: "should hold off for the first back-off without failures", failures: 0, want: time.Second},
+		{name: "should hold off for the back-off of the next failure", failures: 4, want: 16 * time.Second},
+		{name: "should cap at the lock duration", failures: 19, want: 15 * time.Minute},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := &LoginFailure{Failures: tt.failures}
+			f.Reserve(config, now)
+			assert.Equal(t, tt.want, f.RetryAfter(now))
+		})
+	}
+}
+
+func TestLoginFailure_Release(t *testing.T) {
+	config := &utils.LockoutConfig{
+		Backoff:  time.Second,
+		Duration: 15 * time.Minute,
+		Window:   24 * time.Hour,
+	}
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	lock := now.Add(15 * time.Minute)
+
+	tests := []struct {
+		name    string
+		failure LoginFailure
+		reserve bool
+		want    time.Duration
+	}{
+		{name: "should lift the reservation without failures", failure: LoginFailure{}, reserve: true, want: 0},
+		{name: "should restore the back-off of the latest failure", failure: LoginFailure{Failures: 4}, reserve: true, want: 8 * time.Second},
+		{name: "should keep a lock", failure: LoginFailure{Lockouts: 1, RetryAt: &lock}, want: 15 * time.Minute},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := tt.failure
+			f.LastFailedAt = now
+			if tt.reserve {
+				f.Reserve(config, now)
+			}
+			f.Release(config)
+			assert.Equal(t, tt.want, f.RetryAfter(now))
+		})
+	}
+}
@@ -0,0 +1,31 @@
+package models
+
+import (
+	"github.com/google/uuid"
+	"github.com/knockbox/authentication/pkg/utils"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+	"time"
+)
+
+// CreateMFAToken returns a jwt.Token proving the User gave their password, exchanged for tokens in the requested
+// scope once the second factor is given. Its audience is the issuer itself so it is never accepted as an access
+// token elsewhere.
+func (u *User) CreateMFAToken(config *utils.TokenConfig, duration time.Duration, scope string) (jwt.Token, error) {
+	now := time.Now()
+
+	return jwt.NewBuilder().
+		Issuer(config.Issuer).
+		Audience([]string{config.Issuer}).
+		Subject(u.AccountId.String()).
+		JwtID(uuid.NewString()).
+		IssuedAt(now).
+		Expiration(now.Add(duration)).
+		Claim("scope", scope).
+		Build()
+}
+
+// MFATokenScope returns the scope requested with the password the MFA token was issued for.
+func MFATokenScope(token jwt.Token) string {
+	scope, _ := token.PrivateClaims()["scope"].(string)
+	return scope
+}
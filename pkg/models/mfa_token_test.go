@@ -0,0 +1,24 @@
+package models
+
+import (
+	"github.com/google/uuid"
+	"github.com/knockbox/authentication/pkg/utils"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestUser_CreateMFAToken(t *testing.T) {
+	config := &utils.TokenConfig{Issuer: "https://auth.knockbox.io", Audience: []string{"knockbox"}}
+	user := &User{AccountId: uuid.New()}
+
+	token, err := user.CreateMFAToken(config, time.Minute, "openid user:read")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, []string{config.Issuer}, token.Audience(), "should not be accepted by other audiences")
+	assert.Equal(t, user.AccountId.String(), token.Subject())
+	assert.NotEmpty(t, token.JwtID(), "should have a jti to be used once")
+	assert.Equal(t, "openid user:read", MFATokenScope(token))
+}
@@ -0,0 +1,128 @@
+package models
+
+import (
+	"crypto/subtle"
+	"github.com/google/uuid"
+	"github.com/knockbox/authentication/pkg/payloads"
+	"github.com/knockbox/authentication/pkg/utils"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+	"strings"
+	"time"
+)
+
+// OAuthClient defines an application registered to request authorization on behalf of users, or on its own
+// behalf when it is confidential. RedirectURIs and AllowedScopes are space separated lists, a redirect_uri must
+// match one exactly. Only the hash of the secret of a confidential client is stored.
+type OAuthClient struct {
+	Id               uint      `db:"id"`
+	ClientId         string    `db:"client_id"`
+	ClientSecretHash *string   `db:"client_secret_hash"`
+	Name             string    `db:"name"`
+	RedirectURIs     string    `db:"redirect_uris"`
+	AllowedScopes    string    `db:"allowed_scopes"`
+	FirstParty       bool      `db:"first_party"`
+	CreatedAt        time.Time `db:"created_at"`
+}
+
+// ApplyRegister updates the client with the values from the payloads.OAuthClientRegister.
+func (c *OAuthClient) ApplyRegister(payload *payloads.OAuthClientRegister) {
+	c.Name = payload.Name
+	c.RedirectURIs = strings.Join(payload.RedirectURIs, " ")
+	c.AllowedScopes = strings.Join(payload.Scopes, " ")
+	c.FirstParty = payload.FirstParty
+}
+
+// SetSecret stores the hash of the secret, making the client confidential.
+func (c *OAuthClient) SetSecret(secret string) {
+	hash := utils.HashToken(secret)
+	c.ClientSecretHash = &hash
+}
+
+// IsConfidential determines if the client has a secret to authenticate with.
+func (c *OAuthClient) IsConfidential() bool {
+	return c.ClientSecretHash != nil
+}
+
+// VerifySecret determines if the secret is that of the client, public clients never verify.
+func (c *OAuthClient) VerifySecret(secret string) bool {
+	if !c.IsConfidential() {
+		return false
+	}
+
+	return subtle.ConstantTimeCompare([]byte(utils.HashToken(secret)), []byte(*c.ClientSecretHash)) == 1
+}
+
+// GetRedirectURIs returns the registered redirect URIs.
+func (c *OAuthClient) GetRedirectURIs() []string {
+	return strings.Fields(c.RedirectURIs)
+}
+
+// HasRedirectURI determines if the uri is registered, comparison is exact.
+func (c *OAuthClient) HasRedirectURI(uri string) bool {
+	for _, registered := range c.GetRedirectURIs() {
+		if registered == uri {
+			return true
+		}
+	}
+
+	return false
+}
+
+// GetAllowedScopes returns the scopes the client may request, for itself or on behalf of a user.
+func (c *OAuthClient) GetAllowedScopes() []string {
+	return strings.Fields(c.AllowedScopes)
+}
+
+// GrantScopes returns the requested scopes if every one of them is allowed, or false otherwise. Requesting no
+// scope grants every allowed scope.
+func (c *OAuthClient) GrantScopes(requested string) (string, bool) {
+	return grantScopes(c.GetAllowedScopes(), requested)
+}
+
+// CreateToken returns a jwt.Token for the client acting on its own behalf. The sub and client_id claims are both
+// the client_id, which is how a service principal is told apart from a User.
+func (c *OAuthClient) CreateToken(config *utils.TokenConfig, duration time.Duration, scope string) (jwt.Token, error) {
+	now := time.Now()
+
+	builder := jwt.NewBuilder().
+		Issuer(config.Issuer).
+		Audience(config.Audience).
+		Subject(c.ClientId).
+		JwtID(uuid.NewString()).
+		IssuedAt(now).
+		NotBefore(now).
+		Expiration(now.Add(duration)).
+		Claim("client_id", c.ClientId)
+
+	if scope != "" {
+		builder = builder.Claim("scope", scope)
+	}
+
+	return builder.Build()
+}
+
+// DTO converts the OAuthClient to the OAuthClientDTO.
+func (c *OAuthClient) DTO() *OAuthClientDTO {
+	return &OAuthClientDTO{
+		ClientId:     c.ClientId,
+		Name:         c.Name,
+		RedirectURIs: c.GetRedirectURIs(),
+		Scopes:       c.GetAllowedScopes(),
+		Confidential: c.IsConfidential(),
+		FirstParty:   c.FirstParty,
+		CreatedAt:    c.CreatedAt,
+	}
+}
+
+// OAuthClientDTO is used when returning the OAuthClient as JSON. The ClientSecret is only ever present in the
+// response to registration.
+type OAuthClientDTO struct {
+	ClientId     string    `json:"client_id"`
+	ClientSecret string    `json:"client_secret,omitempty"`
+	Name         string    `json:"name"`
+	RedirectURIs []string  `json:"redirect_uris"`
+	Scopes       []string  `json:"scopes"`
+	Confidential bool      `json:"confidential"`
+	FirstParty   bool      `json:"first_party"`
+	CreatedAt    time.Time `json:"created_at"`
+}
@@ -0,0 +1,128 @@
+package models
+
+import (
+	"github.com/knockbox/authentication/pkg/payloads"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestOAuthClient_HasRedirectURI(t *testing.T) {
+	client := &OAuthClient{}
+	client.ApplyRegister(&payloads.OAuthClientRegister{
+		Name:         "web",
+		RedirectURIs: []string{"https://app.example.com/callback", "http://127.0.0.1:8080/callback"},
+	})
+
+	tests := []struct {
+		name string
+		uri  string
+		want bool
+	}{
+		{
+			name: "registered",
+			uri:  "https://app.example.com/callback",
+			want: true,
+		},
+		{
+			name: "second registered",
+			uri:  "http://127.0.0.1:8080/callback",
+			want: true,
+		},
+		{
+			name: "trailing slash",
+			uri:  "https://app.example.com/callback/",
+			want: false,
+		},
+		{
+			name: "extra query",
+			uri:  "https://app.example.com/callback?next=https://evil.example.com",
+			want: false,
+		},
+		{
+			name: "empty",
+			uri:  "",
+			want: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, client.HasRedirectURI(tt.uri))
+		})
+	}
+}
+
+func TestOAuthClient_VerifySecret(t *testing.T) {
+	confidential := &OAuthClient{}
+	confidential.SetSecret("secret")
+
+	tests := []struct {
+		name   string
+		client *OAuthClient
+		secret string
+		want   bool
+	}{
+		{
+			name:   "confidential with secret",
+			client: confidential,
+			secret: "secret",
+			want:   true,
+		},
+		{
+			name:   "confidential with wrong secret",
+			client: confidential,
+			secret: "other",
+			want:   false,
+		},
+		{
+			name:   "public with empty secret",
+			client: &OAuthClient{},
+			secret: "",
+			want:   false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.client.VerifySecret(tt.secret))
+		})
+	}
+}
+
+func TestOAuthClient_GrantScopes(t *testing.T) {
+	client := &OAuthClient{AllowedScopes: "users:read users:write"}
+
+	tests := []struct {
+		name      string
+		requested string
+		want      string
+		wantOk    bool
+	}{
+		{
+			name:      "nothing requested grants all",
+			requested: "",
+			want:      "users:read users:write",
+			wantOk:    true,
+		},
+		{
+			name:      "subset",
+			requested: "users:read",
+			want:      "users:read",
+			wantOk:    true,
+		},
+		{
+			name:      "not allowed",
+			requested: "users:read keys:write",
+			want:      "",
+			wantOk:    false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := client.GrantScopes(tt.requested)
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.wantOk, ok)
+		})
+	}
+}
@@ -38,7 +38,7 @@ func PageFromRequest(r *http.Request) *Page {
 	}
 
 	if query.Has("offset") {
-		if offset, err := strconv.Atoi(query.Get("limit")); err == nil {
+		if offset, err := strconv.Atoi(query.Get("offset")); err == nil {
 			if offset > 0 {
 				page.Offset = uint(offset)
 			}
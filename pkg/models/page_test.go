@@ -0,0 +1,41 @@
+package models
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+)
+
+func TestPageFromRequest(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  *Page
+	}{
+		{
+			name:  "should use defaults",
+			query: "",
+			want:  &Page{Limit: 15, Offset: 0},
+		},
+		{
+			name:  "should read limit and offset",
+			query: "?limit=5&offset=10",
+			want:  &Page{Limit: 5, Offset: 10},
+		},
+		{
+			name:  "should ignore invalid values",
+			query: "?limit=100&offset=-1",
+			want:  &Page{Limit: 15, Offset: 0},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := http.NewRequest(http.MethodGet, "/"+tt.query, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assert.Equal(t, tt.want, PageFromRequest(r))
+		})
+	}
+}
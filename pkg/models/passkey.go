@@ -0,0 +1,208 @@
+package models
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/google/uuid"
+	"github.com/knockbox/authentication/pkg/utils"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+	"strings"
+	"time"
+)
+
+// Passkey defines a WebAuthn credential of a User in our database. SignCount is the signature counter of the
+// last assertion, used to detect cloned authenticators, and Flags are the authenticator flags it last reported.
+type Passkey struct {
+	Id              uint       `db:"id"`
+	UserId          uint       `db:"user_id"`
+	CredentialId    []byte     `db:"credential_id"`
+	Name            string     `db:"name"`
+	PublicKey       []byte     `db:"public_key"`
+	AttestationType string     `db:"attestation_type"`
+	Transports      string     `db:"transports"`
+	AAGUID          []byte     `db:"aaguid"`
+	SignCount       uint32     `db:"sign_count"`
+	Flags           uint8      `db:"flags"`
+	CreatedAt       time.Time  `db:"created_at"`
+	LastUsedAt      *time.Time `db:"last_used_at"`
+}
+
+// NewPasskey constructs the Passkey of the User from a verified registration.
+func NewPasskey(userId uint, name string, credential *webauthn.Credential) *Passkey {
+	transports := make([]string, len(credential.Transport))
+	for i, transport := range credential.Transport {
+		transports[i] = string(transport)
+	}
+
+	return &Passkey{
+		UserId:          userId,
+		CredentialId:    credential.ID,
+		Name:            name,
+		PublicKey:       credential.PublicKey,
+		AttestationType: credential.AttestationType,
+		Transports:      strings.Join(transports, " "),
+		AAGUID:          credential.Authenticator.AAGUID,
+		SignCount:       credential.Authenticator.SignCount,
+		Flags:           flagsOf(credential.Flags),
+	}
+}
+
+// ApplyAssertion records the signature counter and flags of a verified login with the Passkey.
+func (p *Passkey) ApplyAssertion(credential *webauthn.Credential) {
+	p.SignCount = credential.Authenticator.SignCount
+	p.Flags = flagsOf(credential.Flags)
+}
+
+// Credential converts the Passkey to the webauthn.Credential it was created from.
+func (p *Passkey) Credential() webauthn.Credential {
+	var transports []protocol.AuthenticatorTransport
+	for _, transport := range strings.Fields(p.Transports) {
+		transports = append(transports, protocol.AuthenticatorTransport(transport))
+	}
+
+	return webauthn.Credential{
+		ID:              p.CredentialId,
+		PublicKey:       p.PublicKey,
+		AttestationType: p.AttestationType,
+		Transport:       transports,
+		Flags:           webauthn.NewCredentialFlags(protocol.AuthenticatorFlags(p.Flags)),
+		Authenticator: webauthn.Authenticator{
+			AAGUID:    p.AAGUID,
+			SignCount: p.SignCount,
+		},
+	}
+}
+
+// DTO converts the Passkey to the PasskeyDTO.
+func (p *Passkey) DTO() *PasskeyDTO {
+	return &PasskeyDTO{
+		Id:         base64.RawURLEncoding.EncodeToString(p.CredentialId),
+		Name:       p.Name,
+		Synced:     p.Credential().Flags.BackupState,
+		CreatedAt:  p.CreatedAt,
+		LastUsedAt: p.LastUsedAt,
+	}
+}
+
+// flagsOf returns the authenticator flags of the webauthn.CredentialFlags as stored.
+func flagsOf(f webauthn.CredentialFlags) uint8 {
+	var flags protocol.AuthenticatorFlags
+	if f.UserPresent {
+		flags |= protocol.FlagUserPresent
+	}
+
+	if f.UserVerified {
+		flags |= protocol.FlagUserVerified
+	}
+
+	if f.BackupEligible {
+		flags |= protocol.FlagBackupEligible
+	}
+
+	if f.BackupState {
+		flags |= protocol.FlagBackupState
+	}
+
+	return uint8(flags)
+}
+
+// PasskeyDTO is used when returning the Passkey as JSON. The Id is the base64url credential id.
+type PasskeyDTO struct {
+	Id         string     `json:"id"`
+	Name       string     `json:"name"`
+	Synced     bool       `json:"synced"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+}
+
+// WebAuthnUser is the User and their Passkey(s) as seen by the WebAuthn ceremonies. The user handle is the
+// account_id, which is random and reveals nothing about the User.
+type WebAuthnUser struct {
+	*User
+	Passkeys []Passkey
+}
+
+func (u *WebAuthnUser) WebAuthnID() []byte {
+	return u.AccountId[:]
+}
+
+func (u *WebAuthnUser) WebAuthnName() string {
+	return u.Username
+}
+
+func (u *WebAuthnUser) WebAuthnDisplayName() string {
+	return u.Username
+}
+
+func (u *WebAuthnUser) WebAuthnCredentials() []webauthn.Credential {
+	credentials := make([]webauthn.Credential, len(u.Passkeys))
+	for i := range u.Passkeys {
+		credentials[i] = u.Passkeys[i].Credential()
+	}
+
+	return credentials
+}
+
+// Passkey returns the Passkey with the credential id, or nil if the User has none.
+func (u *WebAuthnUser) Passkey(credentialId []byte) *Passkey {
+	for i := range u.Passkeys {
+		if string(u.Passkeys[i].CredentialId) == string(credentialId) {
+			return &u.Passkeys[i]
+		}
+	}
+
+	return nil
+}
+
+// Ceremonies carried by a WebAuthn session token.
+const (
+	CeremonyRegistration = "registration"
+	CeremonyLogin        = "login"
+)
+
+// CreateWebAuthnSessionToken returns a jwt.Token holding the webauthn.SessionData of a ceremony until the browser
+// responds, so no state is kept between the two requests. Registrations are bound to the account_id of the User
+// as the subject, logins have none. Its audience is the issuer itself so it is never accepted as an access token
+// elsewhere.
+func CreateWebAuthnSessionToken(config *utils.TokenConfig, ceremony, subject string, session *webauthn.SessionData) (jwt.Token, error) {
+	bs, err := json.Marshal(session)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+
+	return jwt.NewBuilder().
+		Issuer(config.Issuer).
+		Audience([]string{config.Issuer}).
+		Subject(subject).
+		JwtID(uuid.NewString()).
+		IssuedAt(now).
+		Expiration(session.Expires).
+		Claim("ceremony", ceremony).
+		Claim("session", string(bs)).
+		Build()
+}
+
+// WebAuthnSessionFrom returns the webauthn.SessionData held by the token if it was issued for the ceremony.
+func WebAuthnSessionFrom(token jwt.Token, ceremony string) (*webauthn.SessionData, error) {
+	claims := token.PrivateClaims()
+	if got, _ := claims["ceremony"].(string); got != ceremony {
+		return nil, errors.New("session token was issued for another ceremony")
+	}
+
+	raw, ok := claims["session"].(string)
+	if !ok {
+		return nil, errors.New("session token is missing session")
+	}
+
+	session := &webauthn.SessionData{}
+	if err := json.Unmarshal([]byte(raw), session); err != nil {
+		return nil, err
+	}
+
+	return session, nil
+}
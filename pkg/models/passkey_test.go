@@ -0,0 +1,109 @@
+package models
+
+import (
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/google/uuid"
+	"github.com/knockbox/authentication/pkg/utils"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestNewPasskey(t *testing.T) {
+	credential := &webauthn.Credential{
+		ID:              []byte{1, 2, 3},
+		PublicKey:       []byte{4, 5, 6},
+		AttestationType: "none",
+		Transport:       []protocol.AuthenticatorTransport{protocol.Internal, protocol.Hybrid},
+		Flags: webauthn.NewCredentialFlags(
+			protocol.FlagUserPresent | protocol.FlagUserVerified | protocol.FlagBackupEligible | protocol.FlagBackupState,
+		),
+		Authenticator: webauthn.Authenticator{AAGUID: make([]byte, 16), SignCount: 7},
+	}
+
+	passkey := NewPasskey(1, "Phone", credential)
+	assert.Equal(t, "internal hybrid", passkey.Transports)
+
+	got := passkey.Credential()
+	assert.Equal(t, credential.ID, got.ID)
+	assert.Equal(t, credential.PublicKey, got.PublicKey)
+	assert.Equal(t, credential.Transport, got.Transport)
+	assert.Equal(t, credential.Flags, got.Flags, "flags should survive storage")
+	assert.Equal(t, credential.Authenticator.SignCount, got.Authenticator.SignCount)
+
+	got.Authenticator.SignCount = 8
+	got.Flags.BackupState = false
+	passkey.ApplyAssertion(&got)
+	assert.Equal(t, uint32(8), passkey.SignCount)
+	assert.False(t, passkey.Credential().Flags.BackupState, "flags should follow the assertion")
+	assert.True(t, passkey.Credential().Flags.BackupEligible)
+
+	passkey.ApplyAssertion(credential)
+	dto := passkey.DTO()
+	assert.Equal(t, "AQID", dto.Id, "id should be the base64url credential id")
+	assert.True(t, dto.Synced)
+}
+
+func TestWebAuthnUser_Passkey(t *testing.T) {
+	user := &WebAuthnUser{
+		User: &User{AccountId: uuid.New()},
+		Passkeys: []Passkey{
+			{Id: 1, CredentialId: []byte{1}},
+			{Id: 2, CredentialId: []byte{2}},
+		},
+	}
+
+	assert.Equal(t, user.AccountId[:], user.WebAuthnID())
+	assert.Len(t, user.WebAuthnCredentials(), 2)
+	assert.Equal(t, uint(2), user.Passkey([]byte{2}).Id)
+	assert.Nil(t, user.Passkey([]byte{3}))
+}
+
+func TestWebAuthnSessionFrom(t *testing.T) {
+	config := &utils.TokenConfig{Issuer: "https://auth.knockbox.io"}
+	session := &webauthn.SessionData{
+		Challenge:        "challenge",
+		UserID:           []byte{1, 2, 3},
+		UserVerification: protocol.VerificationRequired,
+		Expires:          time.Now().Add(time.Minute).Truncate(time.Second),
+	}
+
+	token, err := CreateWebAuthnSessionToken(config, CeremonyRegistration, "account", session)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, []string{config.Issuer}, token.Audience())
+	assert.True(t, session.Expires.Equal(token.Expiration()), "token should expire with the ceremony")
+
+	tests := []struct {
+		name     string
+		ceremony string
+		wantErr  bool
+	}{
+		{
+			name:     "should return the session of the ceremony",
+			ceremony: CeremonyRegistration,
+		},
+		{
+			name:     "should refuse another ceremony",
+			ceremony: CeremonyLogin,
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := WebAuthnSessionFrom(token, tt.ceremony)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, session.Challenge, got.Challenge)
+			assert.Equal(t, session.UserID, got.UserID)
+			assert.Equal(t, session.UserVerification, got.UserVerification)
+		})
+	}
+}
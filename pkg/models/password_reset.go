@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// PasswordReset defines a password reset token emailed to a User in our database. Only the hash of the token is
+// stored.
+type PasswordReset struct {
+	Id        uint       `db:"id"`
+	UserId    uint       `db:"user_id"`
+	TokenHash string     `db:"token_hash"`
+	IssuedAt  time.Time  `db:"issued_at"`
+	ExpiresAt time.Time  `db:"expires_at"`
+	UsedAt    *time.Time `db:"used_at"`
+}
+
+// IsExpired determines if the PasswordReset is past its expiry.
+func (p *PasswordReset) IsExpired() bool {
+	return !time.Now().Before(p.ExpiresAt)
+}
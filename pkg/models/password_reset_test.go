@@ -0,0 +1,31 @@
+package models
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestPasswordReset_IsExpired(t *testing.T) {
+	tests := []struct {
+		name      string
+		expiresAt time.Time
+		want      bool
+	}{
+		{
+			name:      "should not be expired before expires_at",
+			expiresAt: time.Now().Add(time.Minute),
+			want:      false,
+		},
+		{
+			name:      "should be expired after expires_at",
+			expiresAt: time.Now().Add(-time.Minute),
+			want:      true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, (&PasswordReset{ExpiresAt: tt.expiresAt}).IsExpired())
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package models
+
+import (
+	"errors"
+	"github.com/knockbox/authentication/pkg/enums"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+	"strings"
+)
+
+// Principal is the party a verified bearer token was issued to, parsed once by middleware.BearerToken. A user
+// principal has a Role and its Subject is their account_id. A client principal is an OAuth client acting on its
+// own behalf, it has no Role and its Subject is its ClientId. Amr lists how a user authenticated, see RFC 8176.
+type Principal struct {
+	Subject   string
+	ClientId  string
+	Username  string
+	Role      enums.UserRole
+	SessionId string
+	Scopes    []enums.Scope
+	Amr       []string
+	Token     jwt.Token
+}
+
+// NewPrincipal reads the principal from the claims of a verified token, user tokens must carry a role.
+func NewPrincipal(token jwt.Token) (*Principal, error) {
+	claims := token.PrivateClaims()
+
+	p := &Principal{
+		Subject: token.Subject(),
+		Token:   token,
+	}
+
+	p.ClientId, _ = claims["client_id"].(string)
+	p.Username, _ = claims["username"].(string)
+	p.SessionId, _ = claims["sid"].(string)
+
+	rawScope, _ := claims["scope"].(string)
+	for _, scope := range strings.Fields(rawScope) {
+		p.Scopes = append(p.Scopes, enums.Scope(scope))
+	}
+
+	// amr is a []string when built here and a []interface{} once parsed.
+	switch amr := claims["amr"].(type) {
+	case []string:
+		p.Amr = amr
+	case []interface{}:
+		for _, method := range amr {
+			if method, ok := method.(string); ok {
+				p.Amr = append(p.Amr, method)
+			}
+		}
+	}
+
+	if p.IsClient() {
+		return p, nil
+	}
+
+	rawRole, ok := claims["role"].(string)
+	if !ok {
+		return nil, errors.New("token is missing role")
+	}
+	p.Role = enums.UserRoleFromString(rawRole)
+
+	return p, nil
+}
+
+// IsClient determines if the principal is an OAuth client acting on its own behalf rather than a user.
+func (p *Principal) IsClient() bool {
+	return p.ClientId != "" && p.ClientId == p.Subject
+}
+
+// HasScope determines if the token of the principal was granted the scope.
+func (p *Principal) HasScope(scope enums.Scope) bool {
+	for _, granted := range p.Scopes {
+		if granted == scope {
+			return true
+		}
+	}
+
+	return false
+}
+
+// HasAuthenticatedWith determines if the user authenticated with the method, e.g. "mfa" for a second factor.
+func (p *Principal) HasAuthenticatedWith(method string) bool {
+	for _, used := range p.Amr {
+		if used == method {
+			return true
+		}
+	}
+
+	return false
+}
+
+// HasRole determines if the principal is a user with at least the role, clients never have a role.
+func (p *Principal) HasRole(min enums.UserRole) bool {
+	return !p.IsClient() && p.Role.HasRequiredRole(min)
+}
@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+// RefreshToken defines an issued refresh token in our database. Only the hash of the token is stored, tokens
+// rotated from the same login share a FamilyId. ClientId is the OAuth client it was issued to, empty for logins
+// to this service, and only that client may exchange it. The Scope and Amr (space separated) are those of the
+// access token it was issued with.
+type RefreshToken struct {
+	Id        uint       `db:"id"`
+	UserId    uint       `db:"user_id"`
+	FamilyId  string     `db:"family_id"`
+	ClientId  string     `db:"client_id"`
+	TokenHash string     `db:"token_hash"`
+	Scope     string     `db:"scope"`
+	Amr       string     `db:"amr"`
+	IssuedAt  time.Time  `db:"issued_at"`
+	ExpiresAt time.Time  `db:"expires_at"`
+	UsedAt    *time.Time `db:"used_at"`
+	RevokedAt *time.Time `db:"revoked_at"`
+}
+
+// IsExpired determines if the RefreshToken is past its expiry.
+func (t *RefreshToken) IsExpired() bool {
+	return !time.Now().Before(t.ExpiresAt)
+}
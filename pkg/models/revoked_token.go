@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// RevokedToken defines a denylist entry in our database. An entry either revokes a single jwt by Jti or every
+// jwt issued to the Subject up to RevokedAt, to the second. Entries are only kept until ExpiresAt, after which the
+// jwt(s) they revoke would have expired anyway.
+type RevokedToken struct {
+	Id        uint      `db:"id"`
+	Jti       *string   `db:"jti"`
+	Subject   *string   `db:"subject"`
+	RevokedAt time.Time `db:"revoked_at"`
+	ExpiresAt time.Time `db:"expires_at"`
+}
@@ -0,0 +1,28 @@
+package models
+
+import "strings"
+
+// grantScopes returns the requested scopes if every one of them is allowed, or false otherwise. Requesting no
+// scope grants every allowed scope.
+func grantScopes(allowed []string, requested string) (string, bool) {
+	if strings.TrimSpace(requested) == "" {
+		return strings.Join(allowed, " "), true
+	}
+
+	scopes := strings.Fields(requested)
+	for _, scope := range scopes {
+		found := false
+		for _, a := range allowed {
+			if a == scope {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return "", false
+		}
+	}
+
+	return strings.Join(scopes, " "), true
+}
@@ -0,0 +1,12 @@
+package models
+
+import "time"
+
+// SigningKey defines a persisted keyring.KeySet key in our database.
+type SigningKey struct {
+	Id          uint      `db:"id"`
+	Kid         string    `db:"kid"`
+	KeyData     []byte    `db:"key_data"`
+	ActiveUntil time.Time `db:"active_until"`
+	ExpiresAt   time.Time `db:"expires_at"`
+}
@@ -1,11 +1,13 @@
 package models
 
 import (
+	"fmt"
 	"github.com/google/uuid"
 	"github.com/knockbox/authentication/pkg/enums"
 	"github.com/knockbox/authentication/pkg/payloads"
 	"github.com/knockbox/authentication/pkg/utils"
 	"github.com/lestrrat-go/jwx/v2/jwt"
+	"strings"
 	"time"
 )
 
@@ -19,7 +21,8 @@ type User struct {
 	Role      enums.UserRole `db:"role"`
 }
 
-// NewUser creates a new User with an auto-generated uuid.UUID and role set to enums.User.
+// NewUser creates a new User with an auto-generated uuid.UUID and role set to enums.Pending until
+// their email is verified.
 func NewUser() *User {
 	return &User{
 		Id:        0,
@@ -27,7 +30,7 @@ func NewUser() *User {
 		Username:  "",
 		Password:  "",
 		Email:     "",
-		Role:      enums.User,
+		Role:      enums.Pending,
 	}
 }
 
@@ -45,12 +48,9 @@ func (u *User) ApplyRegister(payload *payloads.UserRegister) error {
 	return nil
 }
 
-// ApplyUpdate updates the user with the values from the payloads.UserUpdate.
+// ApplyUpdate updates the user with the values from the payloads.UserUpdate. The email is not applied, a new
+// email only replaces the current one once confirmed through CreateEmailChangeToken.
 func (u *User) ApplyUpdate(payload *payloads.UserUpdate) error {
-	if payload.Email != nil {
-		u.Email = *payload.Email
-	}
-
 	if payload.Password != nil {
 		pwd, err := utils.GeneratePassword(*payload.Password)
 		if err != nil {
@@ -59,41 +59,92 @@ func (u *User) ApplyUpdate(payload *payloads.UserUpdate) error {
 		u.Password = pwd
 	}
 
-	if payload.Role != nil {
-		u.Role = *payload.Role
-	}
-
 	return nil
 }
 
-// CreateToken returns a jwt.Token with claims from the User.
-func (u *User) CreateToken(duration time.Duration) (jwt.Token, error) {
-	return jwt.NewBuilder().
-		IssuedAt(time.Now()).
-		Expiration(time.Now().Add(duration)).
+// GrantScopes returns the requested scopes if the role of the User allows every one of them, or false otherwise.
+// Requesting no scope grants every scope the role allows.
+func (u *User) GrantScopes(requested string) (string, bool) {
+	return grantScopes(strings.Fields(enums.ScopesToString(enums.ScopesForRole(u.Role))), requested)
+}
+
+// CreateToken returns a jwt.Token with registered claims from the utils.TokenConfig and claims from the User. The
+// scope should have been granted by GrantScopes. The space separated amr names how the User authenticated.
+func (u *User) CreateToken(config *utils.TokenConfig, duration time.Duration, scope, amr string) (jwt.Token, error) {
+	now := time.Now()
+
+	builder := jwt.NewBuilder().
+		Issuer(config.Issuer).
+		Audience(config.Audience).
+		Subject(u.AccountId.String()).
+		JwtID(uuid.NewString()).
+		IssuedAt(now).
+		NotBefore(now).
+		Expiration(now.Add(duration)).
 		Claim("account_id", u.AccountId).
 		Claim("username", u.Username).
 		Claim("role", u.Role).
-		Build()
+		Claim("scope", scope)
+
+	if methods := strings.Fields(amr); len(methods) > 0 {
+		builder = builder.Claim("amr", methods)
+	}
+
+	return builder.Build()
 }
 
-// DTO converts the User to the UserDTO.
-func (u *User) DTO() *UserDTO {
-	return &UserDTO{
+// DTO converts the User to the UserDTO projected for the requesting principal, nil for anonymous callers. Everyone
+// sees the public fields. Tokens with the user:read scope also show the owner their email, moderators the email
+// and status, and admins the internal id and a link to the history of the User.
+func (u *User) DTO(p *Principal) *UserDTO {
+	dto := &UserDTO{
 		Id:        nil,
 		AccountId: u.AccountId,
 		Username:  u.Username,
 		Email:     nil,
 		Role:      u.Role,
 	}
+
+	if p == nil || p.IsClient() || !p.HasScope(enums.ScopeUserRead) {
+		return dto
+	}
+
+	if p.Subject == u.AccountId.String() || p.HasRole(enums.Moderator) {
+		dto.Email = &u.Email
+	}
+
+	if p.HasRole(enums.Moderator) {
+		status := accountStatus(u.Role)
+		dto.Status = &status
+	}
+
+	if p.HasRole(enums.Admin) {
+		history := fmt.Sprintf("/api/moderation/user/%s/history", u.AccountId)
+		dto.Id = &u.Id
+		dto.HistoryURL = &history
+	}
+
+	return dto
+}
+
+// accountStatus summarises whether the role allows the user to sign in.
+func accountStatus(role enums.UserRole) string {
+	if role.IsForbidden() {
+		return string(role)
+	}
+
+	return "active"
 }
 
 // UserDTO is used when returning the User as JSON. We omit fields based on the authorization
 // of the requesting agent.
 type UserDTO struct {
-	Id        *uint          `json:"id,omitempty"`
-	AccountId uuid.UUID      `json:"account_id"`
-	Username  string         `json:"username"`
-	Email     *string        `json:"email,omitempty"`
-	Role      enums.UserRole `json:"role"`
+	Id         *uint           `json:"id,omitempty"`
+	AccountId  uuid.UUID       `json:"account_id"`
+	Username   string          `json:"username"`
+	Email      *string         `json:"email,omitempty"`
+	Role       enums.UserRole  `json:"role"`
+	Status     *string         `json:"status,omitempty"`
+	HistoryURL *string         `json:"history_url,omitempty"`
+	Profile    *UserDetailsDTO `json:"profile,omitempty"`
 }
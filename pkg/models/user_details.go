@@ -16,7 +16,7 @@ type UserDetails struct {
 	Verified       bool   `db:"verified"`
 }
 
-// ApplyUpdate updates the user with the values from the payloads.UserUpdate.
+// ApplyUpdate updates the details with the values from the payloads.UserDetailsUpdate.
 func (u *UserDetails) ApplyUpdate(payload *payloads.UserDetailsUpdate) {
 	if payload.ProfilePicture != nil {
 		u.ProfilePicture = *payload.ProfilePicture
@@ -38,3 +38,25 @@ func (u *UserDetails) ApplyUpdate(payload *payloads.UserDetailsUpdate) {
 		u.WebsiteURL = *payload.WebsiteURL
 	}
 }
+
+// DTO converts the UserDetails to the UserDetailsDTO.
+func (u *UserDetails) DTO() *UserDetailsDTO {
+	return &UserDetailsDTO{
+		ProfilePicture: u.ProfilePicture,
+		FullName:       u.FullName,
+		GithubURL:      u.GithubURL,
+		TwitterURL:     u.TwitterURL,
+		WebsiteURL:     u.WebsiteURL,
+		Verified:       u.Verified,
+	}
+}
+
+// UserDetailsDTO is used when returning the UserDetails as JSON, every field of it is public.
+type UserDetailsDTO struct {
+	ProfilePicture string `json:"profile_picture"`
+	FullName       string `json:"full_name"`
+	GithubURL      string `json:"github_url"`
+	TwitterURL     string `json:"twitter_url"`
+	WebsiteURL     string `json:"website_url"`
+	Verified       bool   `json:"verified"`
+}
@@ -2,14 +2,70 @@ package models
 
 import (
 	"github.com/knockbox/authentication/pkg/enums"
+	"github.com/knockbox/authentication/pkg/utils"
+	"net/http"
+	"strings"
 	"time"
 )
 
-// UserHistory defines a User(s) history in our database.db
+// maxUserAgentLength is the length of the user_agent column, longer user agents are truncated.
+const maxUserAgentLength = 512
+
+// UserHistory defines a User(s) history in our database.db. PreviousRole, NewRole and ChangedBy are only set
+// for enums.UpdateRole, ChangedBy is the account_id of the user who changed the role and nil when the service
+// did, such as when locking an account after failed logins.
 type UserHistory struct {
-	Id        uint             `db:"id"`
-	UserId    uint             `db:"user_id"`
-	IpAddress string           `db:"ip_address"`
-	Timestamp time.Time        `db:"timestamp"`
-	Action    enums.UserAction `db:"action"`
+	Id           uint             `db:"id"`
+	UserId       uint             `db:"user_id"`
+	IpAddress    string           `db:"ip_address"`
+	UserAgent    string           `db:"user_agent"`
+	Timestamp    time.Time        `db:"timestamp"`
+	Action       enums.UserAction `db:"action"`
+	PreviousRole *enums.UserRole  `db:"previous_role"`
+	NewRole      *enums.UserRole  `db:"new_role"`
+	ChangedBy    *string          `db:"changed_by"`
+}
+
+// DTO converts the UserHistory to the UserHistoryDTO.
+func (u *UserHistory) DTO() *UserHistoryDTO {
+	return &UserHistoryDTO{
+		Action:       u.Action,
+		IpAddress:    u.IpAddress,
+		UserAgent:    u.UserAgent,
+		Timestamp:    u.Timestamp,
+		PreviousRole: u.PreviousRole,
+		NewRole:      u.NewRole,
+		ChangedBy:    u.ChangedBy,
+	}
+}
+
+// UserHistoryDTO is used when returning the UserHistory as JSON.
+type UserHistoryDTO struct {
+	Action       enums.UserAction `json:"action"`
+	IpAddress    string           `json:"ip_address"`
+	UserAgent    string           `json:"user_agent"`
+	Timestamp    time.Time        `json:"timestamp"`
+	PreviousRole *enums.UserRole  `json:"previous_role,omitempty"`
+	NewRole      *enums.UserRole  `json:"new_role,omitempty"`
+	ChangedBy    *string          `json:"changed_by,omitempty"`
+}
+
+// Origin identifies the client a request recorded in the UserHistory came from.
+type Origin struct {
+	IpAddress string
+	UserAgent string
+}
+
+// OriginFromRequest constructs the Origin of the request. The user agent is stripped of invalid UTF-8 and
+// truncated to whole characters, as the column counts characters rather than bytes.
+func OriginFromRequest(r *http.Request) Origin {
+	userAgent := strings.ToValidUTF8(r.UserAgent(), "")
+	if runes := []rune(userAgent); len(runes) > maxUserAgentLength {
+		userAgent = string(runes[:maxUserAgentLength])
+	}
+
+	return Origin{
+		IpAddress: utils.GetIpAddress(r),
+		UserAgent: userAgent,
+	}
 }
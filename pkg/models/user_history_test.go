@@ -0,0 +1,54 @@
+package models
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestOriginFromRequest(t *testing.T) {
+	tests := []struct {
+		name       string
+		remoteAddr string
+		userAgent  string
+		want       Origin
+	}{
+		{
+			name:       "should use the remote address and user agent",
+			remoteAddr: "203.0.113.7:52144",
+			userAgent:  "curl/8.5.0",
+			want:       Origin{IpAddress: "203.0.113.7", UserAgent: "curl/8.5.0"},
+		},
+		{
+			name:       "should truncate long user agents",
+			remoteAddr: "203.0.113.7:52144",
+			userAgent:  strings.Repeat("a", maxUserAgentLength+1),
+			want:       Origin{IpAddress: "203.0.113.7", UserAgent: strings.Repeat("a", maxUserAgentLength)},
+		},
+		{
+			name:       "should truncate long user agents on a character boundary",
+			remoteAddr: "203.0.113.7:52144",
+			userAgent:  "a" + strings.Repeat("é", maxUserAgentLength),
+			want:       Origin{IpAddress: "203.0.113.7", UserAgent: "a" + strings.Repeat("é", maxUserAgentLength-1)},
+		},
+		{
+			name:       "should strip invalid UTF-8",
+			remoteAddr: "203.0.113.7:52144",
+			userAgent:  "curl/8.5.0 \xff",
+			want:       Origin{IpAddress: "203.0.113.7", UserAgent: "curl/8.5.0 "},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := http.NewRequest(http.MethodGet, "/", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			r.RemoteAddr = tt.remoteAddr
+			r.Header.Set("User-Agent", tt.userAgent)
+
+			assert.Equal(t, tt.want, OriginFromRequest(r))
+		})
+	}
+}
@@ -0,0 +1,37 @@
+package models
+
+// UserInfo is the OpenID Connect UserInfo response, standard claims are sourced from the User and UserDetails.
+type UserInfo struct {
+	Sub               string `json:"sub"`
+	PreferredUsername string `json:"preferred_username,omitempty"`
+	Email             string `json:"email,omitempty"`
+	EmailVerified     *bool  `json:"email_verified,omitempty"`
+	Name              string `json:"name,omitempty"`
+	Picture           string `json:"picture,omitempty"`
+	Website           string `json:"website,omitempty"`
+}
+
+// UserInfo converts the User and their UserDetails to the UserInfo, details may be nil. Profile claims are only
+// included with the profile scope and email claims with the email scope.
+func (u *User) UserInfo(details *UserDetails, profile, email bool) *UserInfo {
+	info := &UserInfo{
+		Sub: u.AccountId.String(),
+	}
+
+	if profile {
+		info.PreferredUsername = u.Username
+		if details != nil {
+			info.Name = details.FullName
+			info.Picture = details.ProfilePicture
+			info.Website = details.WebsiteURL
+		}
+	}
+
+	if email {
+		verified := details != nil && details.Verified
+		info.Email = u.Email
+		info.EmailVerified = &verified
+	}
+
+	return info
+}
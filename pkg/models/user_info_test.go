@@ -0,0 +1,55 @@
+package models
+
+import (
+	"encoding/json"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestUser_UserInfo(t *testing.T) {
+	user := &User{AccountId: uuid.New(), Username: "user", Email: "user@knockbox.io"}
+	details := &UserDetails{FullName: "User", Verified: true}
+
+	tests := []struct {
+		name    string
+		profile bool
+		email   bool
+		present []string
+		absent  []string
+	}{
+		{
+			name:    "openid only",
+			present: []string{"sub"},
+			absent:  []string{"preferred_username", "name", "email", "email_verified"},
+		},
+		{
+			name:    "profile",
+			profile: true,
+			present: []string{"sub", "preferred_username", "name"},
+			absent:  []string{"email", "email_verified"},
+		},
+		{
+			name:    "email",
+			email:   true,
+			present: []string{"sub", "email", "email_verified"},
+			absent:  []string{"preferred_username", "name"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			raw, err := json.Marshal(user.UserInfo(details, tt.profile, tt.email))
+			assert.NoError(t, err)
+
+			claims := map[string]interface{}{}
+			assert.NoError(t, json.Unmarshal(raw, &claims))
+
+			for _, claim := range tt.present {
+				assert.Contains(t, claims, claim)
+			}
+			for _, claim := range tt.absent {
+				assert.NotContains(t, claims, claim)
+			}
+		})
+	}
+}
@@ -0,0 +1,180 @@
+package models
+
+import (
+	"github.com/google/uuid"
+	"github.com/knockbox/authentication/pkg/enums"
+	"github.com/knockbox/authentication/pkg/utils"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestUser_GrantScopes(t *testing.T) {
+	tests := []struct {
+		name      string
+		role      enums.UserRole
+		requested string
+		want      string
+		wantOk    bool
+	}{
+		{
+			name:      "user requesting nothing",
+			role:      enums.User,
+			requested: "",
+			want:      "openid profile email user:read user:write",
+			wantOk:    true,
+		},
+		{
+			name:      "admin requesting nothing",
+			role:      enums.Admin,
+			requested: "",
+			want:      "openid profile email user:read user:write admin:keys admin:clients",
+			wantOk:    true,
+		},
+		{
+			name:      "user narrowing",
+			role:      enums.User,
+			requested: "openid user:read",
+			want:      "openid user:read",
+			wantOk:    true,
+		},
+		{
+			name:      "user requesting admin scope",
+			role:      enums.User,
+			requested: "user:read admin:keys",
+			want:      "",
+			wantOk:    false,
+		},
+		{
+			name:      "banned requesting nothing",
+			role:      enums.Banned,
+			requested: "",
+			want:      "",
+			wantOk:    true,
+		},
+		{
+			name:      "unknown scope",
+			role:      enums.Developer,
+			requested: "everything",
+			want:      "",
+			wantOk:    false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := (&User{Role: tt.role}).GrantScopes(tt.requested)
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.wantOk, ok)
+		})
+	}
+}
+
+func TestUser_DTO(t *testing.T) {
+	user := &User{Id: 7, AccountId: uuid.New(), Username: "user", Email: "user@knockbox.io", Role: enums.Locked}
+	read := []enums.Scope{enums.ScopeUserRead}
+
+	tests := []struct {
+		name    string
+		p       *Principal
+		email   bool
+		status  bool
+		private bool
+	}{
+		{
+			name: "anonymous",
+			p:    nil,
+		},
+		{
+			name: "another user",
+			p:    &Principal{Subject: uuid.NewString(), Role: enums.User, Scopes: read},
+		},
+		{
+			name:  "owner",
+			p:     &Principal{Subject: user.AccountId.String(), Role: enums.User, Scopes: read},
+			email: true,
+		},
+		{
+			name: "owner without user:read",
+			p:    &Principal{Subject: user.AccountId.String(), Role: enums.User},
+		},
+		{
+			name:   "moderator",
+			p:      &Principal{Subject: uuid.NewString(), Role: enums.Moderator, Scopes: read},
+			email:  true,
+			status: true,
+		},
+		{
+			name:    "admin",
+			p:       &Principal{Subject: uuid.NewString(), Role: enums.Admin, Scopes: read},
+			email:   true,
+			status:  true,
+			private: true,
+		},
+		{
+			name:    "developer",
+			p:       &Principal{Subject: uuid.NewString(), Role: enums.Developer, Scopes: read},
+			email:   true,
+			status:  true,
+			private: true,
+		},
+		{
+			name: "service client",
+			p:    &Principal{Subject: "service", ClientId: "service", Scopes: read},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dto := user.DTO(tt.p)
+
+			assert.Equal(t, user.AccountId, dto.AccountId)
+			assert.Equal(t, user.Username, dto.Username)
+			assert.Equal(t, tt.email, dto.Email != nil)
+			assert.Equal(t, tt.status, dto.Status != nil)
+			assert.Equal(t, tt.private, dto.Id != nil)
+			assert.Equal(t, tt.private, dto.HistoryURL != nil)
+
+			if tt.status {
+				assert.Equal(t, "locked", *dto.Status)
+			}
+		})
+	}
+}
+
+func TestUser_CreateToken(t *testing.T) {
+	config := &utils.TokenConfig{Issuer: "https://auth.knockbox.io", Audience: []string{"knockbox"}}
+	user := &User{AccountId: uuid.New(), Username: "user", Role: enums.User}
+
+	tests := []struct {
+		name string
+		amr  string
+		want interface{}
+	}{
+		{
+			name: "should omit amr when unknown",
+			amr:  "",
+			want: nil,
+		},
+		{
+			name: "should list the password",
+			amr:  "pwd",
+			want: []string{"pwd"},
+		},
+		{
+			name: "should list every method",
+			amr:  "pwd otp mfa",
+			want: []string{"pwd", "otp", "mfa"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, err := user.CreateToken(config, time.Minute, "user:read", tt.amr)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			amr, _ := token.Get("amr")
+			assert.EqualValues(t, tt.want, amr)
+		})
+	}
+}
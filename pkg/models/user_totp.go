@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// UserTOTP defines the TOTP authenticator of a User in our database. The Secret is sealed by the keyring.Sealer,
+// LastStep is the time step of the last accepted code so a code cannot be used twice.
+type UserTOTP struct {
+	Id          uint       `db:"id"`
+	UserId      uint       `db:"user_id"`
+	Secret      []byte     `db:"secret"`
+	LastStep    int64      `db:"last_step"`
+	CreatedAt   time.Time  `db:"created_at"`
+	ConfirmedAt *time.Time `db:"confirmed_at"`
+}
+
+// IsConfirmed determines if the User proved they enrolled the authenticator, only then is it required to sign in.
+func (t *UserTOTP) IsConfirmed() bool {
+	return t.ConfirmedAt != nil
+}
+
+// RecoveryCode defines a single use code a User may sign in with in place of their TOTP. Only the hash of the
+// code is stored.
+type RecoveryCode struct {
+	Id       uint       `db:"id"`
+	UserId   uint       `db:"user_id"`
+	CodeHash string     `db:"code_hash"`
+	UsedAt   *time.Time `db:"used_at"`
+}
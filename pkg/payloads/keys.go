@@ -0,0 +1,5 @@
+package payloads
+
+type KeyPoolUpdate struct {
+	Size int `json:"size" validate:"required,gte=1,lte=16"`
+}
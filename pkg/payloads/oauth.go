@@ -0,0 +1,9 @@
+package payloads
+
+type OAuthClientRegister struct {
+	Name         string   `json:"name" validate:"required,lte=255"`
+	RedirectURIs []string `json:"redirect_uris" validate:"required_if=Confidential false,dive,url"`
+	Scopes       []string `json:"scopes" validate:"dive,required,excludesall= "`
+	Confidential bool     `json:"confidential"`
+	FirstParty   bool     `json:"first_party"`
+}
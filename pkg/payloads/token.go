@@ -0,0 +1,5 @@
+package payloads
+
+type TokenRefresh struct {
+	RefreshToken string `json:"refresh_token" validate:"required"`
+}
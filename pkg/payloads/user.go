@@ -1,6 +1,9 @@
 package payloads
 
-import "github.com/knockbox/authentication/pkg/enums"
+import (
+	"encoding/json"
+	"github.com/knockbox/authentication/pkg/enums"
+)
 
 type UserRegister struct {
 	Username string `json:"username" validate:"required,gte=2,lte=16"`
@@ -11,10 +14,64 @@ type UserRegister struct {
 type UserLogin struct {
 	Username string `json:"username" validate:"required"`
 	Password string `json:"password" validate:"required"`
+	Scope    string `json:"scope,omitempty"`
 }
 
 type UserUpdate struct {
-	Email    *string         `json:"email,omitempty" validate:"omitempty,email"`
-	Password *string         `json:"password,omitempty" validate:"omitempty,gte=12,lte=32"`
-	Role     *enums.UserRole `json:"role,omitempty" validate:"omitempty,gte=0,lte=6"`
+	Email           *string `json:"email,omitempty" validate:"omitempty,email"`
+	Password        *string `json:"password,omitempty" validate:"omitempty,gte=12,lte=32"`
+	CurrentPassword *string `json:"current_password,omitempty" validate:"required_with=Email Password"`
+}
+
+type UserRoleUpdate struct {
+	Role enums.UserRole `json:"role" validate:"required,oneof=banned locked pending user moderator admin developer"`
+}
+
+type EmailVerification struct {
+	Token string `json:"token" validate:"required"`
+}
+
+type EmailVerificationResend struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+type PasswordForgot struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+type PasswordReset struct {
+	Token    string `json:"token" validate:"required"`
+	Password string `json:"password" validate:"required,gte=12,lte=32"`
+}
+
+type UserLoginMFA struct {
+	MFAToken     string `json:"mfa_token" validate:"required"`
+	Code         string `json:"code,omitempty" validate:"required_without=RecoveryCode,omitempty,numeric,len=6"`
+	RecoveryCode string `json:"recovery_code,omitempty" validate:"required_without=Code,omitempty,lte=32"`
+}
+
+type TOTPConfirm struct {
+	CurrentPassword string `json:"current_password" validate:"required"`
+	Code            string `json:"code" validate:"required,numeric,len=6"`
+}
+
+type CurrentPassword struct {
+	CurrentPassword string `json:"current_password" validate:"required"`
+}
+
+type PasskeyRegistrationOptions struct {
+	CurrentPassword string `json:"current_password" validate:"required"`
+	Code            string `json:"code" validate:"omitempty,numeric,len=6"`
+}
+
+type PasskeyRegister struct {
+	SessionToken string          `json:"session_token" validate:"required"`
+	Name         string          `json:"name" validate:"lte=64"`
+	Credential   json.RawMessage `json:"credential" validate:"required"`
+}
+
+type PasskeyLogin struct {
+	SessionToken string          `json:"session_token" validate:"required"`
+	Credential   json.RawMessage `json:"credential" validate:"required"`
+	Scope        string          `json:"scope,omitempty"`
 }
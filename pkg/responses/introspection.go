@@ -0,0 +1,32 @@
+package responses
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// Introspection is the RFC 7662 token introspection response. Only Active is present for inactive tokens.
+type Introspection struct {
+	Active    bool     `json:"active"`
+	Scope     string   `json:"scope,omitempty"`
+	ClientId  string   `json:"client_id,omitempty"`
+	Username  string   `json:"username,omitempty"`
+	TokenType string   `json:"token_type,omitempty"`
+	Exp       int64    `json:"exp,omitempty"`
+	Iat       int64    `json:"iat,omitempty"`
+	Nbf       int64    `json:"nbf,omitempty"`
+	Sub       string   `json:"sub,omitempty"`
+	Aud       []string `json:"aud,omitempty"`
+	Iss       string   `json:"iss,omitempty"`
+	Jti       string   `json:"jti,omitempty"`
+	Role      string   `json:"role,omitempty"`
+}
+
+func NewInactiveIntrospection() *Introspection {
+	return &Introspection{Active: false}
+}
+
+func (i *Introspection) Encode(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(i)
+}
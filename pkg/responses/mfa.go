@@ -0,0 +1,48 @@
+package responses
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// MFAChallenge is returned by a login whose password was accepted when the user must also give a second factor.
+// The MFAToken is exchanged along with the second factor at /api/login/mfa.
+type MFAChallenge struct {
+	MFARequired bool   `json:"mfa_required"`
+	MFAToken    string `json:"mfa_token"`
+	ExpiresIn   int    `json:"expires_in"`
+}
+
+func NewMFAChallenge(mfaToken []byte, expiresInSeconds int) *MFAChallenge {
+	return &MFAChallenge{
+		MFARequired: true,
+		MFAToken:    string(mfaToken),
+		ExpiresIn:   expiresInSeconds,
+	}
+}
+
+func (c *MFAChallenge) Encode(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(c)
+}
+
+// TOTPEnrollment is the secret of a new authenticator, and the otpauth URI to show it as a QR code.
+type TOTPEnrollment struct {
+	Secret     string `json:"secret"`
+	OTPAuthURI string `json:"otpauth_uri"`
+}
+
+func (e *TOTPEnrollment) Encode(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(e)
+}
+
+// RecoveryCodes are the single use codes that stand in for the authenticator, shown only once.
+type RecoveryCodes struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+func (c *RecoveryCodes) Encode(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(c)
+}
@@ -0,0 +1,20 @@
+package responses
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// OAuthError is the error response of the token endpoint as defined by RFC 6749 section 5.2.
+type OAuthError struct {
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description,omitempty"`
+}
+
+func NewOAuthError(code, description string) *OAuthError {
+	return &OAuthError{Error: code, ErrorDescription: description}
+}
+
+func (e *OAuthError) Encode(w http.ResponseWriter) {
+	_ = json.NewEncoder(w).Encode(e)
+}
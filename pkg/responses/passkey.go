@@ -0,0 +1,25 @@
+package responses
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// WebAuthnCeremony holds the options to pass to navigator.credentials, and the token to send back along with the
+// credential it returns.
+type WebAuthnCeremony struct {
+	Options      interface{} `json:"options"`
+	SessionToken string      `json:"session_token"`
+}
+
+func NewWebAuthnCeremony(options interface{}, sessionToken []byte) *WebAuthnCeremony {
+	return &WebAuthnCeremony{
+		Options:      options,
+		SessionToken: string(sessionToken),
+	}
+}
+
+func (c *WebAuthnCeremony) Encode(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(c)
+}
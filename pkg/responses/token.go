@@ -6,9 +6,12 @@ import (
 )
 
 type Token struct {
-	AccessToken string `json:"access_token"`
-	TokenType   string `json:"token_type"`
-	ExpiresIn   int    `json:"expires_in"`
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	Scope        string `json:"scope,omitempty"`
+	IdToken      string `json:"id_token,omitempty"`
 }
 
 func NewBearerToken(accessToken []byte, expiresInSeconds int) *Token {
@@ -19,6 +22,24 @@ func NewBearerToken(accessToken []byte, expiresInSeconds int) *Token {
 	}
 }
 
+// WithRefreshToken sets the refresh token issued alongside the access token.
+func (t *Token) WithRefreshToken(refreshToken string) *Token {
+	t.RefreshToken = refreshToken
+	return t
+}
+
+// WithScope sets the scope granted to the access token.
+func (t *Token) WithScope(scope string) *Token {
+	t.Scope = scope
+	return t
+}
+
+// WithIdToken sets the OpenID Connect ID token issued alongside the access token.
+func (t *Token) WithIdToken(idToken []byte) *Token {
+	t.IdToken = string(idToken)
+	return t
+}
+
 func (t *Token) Encode(w http.ResponseWriter) {
 	w.Header().Set("Content-Type", "application/json")
 	_ = json.NewEncoder(w).Encode(t)
@@ -0,0 +1,82 @@
+package totp
+
+import (
+	"encoding/base32"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+// rfcSecret is the SHA-1 secret of the RFC 6238 test vectors.
+var rfcSecret = base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+
+func TestGenerateCode(t *testing.T) {
+	// The RFC 6238 vectors are 8 digits, these are their last 6.
+	tests := []struct {
+		name string
+		time int64
+		want string
+	}{
+		{name: "59", time: 59, want: "287082"},
+		{name: "1111111109", time: 1111111109, want: "081804"},
+		{name: "1111111111", time: 1111111111, want: "050471"},
+		{name: "1234567890", time: 1234567890, want: "005924"},
+		{name: "2000000000", time: 2000000000, want: "279037"},
+		{name: "20000000000", time: 20000000000, want: "353130"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := GenerateCode(rfcSecret, Step(time.Unix(tt.time, 0)))
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	now := time.Unix(1111111109, 0)
+	current := Step(now)
+
+	code := func(step int64) string {
+		c, err := GenerateCode(rfcSecret, step)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+
+	tests := []struct {
+		name string
+		code string
+		step int64
+		ok   bool
+	}{
+		{name: "current step", code: code(current), step: current, ok: true},
+		{name: "previous step", code: code(current - 1), step: current - 1, ok: true},
+		{name: "next step", code: code(current + 1), step: current + 1, ok: true},
+		{name: "outside skew", code: code(current - 2), ok: false},
+		{name: "wrong length", code: "12345", ok: false},
+		{name: "empty", code: "", ok: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			step, ok := Validate(rfcSecret, tt.code, now, 1)
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.step, step)
+		})
+	}
+}
+
+func TestGenerateSecret(t *testing.T) {
+	secret, err := GenerateSecret()
+	assert.NoError(t, err)
+	assert.Len(t, secret, 32)
+
+	_, err = GenerateCode(secret, 1)
+	assert.NoError(t, err)
+}
+
+func TestURI(t *testing.T) {
+	got := URI("knockbox", "user@knockbox.io", "JBSWY3DPEHPK3PXP")
+	assert.Equal(t, "otpauth://totp/knockbox:user@knockbox.io?algorithm=SHA1&digits=6&issuer=knockbox&period=30&secret=JBSWY3DPEHPK3PXP", got)
+}
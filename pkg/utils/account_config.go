@@ -0,0 +1,88 @@
+package utils
+
+import (
+	"net/url"
+	"os"
+	"time"
+)
+
+// AccountConfig holds the settings of the links emailed to account holders.
+type AccountConfig struct {
+	VerificationURL       string
+	VerificationLifespan  time.Duration
+	ResendInterval        time.Duration
+	PasswordResetURL      string
+	PasswordResetLifespan time.Duration
+	TOTPIssuer            string
+	MFATokenLifespan      time.Duration
+}
+
+// AccountConfigFromEnv constructs the AccountConfig from environment variables. EMAIL_VERIFICATION_URL and
+// PASSWORD_RESET_URL are the pages the links point at, EMAIL_VERIFICATION_LIFESPAN,
+// MAIL_RESEND_INTERVAL, PASSWORD_RESET_LIFESPAN and MFA_TOKEN_LIFESPAN are time.Duration strings. TOTP_ISSUER
+// names the service in authenticator apps. Missing or malformed values use defaults.
+func AccountConfigFromEnv() *AccountConfig {
+	config := &AccountConfig{
+		VerificationURL:       "http://localhost:9090/api/verify-email",
+		VerificationLifespan:  24 * time.Hour,
+		ResendInterval:        5 * time.Minute,
+		PasswordResetURL:      "http://localhost:3000/password/reset",
+		PasswordResetLifespan: time.Hour,
+		TOTPIssuer:            "knockbox",
+		MFATokenLifespan:      5 * time.Minute,
+	}
+
+	if link := os.Getenv("EMAIL_VERIFICATION_URL"); link != "" {
+		config.VerificationURL = link
+	}
+
+	if lifespan, err := time.ParseDuration(os.Getenv("EMAIL_VERIFICATION_LIFESPAN")); err == nil && lifespan > 0 {
+		config.VerificationLifespan = lifespan
+	}
+
+	if interval, err := time.ParseDuration(os.Getenv("MAIL_RESEND_INTERVAL")); err == nil && interval >= 0 {
+		config.ResendInterval = interval
+	}
+
+	if link := os.Getenv("PASSWORD_RESET_URL"); link != "" {
+		config.PasswordResetURL = link
+	}
+
+	if lifespan, err := time.ParseDuration(os.Getenv("PASSWORD_RESET_LIFESPAN")); err == nil && lifespan > 0 {
+		config.PasswordResetLifespan = lifespan
+	}
+
+	if issuer := os.Getenv("TOTP_ISSUER"); issuer != "" {
+		config.TOTPIssuer = issuer
+	}
+
+	if lifespan, err := time.ParseDuration(os.Getenv("MFA_TOKEN_LIFESPAN")); err == nil && lifespan > 0 {
+		config.MFATokenLifespan = lifespan
+	}
+
+	return config
+}
+
+// VerificationLink returns the VerificationURL with the token added to its query.
+func (c *AccountConfig) VerificationLink(token string) (string, error) {
+	return withToken(c.VerificationURL, token)
+}
+
+// PasswordResetLink returns the PasswordResetURL with the token added to its query.
+func (c *AccountConfig) PasswordResetLink(token string) (string, error) {
+	return withToken(c.PasswordResetURL, token)
+}
+
+// withToken adds the token to the query of the link, keeping any existing parameters.
+func withToken(link, token string) (string, error) {
+	u, err := url.Parse(link)
+	if err != nil {
+		return "", err
+	}
+
+	query := u.Query()
+	query.Set("token", token)
+	u.RawQuery = query.Encode()
+
+	return u.String(), nil
+}
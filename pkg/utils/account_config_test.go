@@ -0,0 +1,112 @@
+package utils
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestAccountConfigFromEnv(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+		want *AccountConfig
+	}{
+		{
+			name: "should use defaults",
+			env:  map[string]string{},
+			want: &AccountConfig{
+				VerificationURL:       "http://localhost:9090/api/verify-email",
+				VerificationLifespan:  24 * time.Hour,
+				ResendInterval:        5 * time.Minute,
+				PasswordResetURL:      "http://localhost:3000/password/reset",
+				PasswordResetLifespan: time.Hour,
+				TOTPIssuer:            "knockbox",
+				MFATokenLifespan:      5 * time.Minute,
+			},
+		},
+		{
+			name: "should read environment",
+			env: map[string]string{
+				"EMAIL_VERIFICATION_URL":      "https://knockbox.io/verify",
+				"EMAIL_VERIFICATION_LIFESPAN": "1h",
+				"MAIL_RESEND_INTERVAL":        "30s",
+				"PASSWORD_RESET_URL":          "https://knockbox.io/reset",
+				"PASSWORD_RESET_LIFESPAN":     "15m",
+				"TOTP_ISSUER":                 "Knockbox",
+				"MFA_TOKEN_LIFESPAN":          "2m",
+			},
+			want: &AccountConfig{
+				VerificationURL:       "https://knockbox.io/verify",
+				VerificationLifespan:  time.Hour,
+				ResendInterval:        30 * time.Second,
+				PasswordResetURL:      "https://knockbox.io/reset",
+				PasswordResetLifespan: 15 * time.Minute,
+				TOTPIssuer:            "Knockbox",
+				MFATokenLifespan:      2 * time.Minute,
+			},
+		},
+		{
+			name: "should ignore malformed durations",
+			env: map[string]string{
+				"EMAIL_VERIFICATION_LIFESPAN": "-1h",
+				"MAIL_RESEND_INTERVAL":        "soon",
+			},
+			want: &AccountConfig{
+				VerificationURL:       "http://localhost:9090/api/verify-email",
+				VerificationLifespan:  24 * time.Hour,
+				ResendInterval:        5 * time.Minute,
+				PasswordResetURL:      "http://localhost:3000/password/reset",
+				PasswordResetLifespan: time.Hour,
+				TOTPIssuer:            "knockbox",
+				MFATokenLifespan:      5 * time.Minute,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, key := range []string{
+				"EMAIL_VERIFICATION_URL",
+				"EMAIL_VERIFICATION_LIFESPAN",
+				"MAIL_RESEND_INTERVAL",
+				"PASSWORD_RESET_URL",
+				"PASSWORD_RESET_LIFESPAN",
+				"TOTP_ISSUER",
+				"MFA_TOKEN_LIFESPAN",
+			} {
+				t.Setenv(key, tt.env[key])
+			}
+
+			assert.Equal(t, tt.want, AccountConfigFromEnv())
+		})
+	}
+}
+
+func TestAccountConfig_VerificationLink(t *testing.T) {
+	tests := []struct {
+		name  string
+		url   string
+		token string
+		want  string
+	}{
+		{
+			name:  "should add the token",
+			url:   "http://localhost:9090/api/verify-email",
+			token: "abc",
+			want:  "http://localhost:9090/api/verify-email?token=abc",
+		},
+		{
+			name:  "should keep existing parameters",
+			url:   "https://knockbox.io/verify?lang=en",
+			token: "a.b+c",
+			want:  "https://knockbox.io/verify?lang=en&token=a.b%2Bc",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := (&AccountConfig{VerificationURL: tt.url}).VerificationLink(tt.token)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
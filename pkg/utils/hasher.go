@@ -1,6 +1,10 @@
 package utils
 
-import "golang.org/x/crypto/bcrypt"
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"golang.org/x/crypto/bcrypt"
+)
 
 // NormalizePassword converts the password to a byte slice.
 func NormalizePassword(password string) []byte {
@@ -26,3 +30,10 @@ func ComparePasswords(hashedPassword, unhashedPassword string) bool {
 
 	return bcrypt.CompareHashAndPassword(hashedBytes, unhashedBytes) == nil
 }
+
+// HashToken func for hashing opaque tokens before they are stored. Opaque tokens are high entropy so a fast
+// hash is sufficient, unlike passwords.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
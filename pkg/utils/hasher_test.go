@@ -92,3 +92,24 @@ func TestNormalizePassword(t *testing.T) {
 		})
 	}
 }
+
+func TestHashToken(t *testing.T) {
+	tests := []struct {
+		name  string
+		token string
+		want  string
+	}{
+		{
+			name:  "should hash token",
+			token: "hello",
+			want:  "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := HashToken(tt.token); got != tt.want {
+				t.Errorf("HashToken() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,16 @@
+package utils
+
+import (
+	"net"
+	"net/http"
+)
+
+// GetIpAddress returns the address of the remote end of the request without its port.
+func GetIpAddress(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
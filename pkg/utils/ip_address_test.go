@@ -0,0 +1,37 @@
+package utils
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+)
+
+func TestGetIpAddress(t *testing.T) {
+	tests := []struct {
+		name       string
+		remoteAddr string
+		want       string
+	}{
+		{
+			name:       "ipv4 with port",
+			remoteAddr: "192.0.2.1:52000",
+			want:       "192.0.2.1",
+		},
+		{
+			name:       "ipv6 with port",
+			remoteAddr: "[2001:db8::1]:52000",
+			want:       "2001:db8::1",
+		},
+		{
+			name:       "without port",
+			remoteAddr: "192.0.2.1",
+			want:       "192.0.2.1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, GetIpAddress(&http.Request{RemoteAddr: tt.remoteAddr}))
+		})
+	}
+}
@@ -0,0 +1,58 @@
+package utils
+
+import (
+	"os"
+	"strconv"
+	"time"
+)
+
+// LockoutConfig holds how failed logins are throttled, per account and per IP address.
+type LockoutConfig struct {
+	AccountThreshold uint
+	IpThreshold      uint
+	Backoff          time.Duration
+	Duration         time.Duration
+	MaxLockouts      uint
+	Window           time.Duration
+}
+
+// LockoutConfigFromEnv constructs the LockoutConfig from environment variables. LOCKOUT_ACCOUNT_THRESHOLD and
+// LOCKOUT_IP_THRESHOLD are how many failures lock an account or IP address, LOCKOUT_MAX_LOCKOUTS is how many
+// locks in a row lock an account until an admin unlocks it, 0 never does. LOCKOUT_BACKOFF, LOCKOUT_DURATION and
+// LOCKOUT_WINDOW are time.Duration strings. Missing or malformed values use defaults.
+func LockoutConfigFromEnv() *LockoutConfig {
+	config := &LockoutConfig{
+		AccountThreshold: 5,
+		IpThreshold:      20,
+		Backoff:          time.Second,
+		Duration:         15 * time.Minute,
+		MaxLockouts:      3,
+		Window:           24 * time.Hour,
+	}
+
+	if n, err := strconv.ParseUint(os.Getenv("LOCKOUT_ACCOUNT_THRESHOLD"), 10, 32); err == nil && n > 0 {
+		config.AccountThreshold = uint(n)
+	}
+
+	if n, err := strconv.ParseUint(os.Getenv("LOCKOUT_IP_THRESHOLD"), 10, 32); err == nil && n > 0 {
+		config.IpThreshold = uint(n)
+	}
+
+	if backoff, err := time.ParseDuration(os.Getenv("LOCKOUT_BACKOFF")); err == nil && backoff >= 0 {
+		config.Backoff = backoff
+	}
+
+	if duration, err := time.ParseDuration(os.Getenv("LOCKOUT_DURATION")); err == nil && duration > 0 {
+		config.Duration = duration
+	}
+
+	if n, err := strconv.ParseUint(os.Getenv("LOCKOUT_MAX_LOCKOUTS"), 10, 32); err == nil {
+		config.MaxLockouts = uint(n)
+	}
+
+	if window, err := time.ParseDuration(os.Getenv("LOCKOUT_WINDOW")); err == nil && window > 0 {
+		config.Window = window
+	}
+
+	return config
+}
@@ -0,0 +1,80 @@
+package utils
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestLockoutConfigFromEnv(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+		want *LockoutConfig
+	}{
+		{
+			name: "should use defaults",
+			env:  map[string]string{},
+			want: &LockoutConfig{
+				AccountThreshold: 5,
+				IpThreshold:      20,
+				Backoff:          time.Second,
+				Duration:         15 * time.Minute,
+				MaxLockouts:      3,
+				Window:           24 * time.Hour,
+			},
+		},
+		{
+			name: "should read environment",
+			env: map[string]string{
+				"LOCKOUT_ACCOUNT_THRESHOLD": "10",
+				"LOCKOUT_IP_THRESHOLD":      "50",
+				"LOCKOUT_BACKOFF":           "500ms",
+				"LOCKOUT_DURATION":          "1h",
+				"LOCKOUT_MAX_LOCKOUTS":      "0",
+				"LOCKOUT_WINDOW":            "48h",
+			},
+			want: &LockoutConfig{
+				AccountThreshold: 10,
+				IpThreshold:      50,
+				Backoff:          500 * time.Millisecond,
+				Duration:         time.Hour,
+				MaxLockouts:      0,
+				Window:           48 * time.Hour,
+			},
+		},
+		{
+			name: "should ignore malformed values",
+			env: map[string]string{
+				"LOCKOUT_ACCOUNT_THRESHOLD": "0",
+				"LOCKOUT_IP_THRESHOLD":      "-1",
+				"LOCKOUT_DURATION":          "forever",
+				"LOCKOUT_MAX_LOCKOUTS":      "some",
+			},
+			want: &LockoutConfig{
+				AccountThreshold: 5,
+				IpThreshold:      20,
+				Backoff:          time.Second,
+				Duration:         15 * time.Minute,
+				MaxLockouts:      3,
+				Window:           24 * time.Hour,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, key := range []string{
+				"LOCKOUT_ACCOUNT_THRESHOLD",
+				"LOCKOUT_IP_THRESHOLD",
+				"LOCKOUT_BACKOFF",
+				"LOCKOUT_DURATION",
+				"LOCKOUT_MAX_LOCKOUTS",
+				"LOCKOUT_WINDOW",
+			} {
+				t.Setenv(key, tt.env[key])
+			}
+
+			assert.Equal(t, tt.want, LockoutConfigFromEnv())
+		})
+	}
+}
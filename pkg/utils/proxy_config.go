@@ -0,0 +1,138 @@
+package utils
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// ProxyConfig holds the load balancers and proxies trusted to name the client of a request, and the header they
+// name it in.
+type ProxyConfig struct {
+	Trusted []*net.IPNet
+	Header  string
+}
+
+// ProxyConfigFromEnv constructs the ProxyConfig from environment variables. TRUSTED_PROXIES is a comma separated
+// list of addresses or CIDR ranges, without it no proxy is trusted. TRUSTED_PROXY_HEADER is X-Forwarded-For, the
+// default, or Forwarded. Only the header the proxies set can be read, a client could send the other.
+func ProxyConfigFromEnv() (*ProxyConfig, error) {
+	config := &ProxyConfig{Header: "X-Forwarded-For"}
+
+	if header := os.Getenv("TRUSTED_PROXY_HEADER"); header != "" {
+		header = http.CanonicalHeaderKey(header)
+		if header != "X-Forwarded-For" && header != "Forwarded" {
+			return nil, fmt.Errorf("TRUSTED_PROXY_HEADER: %q is neither X-Forwarded-For nor Forwarded", header)
+		}
+
+		config.Header = header
+	}
+
+	raw := os.Getenv("TRUSTED_PROXIES")
+	if raw == "" {
+		return config, nil
+	}
+
+	for _, entry := range strings.Split(raw, ",") {
+		entry = strings.TrimSpace(entry)
+		if !strings.Contains(entry, "/") {
+			ip := net.ParseIP(entry)
+			if ip == nil {
+				return nil, fmt.Errorf("TRUSTED_PROXIES: %q is not an address or CIDR range", entry)
+			}
+
+			if ip4 := ip.To4(); ip4 != nil {
+				ip = ip4
+			}
+
+			bits := 8 * len(ip)
+			config.Trusted = append(config.Trusted, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+
+		_, network, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, fmt.Errorf("TRUSTED_PROXIES: %w", err)
+		}
+
+		config.Trusted = append(config.Trusted, network)
+	}
+
+	return config, nil
+}
+
+// ClientIpAddress returns the address of the client of the request. A request from a trusted proxy is followed
+// back through the addresses it was forwarded for to the first that is not a trusted proxy. Addresses before it
+// were given by the client, which can claim any it likes.
+func (c *ProxyConfig) ClientIpAddress(r *http.Request) string {
+	addr := GetIpAddress(r)
+	if !c.isTrusted(addr) {
+		return addr
+	}
+
+	hops := c.forwardedFor(r.Header)
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := stripPort(hops[i])
+		if net.ParseIP(hop) == nil {
+			break
+		}
+
+		addr = hop
+		if !c.isTrusted(addr) {
+			break
+		}
+	}
+
+	return addr
+}
+
+// isTrusted reports whether the address is within one of the trusted ranges.
+func (c *ProxyConfig) isTrusted(addr string) bool {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return false
+	}
+
+	for _, network := range c.Trusted {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// forwardedFor returns the addresses in the header the request was forwarded for, the client first and the last
+// proxy last.
+func (c *ProxyConfig) forwardedFor(header http.Header) []string {
+	var hops []string
+	for _, value := range header.Values(c.Header) {
+		for _, element := range strings.Split(value, ",") {
+			if c.Header == "X-Forwarded-For" {
+				hops = append(hops, strings.TrimSpace(element))
+				continue
+			}
+
+			// Forwarded (RFC 7239) names the address in the for parameter of each element.
+			for _, pair := range strings.Split(element, ";") {
+				name, param, ok := strings.Cut(strings.TrimSpace(pair), "=")
+				if ok && strings.EqualFold(name, "for") {
+					hops = append(hops, strings.Trim(param, `"`))
+				}
+			}
+		}
+	}
+
+	return hops
+}
+
+// stripPort removes the port, and the brackets around an IPv6 address, from a forwarded address.
+func stripPort(addr string) string {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+
+	return strings.TrimSuffix(strings.TrimPrefix(addr, "["), "]")
+}
@@ -0,0 +1,145 @@
+package utils
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net"
+	"net/http"
+	"testing"
+)
+
+func TestProxyConfigFromEnv(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		want    *ProxyConfig
+		wantErr bool
+	}{
+		{
+			name: "should trust no proxy by default",
+			env:  map[string]string{},
+			want: &ProxyConfig{Header: "X-Forwarded-For"},
+		},
+		{
+			name: "should read addresses and ranges",
+			env: map[string]string{
+				"TRUSTED_PROXIES":      "10.0.0.0/8, 192.0.2.1,2001:db8::1",
+				"TRUSTED_PROXY_HEADER": "forwarded",
+			},
+			want: &ProxyConfig{
+				Trusted: []*net.IPNet{
+					{IP: net.IP{10, 0, 0, 0}, Mask: net.CIDRMask(8, 32)},
+					{IP: net.IP{192, 0, 2, 1}, Mask: net.CIDRMask(32, 32)},
+					{IP: net.ParseIP("2001:db8::1"), Mask: net.CIDRMask(128, 128)},
+				},
+				Header: "Forwarded",
+			},
+		},
+		{
+			name:    "should refuse a malformed range",
+			env:     map[string]string{"TRUSTED_PROXIES": "10.0.0.0/33"},
+			wantErr: true,
+		},
+		{
+			name:    "should refuse a malformed address",
+			env:     map[string]string{"TRUSTED_PROXIES": "load-balancer"},
+			wantErr: true,
+		},
+		{
+			name:    "should refuse another header",
+			env:     map[string]string{"TRUSTED_PROXY_HEADER": "X-Real-IP"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("TRUSTED_PROXIES", tt.env["TRUSTED_PROXIES"])
+			t.Setenv("TRUSTED_PROXY_HEADER", tt.env["TRUSTED_PROXY_HEADER"])
+
+			got, err := ProxyConfigFromEnv()
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestProxyConfig_ClientIpAddress(t *testing.T) {
+	_, balancers, _ := net.ParseCIDR("10.0.0.0/8")
+	xff := &ProxyConfig{Trusted: []*net.IPNet{balancers}, Header: "X-Forwarded-For"}
+	forwarded := &ProxyConfig{Trusted: []*net.IPNet{balancers}, Header: "Forwarded"}
+
+	tests := []struct {
+		name       string
+		config     *ProxyConfig
+		remoteAddr string
+		header     http.Header
+		want       string
+	}{
+		{
+			name:       "direct request",
+			config:     xff,
+			remoteAddr: "192.0.2.1:52000",
+			want:       "192.0.2.1",
+		},
+		{
+			name:       "forwarded header from an untrusted peer",
+			config:     xff,
+			remoteAddr: "192.0.2.1:52000",
+			header:     http.Header{"X-Forwarded-For": {"198.51.100.7"}},
+			want:       "192.0.2.1",
+		},
+		{
+			name:       "client behind the load balancer",
+			config:     xff,
+			remoteAddr: "10.0.0.2:52000",
+			header:     http.Header{"X-Forwarded-For": {"198.51.100.7"}},
+			want:       "198.51.100.7",
+		},
+		{
+			name:       "client claiming another address",
+			config:     xff,
+			remoteAddr: "10.0.0.2:52000",
+			header:     http.Header{"X-Forwarded-For": {"203.0.113.9, 198.51.100.7"}},
+			want:       "198.51.100.7",
+		},
+		{
+			name:       "client behind two trusted proxies",
+			config:     xff,
+			remoteAddr: "10.0.0.2:52000",
+			header:     http.Header{"X-Forwarded-For": {"198.51.100.7", "10.0.0.3"}},
+			want:       "198.51.100.7",
+		},
+		{
+			name:       "malformed address before the load balancer",
+			config:     xff,
+			remoteAddr: "10.0.0.2:52000",
+			header:     http.Header{"X-Forwarded-For": {"unknown"}},
+			want:       "10.0.0.2",
+		},
+		{
+			name:       "rfc 7239 forwarded header",
+			config:     forwarded,
+			remoteAddr: "10.0.0.2:52000",
+			header:     http.Header{"Forwarded": {`for=203.0.113.9, for="[2001:db8::7]:4711";proto=https`}},
+			want:       "2001:db8::7",
+		},
+		{
+			name:       "header the proxies do not set",
+			config:     forwarded,
+			remoteAddr: "10.0.0.2:52000",
+			header:     http.Header{"X-Forwarded-For": {"198.51.100.7"}},
+			want:       "10.0.0.2",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &http.Request{RemoteAddr: tt.remoteAddr, Header: tt.header}
+			assert.Equal(t, tt.want, tt.config.ClientIpAddress(r))
+		})
+	}
+}
@@ -2,7 +2,10 @@ package utils
 
 import (
 	"crypto/rand"
+	"encoding/base32"
+	"encoding/base64"
 	"math/big"
+	"strings"
 )
 
 // CryptoRandom returns an int in the range [0, max)
@@ -14,3 +17,25 @@ func CryptoRandom(max int) (int64, error) {
 
 	return bigInt.Int64(), nil
 }
+
+// GenerateOpaqueToken returns n random bytes encoded as unpadded base64url, for use as an opaque token.
+func GenerateOpaqueToken(n int) (string, error) {
+	bs := make([]byte, n)
+	if _, err := rand.Read(bs); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(bs), nil
+}
+
+// GenerateRecoveryCode returns 40 random bits as 8 lowercase base32 characters split in two by a hyphen, short
+// enough to be written down and typed.
+func GenerateRecoveryCode() (string, error) {
+	bs := make([]byte, 5)
+	if _, err := rand.Read(bs); err != nil {
+		return "", err
+	}
+
+	code := strings.ToLower(base32.StdEncoding.EncodeToString(bs))
+	return code[:4] + "-" + code[4:], nil
+}
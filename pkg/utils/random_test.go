@@ -37,3 +37,27 @@ func TestCryptoRandom(t *testing.T) {
 		})
 	}
 }
+
+func TestGenerateOpaqueToken(t *testing.T) {
+	a, err := GenerateOpaqueToken(32)
+	if err != nil {
+		t.Errorf("GenerateOpaqueToken() error = %v", err)
+		return
+	}
+
+	b, _ := GenerateOpaqueToken(32)
+	assert.Len(t, a, 43, "32 bytes should encode to 43 characters")
+	assert.NotEqual(t, a, b, "tokens should be unique")
+}
+
+func TestGenerateRecoveryCode(t *testing.T) {
+	a, err := GenerateRecoveryCode()
+	if err != nil {
+		t.Errorf("GenerateRecoveryCode() error = %v", err)
+		return
+	}
+
+	b, _ := GenerateRecoveryCode()
+	assert.Regexp(t, `^[a-z2-7]{4}-[a-z2-7]{4}$`, a)
+	assert.NotEqual(t, a, b, "codes should be unique")
+}
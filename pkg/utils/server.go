@@ -35,7 +35,7 @@ func StartServerWithGracefulShutdown(mux http.Handler, addr string, l hclog.Logg
 	}()
 
 	// Graceful shutdown
-	sig := make(chan os.Signal)
+	sig := make(chan os.Signal, 1)
 	signal.Notify(sig, os.Interrupt, os.Kill)
 	l.Info("Terminating", "signal", <-sig)
 
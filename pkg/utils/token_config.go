@@ -0,0 +1,82 @@
+package utils
+
+import (
+	"os"
+	"strings"
+	"time"
+)
+
+// TokenConfig holds the registered claims shared by every jwt this service issues and accepts.
+type TokenConfig struct {
+	Issuer          string
+	Audience        []string
+	ClockSkew       time.Duration
+	AccessLifespan  time.Duration
+	RefreshLifespan time.Duration
+}
+
+// TokenConfigFromEnv constructs the TokenConfig from environment variables, TOKEN_AUDIENCE is comma
+// separated while TOKEN_CLOCK_SKEW, ACCESS_TOKEN_LIFESPAN and REFRESH_TOKEN_LIFESPAN are time.Duration
+// strings. Missing or malformed values use defaults.
+func TokenConfigFromEnv() *TokenConfig {
+	config := &TokenConfig{
+		Issuer:          "http://localhost:9090",
+		Audience:        []string{"knockbox"},
+		ClockSkew:       30 * time.Second,
+		AccessLifespan:  24 * time.Hour,
+		RefreshLifespan: 30 * 24 * time.Hour,
+	}
+
+	if issuer := os.Getenv("TOKEN_ISSUER"); issuer != "" {
+		config.Issuer = issuer
+	}
+
+	if audience := os.Getenv("TOKEN_AUDIENCE"); audience != "" {
+		config.Audience = nil
+		for _, aud := range strings.Split(audience, ",") {
+			if aud = strings.TrimSpace(aud); aud != "" {
+				config.Audience = append(config.Audience, aud)
+			}
+		}
+	}
+
+	if skew, err := time.ParseDuration(os.Getenv("TOKEN_CLOCK_SKEW")); err == nil && skew >= 0 {
+		config.ClockSkew = skew
+	}
+
+	if lifespan, err := time.ParseDuration(os.Getenv("ACCESS_TOKEN_LIFESPAN")); err == nil && lifespan > 0 {
+		config.AccessLifespan = lifespan
+	}
+
+	if lifespan, err := time.ParseDuration(os.Getenv("REFRESH_TOKEN_LIFESPAN")); err == nil && lifespan > 0 {
+		config.RefreshLifespan = lifespan
+	}
+
+	return config
+}
+
+// MissingTokenEnv returns which of TOKEN_ISSUER and TOKEN_AUDIENCE are unset. TokenConfigFromEnv then falls back
+// to defaults that only suit local development.
+func MissingTokenEnv() []string {
+	var missing []string
+	for _, key := range []string{"TOKEN_ISSUER", "TOKEN_AUDIENCE"} {
+		if os.Getenv(key) == "" {
+			missing = append(missing, key)
+		}
+	}
+
+	return missing
+}
+
+// HasAudience determines if any of the given audiences is accepted by the TokenConfig.
+func (c *TokenConfig) HasAudience(audience []string) bool {
+	for _, want := range c.Audience {
+		for _, aud := range audience {
+			if aud == want {
+				return true
+			}
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,82 @@
+package utils
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestTokenConfigFromEnv(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+		want *TokenConfig
+	}{
+		{
+			name: "should use defaults",
+			env:  map[string]string{},
+			want: &TokenConfig{
+				Issuer:          "http://localhost:9090",
+				Audience:        []string{"knockbox"},
+				ClockSkew:       30 * time.Second,
+				AccessLifespan:  24 * time.Hour,
+				RefreshLifespan: 720 * time.Hour,
+			},
+		},
+		{
+			name: "should read environment",
+			env: map[string]string{
+				"TOKEN_ISSUER":           "https://auth.knockbox.io",
+				"TOKEN_AUDIENCE":         "knockbox, api",
+				"TOKEN_CLOCK_SKEW":       "5s",
+				"ACCESS_TOKEN_LIFESPAN":  "15m",
+				"REFRESH_TOKEN_LIFESPAN": "168h",
+			},
+			want: &TokenConfig{
+				Issuer:          "https://auth.knockbox.io",
+				Audience:        []string{"knockbox", "api"},
+				ClockSkew:       5 * time.Second,
+				AccessLifespan:  15 * time.Minute,
+				RefreshLifespan: 168 * time.Hour,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, key := range []string{"TOKEN_ISSUER", "TOKEN_AUDIENCE", "TOKEN_CLOCK_SKEW", "ACCESS_TOKEN_LIFESPAN", "REFRESH_TOKEN_LIFESPAN"} {
+				t.Setenv(key, tt.env[key])
+			}
+
+			assert.Equal(t, tt.want, TokenConfigFromEnv())
+		})
+	}
+}
+
+func TestMissingTokenEnv(t *testing.T) {
+	tests := []struct {
+		name     string
+		issuer   string
+		audience string
+		want     []string
+	}{
+		{name: "should report both", want: []string{"TOKEN_ISSUER", "TOKEN_AUDIENCE"}},
+		{name: "should report the audience", issuer: "https://auth.knockbox.io", want: []string{"TOKEN_AUDIENCE"}},
+		{name: "should report none", issuer: "https://auth.knockbox.io", audience: "knockbox", want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("TOKEN_ISSUER", tt.issuer)
+			t.Setenv("TOKEN_AUDIENCE", tt.audience)
+
+			assert.Equal(t, tt.want, MissingTokenEnv())
+		})
+	}
+}
+
+func TestTokenConfig_HasAudience(t *testing.T) {
+	config := &TokenConfig{Audience: []string{"knockbox", "api"}}
+
+	assert.True(t, config.HasAudience([]string{"other", "api"}))
+	assert.False(t, config.HasAudience([]string{"other"}))
+	assert.False(t, config.HasAudience(nil))
+}
@@ -0,0 +1,57 @@
+package utils
+
+import (
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"os"
+	"strings"
+	"time"
+)
+
+// WebAuthnConfigFromEnv constructs the passkey relying party from environment variables. WEBAUTHN_RP_ID is the
+// domain passkeys are bound to, WEBAUTHN_RP_NAME is shown by authenticators, WEBAUTHN_RP_ORIGINS is a comma
+// separated list of the origins allowed to run ceremonies and WEBAUTHN_TIMEOUT is a time.Duration string.
+// Missing or malformed values use defaults. Passkeys must be discoverable and verify the user.
+func WebAuthnConfigFromEnv() *webauthn.Config {
+	rpName := "knockbox"
+	if name := os.Getenv("WEBAUTHN_RP_NAME"); name != "" {
+		rpName = name
+	}
+
+	rpId := "localhost"
+	if id := os.Getenv("WEBAUTHN_RP_ID"); id != "" {
+		rpId = id
+	}
+
+	origins := []string{"http://localhost:3000"}
+	if raw := os.Getenv("WEBAUTHN_RP_ORIGINS"); raw != "" {
+		origins = nil
+		for _, origin := range strings.Split(raw, ",") {
+			if origin = strings.TrimSpace(origin); origin != "" {
+				origins = append(origins, origin)
+			}
+		}
+	}
+
+	timeout := 5 * time.Minute
+	if d, err := time.ParseDuration(os.Getenv("WEBAUTHN_TIMEOUT")); err == nil && d > 0 {
+		timeout = d
+	}
+
+	ceremony := webauthn.TimeoutConfig{Enforce: true, Timeout: timeout, TimeoutUVD: timeout}
+
+	return &webauthn.Config{
+		RPID:                  rpId,
+		RPDisplayName:         rpName,
+		RPOrigins:             origins,
+		AttestationPreference: protocol.PreferNoAttestation,
+		AuthenticatorSelection: protocol.AuthenticatorSelection{
+			ResidentKey:      protocol.ResidentKeyRequirementRequired,
+			UserVerification: protocol.VerificationRequired,
+		},
+		Timeouts: webauthn.TimeoutsConfig{
+			Login:        ceremony,
+			Registration: ceremony,
+		},
+	}
+}
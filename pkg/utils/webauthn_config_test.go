@@ -0,0 +1,64 @@
+package utils
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestWebAuthnConfigFromEnv(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		rpId    string
+		rpName  string
+		origins []string
+		timeout time.Duration
+	}{
+		{
+			name:    "should use defaults",
+			env:     map[string]string{},
+			rpId:    "localhost",
+			rpName:  "knockbox",
+			origins: []string{"http://localhost:3000"},
+			timeout: 5 * time.Minute,
+		},
+		{
+			name: "should read environment",
+			env: map[string]string{
+				"WEBAUTHN_RP_ID":      "knockbox.io",
+				"WEBAUTHN_RP_NAME":    "Knockbox",
+				"WEBAUTHN_RP_ORIGINS": "https://knockbox.io, https://app.knockbox.io",
+				"WEBAUTHN_TIMEOUT":    "2m",
+			},
+			rpId:    "knockbox.io",
+			rpName:  "Knockbox",
+			origins: []string{"https://knockbox.io", "https://app.knockbox.io"},
+			timeout: 2 * time.Minute,
+		},
+		{
+			name: "should ignore malformed timeout",
+			env: map[string]string{
+				"WEBAUTHN_TIMEOUT": "-1m",
+			},
+			rpId:    "localhost",
+			rpName:  "knockbox",
+			origins: []string{"http://localhost:3000"},
+			timeout: 5 * time.Minute,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, key := range []string{"WEBAUTHN_RP_ID", "WEBAUTHN_RP_NAME", "WEBAUTHN_RP_ORIGINS", "WEBAUTHN_TIMEOUT"} {
+				t.Setenv(key, tt.env[key])
+			}
+
+			config := WebAuthnConfigFromEnv()
+			assert.Equal(t, tt.rpId, config.RPID)
+			assert.Equal(t, tt.rpName, config.RPDisplayName)
+			assert.Equal(t, tt.origins, config.RPOrigins)
+			assert.Equal(t, tt.timeout, config.Timeouts.Login.Timeout)
+			assert.True(t, config.Timeouts.Registration.Enforce, "ceremonies should expire")
+		})
+	}
+}